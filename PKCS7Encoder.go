@@ -37,14 +37,17 @@ func pkcs7Encode(count int) []byte {
  * 删除解密后明文的补位字符
  *
  * @param decrypted 解密后的明文
- * @return 删除补位字符后的明文
+ * @return 删除补位字符后的明文, 补位字符不合法时返回 ErrInvalidPadding
  */
-func pkcs7Decode(decrypted []byte) []byte {
+func pkcs7Decode(decrypted []byte) ([]byte, error) {
+	if len(decrypted) == 0 {
+		return nil, ErrInvalidPadding
+	}
 	pad := int(decrypted[len(decrypted)-1])
-	if pad < 1 || pad > 32 {
-		pad = 0
+	if pad < 1 || pad > blockSize || pad > len(decrypted) {
+		return nil, ErrInvalidPadding
 	}
-	return decrypted[:len(decrypted)-pad]
+	return decrypted[:len(decrypted)-pad], nil
 }
 
 /**
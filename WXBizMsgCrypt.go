@@ -21,6 +21,12 @@ import (
 var (
 	ErrValidateAppID     = errors.New("appid 校验失败")
 	ErrValidateSignature = errors.New("签名校验失败")
+
+	// 以下错误表示收到的密文格式错误, 通常是请求并非来自微信服务器或数据已被篡改
+	ErrInvalidAESKey     = errors.New("EncodingAESKey 长度错误")
+	ErrInvalidCiphertext = errors.New("密文不是合法的 base64 数据或长度不是块大小的整数倍")
+	ErrInvalidPadding    = errors.New("补位字符错误")
+	ErrInvalidMsgLength  = errors.New("消息长度错误")
 )
 
 // 发送的加密消息格式
@@ -49,6 +55,8 @@ func NewWXBizMsgCrypt(aesToken, encodingAesKey, appid string) (crypt *WXBizMsgCr
 	data, err := base64.StdEncoding.DecodeString(encodingAesKey + "=")
 	if err != nil {
 		return nil, err
+	} else if len(data) != 32 {
+		return nil, ErrInvalidAESKey
 	} else {
 		return &WXBizMsgCrypt{
 			token:  aesToken,
@@ -86,7 +94,7 @@ func (mc *WXBizMsgCrypt) Encrypt(random string, text []byte) (base64Encrypted st
 	return base64.StdEncoding.EncodeToString(encryped), nil
 }
 
-// 对密文进行解密
+// 对密文进行解密, 密文格式错误时返回 ErrInvalidCiphertext、ErrInvalidPadding 或 ErrInvalidMsgLength
 func (mc *WXBizMsgCrypt) Decrypt(text string) (original []byte, err error) {
 	// 设置解密模式为AES的CBC模式
 	block, err := aes.NewCipher(mc.aesKey)
@@ -94,23 +102,32 @@ func (mc *WXBizMsgCrypt) Decrypt(text string) (original []byte, err error) {
 		return nil, err
 	}
 	decrypter := cipher.NewCBCDecrypter(block, mc.aesKey[:16])
-	if err != nil {
-		return nil, err
-	}
 
 	// 使用BASE64对密文进行解码
 	src, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(src) == 0 || len(src)%aes.BlockSize != 0 {
+		return nil, ErrInvalidCiphertext
+	}
 	dst := make([]byte, len(src))
 
 	// 解密
 	decrypter.CryptBlocks(dst, src)
 
 	// 去除补位字符
-	dst = pkcs7Decode(dst)
+	dst, err = pkcs7Decode(dst)
+	if err != nil {
+		return nil, err
+	}
 
 	// 分离16位随机字符串,网络字节序和AppId
+	if len(dst) < 20 {
+		return nil, ErrInvalidMsgLength
+	}
 	networkOrder := dst[16:20]
 	xmlLength := recoverNetworkBytesOrder(networkOrder)
+	if xmlLength < 0 || xmlLength > len(dst)-20 {
+		return nil, ErrInvalidMsgLength
+	}
 	xmlContent := dst[20 : 20+xmlLength]
 	formAppID := string(dst[20+xmlLength:])
 	if formAppID != mc.appID {
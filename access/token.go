@@ -19,9 +19,14 @@ type Ticket struct {
 }
 
 func GetAccessToken(appid, appSecret string) (token *Token, err error) {
+	return GetAccessTokenWith(wechat.DefaultClient, appid, appSecret)
+}
+
+// GetAccessTokenWith 同 GetAccessToken, 通过客户端 c 发送请求
+func GetAccessTokenWith(c *wechat.Client, appid, appSecret string) (token *Token, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/token?grant_type=client_credential&appid=" + appid + "&secret=" + appSecret
 	token = &Token{}
-	err = wechat.GetJson(uri, token)
+	err = c.GetJson(uri, token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -30,9 +35,14 @@ func GetAccessToken(appid, appSecret string) (token *Token, err error) {
 }
 
 func GetTicket(accessToken string) (ticket *Ticket, err error) {
+	return GetTicketWith(wechat.DefaultClient, accessToken)
+}
+
+// GetTicketWith 同 GetTicket, 通过客户端 c 发送请求
+func GetTicketWith(c *wechat.Client, accessToken string) (ticket *Ticket, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/ticket/getticket?type=jsapi&access_token=" + accessToken
 	ticket = &Ticket{}
-	err = wechat.GetJson(uri, ticket)
+	err = c.GetJson(uri, ticket)
 	if err != nil {
 		return nil, err
 	} else {
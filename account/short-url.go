@@ -14,13 +14,18 @@ type response struct {
 
 // 获得短连接
 func GetShortUrl(token, longUrl string) (shortUrl string, err error) {
+	return GetShortUrlWith(wechat.DefaultClient, token, longUrl)
+}
+
+// GetShortUrlWith 同 GetShortUrl, 通过客户端 c 发送请求
+func GetShortUrlWith(c *wechat.Client, token, longUrl string) (shortUrl string, err error) {
 	data := map[string]interface{}{
 		"action":   "long2short",
 		"long_url": longUrl,
 	}
 	url := "https://api.weixin.qq.com/cgi-bin/shorturl?access_token=" + token
 	var res *response
-	err = wechat.PostSchema(wechat.KindJson, url, data, &res)
+	err = c.PostSchema(wechat.KindJson, url, data, &res)
 	if err != nil {
 		return "", err
 	} else {
@@ -0,0 +1,175 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// autoreply 是基于关键词及正则表达式的自动回复规则引擎, 可作为 message.Server 的 Handler 使用:
+//
+//	engine := autoreply.NewEngine(nil)
+//	err := engine.LoadFile("rules.yaml")
+//	engine.Access, engine.Appid = access, appid // 需要回复客服消息时设置
+//	engine.Register(server)
+//
+// 规则文件修改后再次调用 LoadFile 即可重新加载, 加载失败时继续使用原来的规则.
+package autoreply
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/platform"
+)
+
+var ErrNoAccess = errors.New("回复客服消息需要设置 Engine.Access")
+
+// Engine 是自动回复规则引擎, 零值可以直接使用
+type Engine struct {
+	// 获取发送客服消息所需的令牌, 只有客服消息回复需要
+	Access *platform.AccessContainer
+
+	// 公众号 appid
+	Appid string
+
+	// 获取消息所属公众号的 appid, 用于第三方平台同时接收多个公众号的消息, 不为 nil 时忽略 Appid
+	AppidFunc func(req *message.Request) (appid string, err error)
+
+	// 规则生效时间段所在的时区, 为 nil 时为 time.Local
+	Location *time.Location
+
+	rules  *Rules
+	rand   *rand.Rand
+	mu     sync.RWMutex
+	randMu sync.Mutex
+}
+
+// NewEngine 创建规则引擎, rules 可为 nil, 之后通过 SetRules 或 LoadFile 设置
+func NewEngine(rules *Rules) *Engine {
+	if rules == nil {
+		rules = &Rules{}
+	}
+	return &Engine{rules: rules, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// SetRules 替换所有规则, rules 应通过 ParseJSON、ParseYAML 或 ParseFile 获得
+func (e *Engine) SetRules(rules *Rules) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Rules 返回当前使用的规则, 未设置时返回空规则
+func (e *Engine) Rules() *Rules {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.rules == nil {
+		return &Rules{}
+	}
+	return e.rules
+}
+
+// LoadFile 从规则文件加载规则并替换当前规则, 加载失败时不影响当前规则
+func (e *Engine) LoadFile(filename string) error {
+	rules, err := ParseFile(filename)
+	if err != nil {
+		return err
+	}
+	e.SetRules(rules)
+	return nil
+}
+
+// Register 将引擎注册为 s 的关注事件处理器及默认处理器
+func (e *Engine) Register(s *message.Server) {
+	s.HandleEvent(message.EvtUserSubscribe, e)
+	s.Default = e
+}
+
+// Match 返回 t 时消息 msg 匹配的规则, 没有匹配的规则时返回 nil
+func (e *Engine) Match(msg *message.ServerMessage, t time.Time) (*Rule, error) {
+	rules := e.Rules().Rules
+	find := func(match func(r *Rule) bool) *Rule {
+		for _, r := range rules {
+			if match(r) && r.Active(t) {
+				return r
+			}
+		}
+		return nil
+	}
+	switch msg.MsgType {
+	case message.ServerMsgTypeEvent:
+		if msg.Event == message.EvtUserSubscribe {
+			return find(func(r *Rule) bool { return r.Match == MatchSubscribe }), nil
+		}
+		return nil, nil
+	case message.ServerMsgTypeText:
+		text, err := msg.MarshalTextMessage()
+		if err != nil {
+			return nil, err
+		}
+		content := strings.TrimSpace(text.Content)
+		if r := find(func(r *Rule) bool { return r.matchText(content) }); r != nil {
+			return r, nil
+		}
+	}
+	return find(func(r *Rule) bool { return r.Match == MatchDefault }), nil
+}
+
+func (e *Engine) now() time.Time {
+	if e.Location != nil {
+		return time.Now().In(e.Location)
+	}
+	return time.Now()
+}
+
+func (e *Engine) choose(replies []*Reply) *Reply {
+	if len(replies) == 1 {
+		return replies[0]
+	}
+	e.randMu.Lock()
+	defer e.randMu.Unlock()
+	if e.rand == nil {
+		e.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return replies[e.rand.Intn(len(replies))]
+}
+
+func (e *Engine) ServeMessage(req *message.Request) (*message.ResponseMessage, error) {
+	rule, err := e.Match(req.ServerMessage, e.now())
+	if err != nil || rule == nil {
+		return nil, err
+	}
+	reply := e.choose(rule.Replies)
+	if reply.Message != nil {
+		return reply.Message.build(req.ServerMessage)
+	}
+	return nil, e.sendCustomerMessages(req, reply.CustomerMessages)
+}
+
+// 依次发送客服消息
+func (e *Engine) sendCustomerMessages(req *message.Request, msgs []*message.CustomerMessage) error {
+	if e.Access == nil {
+		return ErrNoAccess
+	}
+	appid := e.Appid
+	if e.AppidFunc != nil {
+		var err error
+		appid, err = e.AppidFunc(req)
+		if err != nil {
+			return err
+		}
+	}
+	return e.Access.Call(req.Context(), appid, func(c *wechat.Client, token string) error {
+		for _, msg := range msgs {
+			// 规则中的消息被多个请求共用, 发送副本
+			m := *msg
+			err := m.SendWith(c, token, req.FromUserName)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package autoreply_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat/autoreply"
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/wechattest"
+)
+
+const testRules = `
+rules:
+  - name: exact
+    match: exact
+    keywords: [hello, 你好]
+    replies:
+      - message: {type: text, content: exact}
+  - name: prefix
+    match: prefix
+    keywords: [help]
+    replies:
+      - message: {type: text, content: prefix}
+  - name: regexp
+    match: regexp
+    keywords: ['^\d{11}$']
+    replies:
+      - message: {type: text, content: regexp}
+  - name: night
+    match: exact
+    keywords: [time]
+    time_windows:
+      - {start: "22:00", end: "06:00"}
+    replies:
+      - message: {type: text, content: night}
+  - name: day
+    match: exact
+    keywords: [time]
+    replies:
+      - message: {type: text, content: day}
+  - name: subscribe
+    match: subscribe
+    replies:
+      - message: {type: text, content: welcome}
+  - name: default
+    match: default
+    replies:
+      - message: {type: text, content: default}
+`
+
+func textMessage(content string) *message.ServerMessage {
+	return &message.ServerMessage{
+		MsgType: message.ServerMsgTypeText,
+		Data:    []byte("<xml><Content><![CDATA[" + content + "]]></Content></xml>"),
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules, err := autoreply.ParseYAML([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := autoreply.NewEngine(rules)
+	noon := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	midnight := time.Date(2020, 6, 1, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		msg  *message.ServerMessage
+		t    time.Time
+		want string
+	}{
+		{textMessage("hello"), noon, "exact"},
+		{textMessage("  你好 "), noon, "exact"},
+		{textMessage("hello world"), noon, "default"},
+		{textMessage("help me"), noon, "prefix"},
+		{textMessage("13800138000"), noon, "regexp"},
+		{textMessage("1380013800"), noon, "default"},
+		{textMessage("time"), midnight, "night"},
+		{textMessage("time"), noon, "day"},
+		{&message.ServerMessage{MsgType: message.ServerMsgTypeEvent, Event: message.EvtUserSubscribe}, noon, "subscribe"},
+		{&message.ServerMessage{MsgType: message.ServerMsgTypeEvent, Event: message.EvtUserClick}, noon, ""},
+		{&message.ServerMessage{MsgType: message.ServerMsgTypeImage}, noon, "default"},
+	}
+	for _, c := range cases {
+		rule, err := e.Match(c.msg, c.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if rule != nil {
+			got = rule.Name
+		}
+		if got != c.want {
+			t.Errorf("%s %s %s: got rule %q, want %q", c.msg.MsgType, c.msg.Event, c.msg.Data, got, c.want)
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	rules, err := autoreply.ParseJSON([]byte(`{"rules": [{"name": "weekend", "match": "default",
+		"time_windows": [{"start": "20:00", "end": "02:00", "weekdays": [6]}],
+		"replies": [{"message": {"type": "text", "content": "x"}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := rules.Rules[0].TimeWindows[0]
+	saturday := time.Date(2020, 6, 6, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		{saturday.Add(21 * time.Hour), true},
+		{saturday.Add(19 * time.Hour), false},
+		// 跨越零点时按开始时间所在的日期判断
+		{saturday.Add(25 * time.Hour), true},
+		{saturday.Add(1 * time.Hour), false},
+		{saturday.Add(26 * time.Hour), false},
+	}
+	for _, c := range cases {
+		if got := w.Contains(c.t); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.t.Format("Mon 15:04"), got, c.want)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	cases := []string{
+		`{"rules": [{"name": "a", "match": "unknown", "replies": [{"message": {"type": "text"}}]}]}`,
+		`{"rules": [{"name": "a", "match": "exact", "replies": [{"message": {"type": "text"}}]}]}`,
+		`{"rules": [{"name": "a", "match": "regexp", "keywords": ["("], "replies": [{"message": {"type": "text"}}]}]}`,
+		`{"rules": [{"name": "a", "match": "default"}]}`,
+		`{"rules": [{"name": "a", "match": "default", "replies": [{}]}]}`,
+		`{"rules": [{"name": "a", "match": "default", "time_windows": [{"start": "25:00"}], "replies": [{"message": {"type": "text"}}]}]}`,
+	}
+	for _, c := range cases {
+		_, err := autoreply.ParseJSON([]byte(c))
+		if !errors.Is(err, autoreply.ErrInvalidRule) {
+			t.Errorf("%s: got %v, want ErrInvalidRule", c, err)
+		}
+	}
+}
+
+func TestZeroEngine(t *testing.T) {
+	e := &autoreply.Engine{}
+	rule, err := e.Match(textMessage("hello"), time.Now())
+	if err != nil || rule != nil {
+		t.Fatalf("got %v %v, want no rule", rule, err)
+	}
+	rules, err := autoreply.ParseYAML([]byte(`
+rules:
+  - name: random
+    match: default
+    replies:
+      - message: {type: text, content: a}
+      - message: {type: text, content: b}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetRules(rules)
+	s := message.NewServer("token", nil)
+	e.Register(s)
+	sim := &wechattest.Simulator{Token: "token"}
+	reply, err := sim.Send(s, wechattest.TextMessage("openid", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply == nil || reply.Content == nil || (reply.Content.Value != "a" && reply.Content.Value != "b") {
+		t.Errorf("unexpected reply %+v", reply)
+	}
+}
+
+func TestCustomerMessagesWithoutAccess(t *testing.T) {
+	rules, err := autoreply.ParseJSON([]byte(`{"rules": [{"name": "a", "match": "default",
+		"replies": [{"customer_messages": [{"msgtype": "text", "text": {"content": "x"}}]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := autoreply.NewEngine(rules)
+	req := &message.Request{ServerMessage: textMessage("hello")}
+	_, err = e.ServeMessage(req)
+	if err != autoreply.ErrNoAccess {
+		t.Errorf("got %v, want ErrNoAccess", err)
+	}
+}
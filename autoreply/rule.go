@@ -0,0 +1,325 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package autoreply
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/message"
+	"github.com/pkg/errors"
+)
+
+// 匹配方式
+type MatchType string
+
+const (
+	// 文本消息与关键词完全相同
+	MatchExact MatchType = "exact"
+
+	// 文本消息以关键词开头
+	MatchPrefix MatchType = "prefix"
+
+	// 文本消息匹配正则表达式
+	MatchRegexp MatchType = "regexp"
+
+	// 用户关注公众号
+	MatchSubscribe MatchType = "subscribe"
+
+	// 没有匹配任何规则的用户消息
+	MatchDefault MatchType = "default"
+)
+
+var ErrInvalidRule = errors.New("自动回复规则错误")
+
+// Rules 是自动回复规则集合, 文本消息按顺序匹配, 使用第一个匹配且在生效时间内的规则
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule 是自动回复规则, 可以从 JSON 或 YAML 文件加载:
+//
+//	rules:
+//	  - name: hello
+//	    match: exact
+//	    keywords: [你好, hello]
+//	    time_windows:
+//	      - {start: "09:00", end: "18:00", weekdays: [1, 2, 3, 4, 5]}
+//	    replies:
+//	      - message: {type: text, content: 您好}
+//	      - customer_messages:
+//	          - {msgtype: text, text: {content: 您好}}
+//	          - {msgtype: image, image: {media_id: MEDIA_ID}}
+type Rule struct {
+	// 规则名称
+	Name string `json:"name"`
+
+	// 匹配方式
+	Match MatchType `json:"match"`
+
+	// 关键词, 匹配方式为 regexp 时为正则表达式
+	Keywords []string `json:"keywords,omitempty"`
+
+	// 生效时间段, 为空时一直有效
+	TimeWindows []*TimeWindow `json:"time_windows,omitempty"`
+
+	// 回复内容, 有多个回复时随机选择一个
+	Replies []*Reply `json:"replies"`
+
+	patterns []*regexp.Regexp
+}
+
+// Reply 是一次回复的内容, Message 及 CustomerMessages 只能设置其中一个
+type Reply struct {
+	// 被动回复消息
+	Message *Message `json:"message,omitempty"`
+
+	// 客服消息, 可以连续发送多条
+	CustomerMessages []*message.CustomerMessage `json:"customer_messages,omitempty"`
+}
+
+// Message 是被动回复消息, 按 Type 使用对应的字段
+type Message struct {
+	Type message.ResponseMsgType `json:"type"`
+
+	// text
+	Content string `json:"content,omitempty"`
+
+	// image, voice, video
+	MediaID string `json:"media_id,omitempty"`
+
+	// video, music
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// music
+	MusicURL     string `json:"music_url,omitempty"`
+	HQMusicURL   string `json:"hq_music_url,omitempty"`
+	ThumbMediaID string `json:"thumb_media_id,omitempty"`
+
+	// news
+	Articles []*message.CustomerArticle `json:"articles,omitempty"`
+
+	// transfer_customer_service
+	KfAccount string `json:"kf_account,omitempty"`
+}
+
+// 按触发消息构建被动回复消息
+func (m *Message) build(sm *message.ServerMessage) (*message.ResponseMessage, error) {
+	switch m.Type {
+	case message.ResponseMsgTypeText:
+		return sm.ReplyText(m.Content)
+	case message.ResponseMsgTypeImage:
+		return sm.ReplyImage(m.MediaID)
+	case message.ResponseMsgTypeVoice:
+		return sm.ReplyVoice(m.MediaID)
+	case message.ResponseMsgTypeVideo:
+		return sm.ReplyVideo(m.MediaID, m.Title, m.Description)
+	case message.ResponseMsgTypeMusic:
+		return sm.ReplyMusic(m.Title, m.Description, m.MusicURL, m.HQMusicURL, m.ThumbMediaID)
+	case message.ResponseMsgTypeNews:
+		return sm.ReplyNews(m.Articles...)
+	case message.ResponseMsgTypeTransferCustomerService:
+		return sm.ReplyTransferCustomerService(m.KfAccount)
+	default:
+		return nil, errors.Wrapf(ErrInvalidRule, "unknown reply type %q", m.Type)
+	}
+}
+
+// TimeWindow 是规则的生效时间段, End 小于 Start 时表示跨越零点
+type TimeWindow struct {
+	// 开始及结束时间, 格式为 "15:04", 为空时分别为 "00:00" 及 "24:00"
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+
+	// 生效的星期, 0 为星期日, 为空时每天有效. 跨越零点时按开始时间所在的日期判断
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+
+	start, end int
+}
+
+// 解析 "15:04" 格式的时间, 返回从零点开始的分钟数
+func parseClock(clock string, def int) (int, error) {
+	if clock == "" {
+		return def, nil
+	}
+	if clock == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidRule, "time %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *TimeWindow) compile() (err error) {
+	w.start, err = parseClock(w.Start, 0)
+	if err != nil {
+		return err
+	}
+	w.end, err = parseClock(w.End, 24*60)
+	return err
+}
+
+func (w *TimeWindow) weekday(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains 判断 t 是否在时间段内
+func (w *TimeWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return w.start <= minute && minute < w.end && w.weekday(t.Weekday())
+	}
+	// 跨越零点
+	if minute >= w.start {
+		return w.weekday(t.Weekday())
+	}
+	if minute < w.end {
+		return w.weekday(t.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+// Active 判断规则在 t 时是否生效
+func (r *Rule) Active(t time.Time) bool {
+	if len(r.TimeWindows) == 0 {
+		return true
+	}
+	for _, w := range r.TimeWindows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断文本消息 content 是否匹配规则
+func (r *Rule) matchText(content string) bool {
+	switch r.Match {
+	case MatchExact:
+		for _, kw := range r.Keywords {
+			if content == kw {
+				return true
+			}
+		}
+	case MatchPrefix:
+		for _, kw := range r.Keywords {
+			if strings.HasPrefix(content, kw) {
+				return true
+			}
+		}
+	case MatchRegexp:
+		for _, p := range r.patterns {
+			if p.MatchString(content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 校验规则并编译正则表达式及时间段
+func (r *Rule) compile() error {
+	wrap := func(format string, args ...interface{}) error {
+		return errors.Wrapf(ErrInvalidRule, "rule %q: %s", r.Name, fmt.Sprintf(format, args...))
+	}
+	switch r.Match {
+	case MatchExact, MatchPrefix, MatchRegexp:
+		if len(r.Keywords) == 0 {
+			return wrap("keywords is required")
+		}
+	case MatchSubscribe, MatchDefault:
+	default:
+		return wrap("unknown match type %q", r.Match)
+	}
+	r.patterns = nil
+	if r.Match == MatchRegexp {
+		for _, kw := range r.Keywords {
+			p, err := regexp.Compile(kw)
+			if err != nil {
+				return wrap("%v", err)
+			}
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	for _, w := range r.TimeWindows {
+		if err := w.compile(); err != nil {
+			return wrap("%v", err)
+		}
+	}
+	if len(r.Replies) == 0 {
+		return wrap("replies is required")
+	}
+	for i, reply := range r.Replies {
+		if (reply.Message == nil) == (len(reply.CustomerMessages) == 0) {
+			return wrap("replies[%d]: exactly one of message and customer_messages is required", i)
+		}
+	}
+	return nil
+}
+
+func (rs *Rules) compile() error {
+	for _, r := range rs.Rules {
+		if err := r.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseJSON 解析 JSON 格式的规则
+func ParseJSON(data []byte) (*Rules, error) {
+	rs := &Rules{}
+	err := json.Unmarshal(data, rs)
+	if err != nil {
+		return nil, err
+	}
+	err = rs.compile()
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// ParseYAML 解析 YAML 格式的规则, 字段名与 JSON 格式相同
+func ParseYAML(data []byte) (*Rules, error) {
+	data, err := wechat.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSON(data)
+}
+
+// ParseFile 按扩展名解析 .json、.yaml 或 .yml 格式的规则文件
+func ParseFile(filename string) (*Rules, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return nil, errors.Errorf("unsupported rules file: %s", filename)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 微信接口地址, 各个包中的接口地址均以此为前缀
+const (
+	APIBaseURL = "https://api.weixin.qq.com"
+	MchBaseURL = "https://api.mch.weixin.qq.com"
+)
+
+// Client 用于发送微信接口请求.
+// 可通过 HTTPClient 设置代理等传输参数, 通过 BaseURL 将请求发送到其他地址(如本地的模拟服务器).
+type Client struct {
+	// 为 nil 时使用 http.DefaultClient
+	HTTPClient *http.Client
+
+	// 单次请求超时时间, 为 0 时使用 HTTPClient 的设置
+	Timeout time.Duration
+
+	// 替换 APIBaseURL, 为空时不替换
+	BaseURL string
+
+	// 替换 MchBaseURL(微信支付接口), 为空时不替换
+	MchBaseURL string
+
+	// 重试策略, 为 nil 时使用 DefaultRetryPolicy, 设置为 NoRetry 时不重试
+	Retry *RetryPolicy
+
+	// 请求频率限制器, 为 nil 时不限制
+	Limiter RateLimiter
+
+	// 拦截器, 按顺序包裹每次接口调用, 可用于日志、监控及链路追踪
+	Interceptors []Interceptor
+
+	ctx        context.Context
+	appid      string
+	idempotent bool
+}
+
+// 默认客户端, 所有未指定客户端的接口都通过该客户端发送请求.
+// 值为 nil 的 *Client 等同于 DefaultClient.
+var DefaultClient = &Client{Retry: DefaultRetryPolicy}
+
+// 未设置 Retry 的客户端使用默认重试策略
+func (c *Client) retryPolicy() *RetryPolicy {
+	if c.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return c.Retry
+}
+
+func getClient(c *Client) *Client {
+	if c == nil {
+		return DefaultClient
+	}
+	return c
+}
+
+// WithContext 返回绑定了 ctx 的客户端副本, 通过该副本发出的请求都会受 ctx 控制(取消或超时).
+// 所有 XxxWith 形式的接口都可以通过该方法获得 context 支持, 如:
+//
+//	material.CountMaterialsWith(client.WithContext(ctx), token)
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	cp := *getClient(c)
+	cp.ctx = ctx
+	return &cp
+}
+
+// Context 返回客户端所绑定的 ctx, 未绑定时返回 context.Background()
+func (c *Client) Context() context.Context {
+	c = getClient(c)
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// WithContext 返回绑定了 ctx 的 DefaultClient 副本
+func WithContext(ctx context.Context) *Client {
+	return DefaultClient.WithContext(ctx)
+}
+
+// WithAppid 返回绑定了 appid 的客户端副本, appid 用于按公众号区分请求频率限制等.
+func (c *Client) WithAppid(appid string) *Client {
+	cp := *getClient(c)
+	cp.appid = appid
+	return &cp
+}
+
+// Appid 返回客户端所绑定的 appid
+func (c *Client) Appid() string {
+	return getClient(c).appid
+}
+
+// URL 将微信接口地址替换为客户端所设置的地址
+func (c *Client) URL(rawurl string) string {
+	c = getClient(c)
+	if c.BaseURL != "" && strings.HasPrefix(rawurl, APIBaseURL) {
+		return strings.TrimSuffix(c.BaseURL, "/") + strings.TrimPrefix(rawurl, APIBaseURL)
+	}
+	if c.MchBaseURL != "" && strings.HasPrefix(rawurl, MchBaseURL) {
+		return strings.TrimSuffix(c.MchBaseURL, "/") + strings.TrimPrefix(rawurl, MchBaseURL)
+	}
+	return rawurl
+}
+
+func (c *Client) httpClient() *http.Client {
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	if c.Timeout > 0 {
+		cp := *hc
+		cp.Timeout = c.Timeout
+		hc = &cp
+	}
+	return hc
+}
+
+// Do 发送请求并读取所有响应数据
+func (c *Client) Do(req *http.Request) (data []byte, err error) {
+	_, data, err = c.do(req, nil)
+	return data, err
+}
+
+// Download 发送请求并将响应数据写入 w, 用于下载多媒体文件等二进制数据.
+// 如果响应为 json 或文本数据(如错误信息), 则不写入 w, 而是通过 data 返回, 由调用方解析.
+// header 为响应头, 可从中获得 Content-Type 及文件名. 开始写入 w 之后出错时不会重试.
+func (c *Client) Download(req *http.Request, w io.Writer) (header http.Header, data []byte, err error) {
+	return c.do(req, w)
+}
+
+func (c *Client) do(req *http.Request, w io.Writer) (header http.Header, data []byte, err error) {
+	c = getClient(c)
+	endpoint := req.URL.Path
+	if u := c.URL(req.URL.String()); u != req.URL.String() {
+		req.URL, err = url.Parse(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Host = req.URL.Host
+	}
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+	if len(c.Interceptors) == 0 {
+		attempt, err := c.attempt(req, endpoint, w)
+		return attempt.Header, attempt.Data, err
+	}
+	call := newCall(c.appid, endpoint, req)
+	err = c.intercept(call, func(call *Call) error {
+		start := time.Now()
+		attempt, err := c.attempt(req.WithContext(call.Context), endpoint, w)
+		call.Latency = time.Since(start)
+		call.Attempts = attempt.Number
+		call.StatusCode = attempt.StatusCode
+		call.ResponseBody = attempt.Data
+		call.Err = err
+		header = attempt.Header
+		if e, ok := ParseError(attempt.Data).(*Error); ok {
+			call.ErrCode = e.ErrCode
+		}
+		return err
+	})
+	return header, call.ResponseBody, err
+}
+
+// attempt 发送请求, 按限流器及重试策略处理, 返回最后一次尝试的结果
+func (c *Client) attempt(req *http.Request, endpoint string, w io.Writer) (*Attempt, error) {
+	attempt := &Attempt{
+		Request:    req,
+		Endpoint:   endpoint,
+		Idempotent: c.idempotent || req.Method == http.MethodGet || req.Method == http.MethodHead,
+	}
+	for {
+		attempt.Number++
+		if c.Limiter != nil {
+			err := c.Limiter.Wait(req.Context(), c.appid, endpoint)
+			if err != nil {
+				return attempt, err
+			}
+		}
+		c.send(attempt, w)
+		if c.Limiter != nil && attempt.Err == nil {
+			c.Limiter.Observe(c.appid, endpoint, ParseError(attempt.Data))
+		}
+		if !c.retryPolicy().wait(attempt) {
+			return attempt, attempt.Err
+		}
+	}
+}
+
+// 是否需要读取所有响应数据: 出错的响应及 json、文本数据.
+// 未提供 Content-Type 时, 根据响应数据是否以 '{' 开头判断是否为 json 数据
+func bufferResponse(resp *http.Response, body *bufio.Reader) bool {
+	if resp.StatusCode != http.StatusOK {
+		return true
+	}
+	ct := resp.Header.Get("Content-Type")
+	if ct == "" {
+		head, _ := body.Peek(sniffLen)
+		head = bytes.TrimLeft(head, " \t\r\n")
+		return len(head) > 0 && head[0] == '{'
+	}
+	return strings.HasPrefix(ct, "application/json") || strings.HasPrefix(ct, "text/")
+}
+
+// 判断响应数据类型时最多读取的字节数
+const sniffLen = 512
+
+// send 发送一次请求并将结果记录在 attempt 中. w 不为 nil 时, 二进制数据直接写入 w
+func (c *Client) send(attempt *Attempt, w io.Writer) {
+	attempt.StatusCode, attempt.Header, attempt.Data = 0, nil, nil
+	resp, err := c.httpClient().Do(attempt.Request)
+	if err != nil {
+		attempt.Err = err
+		return
+	}
+	defer resp.Body.Close()
+	attempt.StatusCode, attempt.Header = resp.StatusCode, resp.Header
+	if w == nil {
+		attempt.Data, attempt.Err = ioutil.ReadAll(resp.Body)
+		return
+	}
+	body := bufio.NewReaderSize(resp.Body, sniffLen)
+	if !bufferResponse(resp, body) {
+		attempt.written = true
+		_, attempt.Err = io.Copy(w, body)
+		return
+	}
+	attempt.Data, attempt.Err = ioutil.ReadAll(body)
+}
+
+// Get 发送 GET 请求并读取所有响应数据
+func (c *Client) Get(uri string) (data []byte, err error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post 发送 POST 请求并读取所有响应数据
+func (c *Client) Post(uri, contentType string, body io.Reader) (data []byte, err error) {
+	req, err := http.NewRequest(http.MethodPost, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// ParseError 解析 json 响应数据中的错误信息, 如果 errcode 不为 0 则返回 *Error
+func ParseError(data []byte) error {
+	if bytes.Contains(data, []byte("errcode")) {
+		var werr = &Error{}
+		_ = json.Unmarshal(data, werr)
+		if werr.ErrCode != 0 {
+			return werr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/wechattest"
+)
+
+// 返回使用快速重试策略的客户端及 access_token
+func newRetryClient(t *testing.T, srv *wechattest.Server) (*wechat.Client, string) {
+	t.Helper()
+	srv.AddApp("wx123", "secret")
+	c := srv.Client()
+	c.Retry = &wechat.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	token, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ResetRequests()
+	return c, token.Value
+}
+
+func TestClientRetry(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	c, token := newRetryClient(t, srv)
+	menus := &wechat.Menus{Buttons: []*wechat.MenuButton{{Type: wechat.MenuButtonClick, Name: "a", Key: "a"}}}
+	err := wechat.GenerateMenusWith(c, token, menus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ResetRequests()
+
+	// GET 请求在 5xx 及系统繁忙时重试
+	srv.InjectStatus("/cgi-bin/menu/get", http.StatusBadGateway, 1)
+	srv.InjectError("/cgi-bin/menu/get", wechat.ErrCodeSystemBusy, 1)
+	_, err = wechat.GetMenusWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/get")); n != 3 {
+		t.Errorf("GET: got %d requests, want 3", n)
+	}
+
+	// 超过最大尝试次数后返回最后一次的错误
+	srv.InjectError("/cgi-bin/menu/get", wechat.ErrCodeSystemBusy, 5)
+	_, err = wechat.GetMenusWith(c, token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeSystemBusy {
+		t.Errorf("got %v, want system busy", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/get")); n != 6 {
+		t.Errorf("GET: got %d requests, want 6", n)
+	}
+	srv.ClearErrors()
+
+	// 非幂等的 POST 请求不重试
+	srv.InjectError("/cgi-bin/menu/create", wechat.ErrCodeSystemBusy, 1)
+	err = wechat.GenerateMenusWith(c, token, menus)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeSystemBusy {
+		t.Errorf("got %v, want system busy", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/create")); n != 1 {
+		t.Errorf("POST: got %d requests, want 1", n)
+	}
+
+	// 通过 Idempotent 发送的 POST 请求重试时重新发送请求数据
+	srv.AddUsers("wx123", &wechattest.User{Openid: "openid", Subscribe: 1})
+	srv.InjectStatus("/cgi-bin/menu/trymatch", http.StatusServiceUnavailable, 1)
+	_, err = wechat.TryMatchMenusWith(c, token, "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.RequestsTo("/cgi-bin/menu/trymatch")
+	if len(reqs) != 2 || string(reqs[0].Body) != string(reqs[1].Body) || len(reqs[1].Body) == 0 {
+		t.Errorf("idempotent POST: got %d requests", len(reqs))
+	}
+}
+
+func TestClientTokenNotRetried(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	c, _ := newRetryClient(t, srv)
+	srv.InjectStatus("/cgi-bin/token", http.StatusBadGateway, 1)
+	_, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/token")); n != 1 {
+		t.Errorf("got %d token requests, want 1", n)
+	}
+}
+
+func TestClientDefaultRetry(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	c, token := newRetryClient(t, srv)
+
+	// 未设置 Retry 时使用 DefaultRetryPolicy
+	c.Retry = nil
+	srv.InjectStatus("/cgi-bin/menu/get", http.StatusBadGateway, 1)
+	_, _ = wechat.GetMenusWith(c, token)
+	if n := len(srv.RequestsTo("/cgi-bin/menu/get")); n != 2 {
+		t.Errorf("nil Retry: got %d requests, want 2", n)
+	}
+	srv.ResetRequests()
+
+	c.Retry = wechat.NoRetry
+	srv.InjectStatus("/cgi-bin/menu/get", http.StatusBadGateway, 1)
+	_, err := wechat.GetMenusWith(c, token)
+	if err == nil {
+		t.Error("NoRetry: expected error")
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/get")); n != 1 {
+		t.Errorf("NoRetry: got %d requests, want 1", n)
+	}
+}
+
+func TestClientDownload(t *testing.T) {
+	var contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 为 nil 时不自动检测 Content-Type
+		w.Header()["Content-Type"] = nil
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	c := &wechat.Client{BaseURL: srv.URL}
+	cases := []struct {
+		name, contentType, body string
+		json                    bool
+	}{
+		{"json", "application/json; charset=utf-8", `{"errcode":40007}`, true},
+		{"text", "text/plain", `{"errcode":40007}`, true},
+		{"binary", "image/jpeg", "\xff\xd8\xff", false},
+		// 未提供 Content-Type 时根据数据判断
+		{"sniff json", "", ` {"errcode":40007}`, true},
+		{"sniff binary", "", "\xff\xd8\xff", false},
+		{"sniff empty", "", "", false},
+	}
+	for _, cs := range cases {
+		contentType, body = cs.contentType, cs.body
+		req, _ := http.NewRequest(http.MethodGet, wechat.APIBaseURL+"/cgi-bin/media/get", nil)
+		buf := &bytes.Buffer{}
+		_, data, err := c.Download(req, buf)
+		if err != nil {
+			t.Fatalf("%s: %v", cs.name, err)
+		}
+		got, written := string(data), buf.String()
+		if !cs.json {
+			got, written = written, got
+		}
+		if got != cs.body || written != "" {
+			t.Errorf("%s: got data %q, written %q", cs.name, data, buf.String())
+		}
+	}
+}
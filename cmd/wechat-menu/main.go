@@ -0,0 +1,282 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// wechat-menu 将多个公众号的默认菜单同步为菜单定义文件中的菜单, 只更新与定义不同的公众号:
+//
+//	wechat-menu -apps apps.yaml -menu menu.yaml -dry-run
+//
+// apps 文件为 JSON 或 YAML 格式的公众号列表, menu 为单个公众号使用的菜单定义文件, 为空时使用 -menu 指定的文件:
+//
+//	apps:
+//	  - {appid: wx1234567890}
+//	  - {appid: wx0987654321, menu: other.yaml}
+//
+// 命令默认从 -token-url 指定的令牌服务读取各公众号正在使用的 access_token, 不会重新获取令牌:
+//
+//	wechat-menu -apps apps.yaml -menu menu.yaml -token-url 'http://127.0.0.1:8080/token?appid={appid}'
+//
+// 令牌服务返回与 /cgi-bin/token 相同格式的 JSON, 即 {"access_token": "TOKEN", "expires_in": 7200}.
+// 只有指定 -new-token 时才会使用 apps 文件中的 secret 重新获取 access_token(未指定 -token-url 或读取的令牌失效时),
+// 之前获取的令牌在 5 分钟后失效, 其他使用旧令牌的服务需要能够自动刷新令牌.
+// 所有公众号均无差异或已同步时退出码为 0, 任意公众号出错时退出码为 1, -dry-run 模式下存在差异时退出码为 2.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/platform"
+	"github.com/pkg/errors"
+)
+
+type app struct {
+	Appid  string `json:"appid"`
+	Secret string `json:"secret,omitempty"`
+	Menu   string `json:"menu,omitempty"`
+}
+
+type config struct {
+	Apps []*app `json:"apps"`
+}
+
+func loadConfig(filename string) (*config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+	case ".yaml", ".yml":
+		data, err = wechat.YAMLToJSON(data)
+		if err != nil {
+			return nil, errors.Wrap(err, filename)
+		}
+	default:
+		return nil, errors.Errorf("unsupported apps file: %s", filename)
+	}
+	cfg := &config{}
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, filename)
+	}
+	for i, a := range cfg.Apps {
+		if a.Appid == "" {
+			return nil, errors.Errorf("%s: apps[%d]: appid is required", filename, i)
+		}
+	}
+	return cfg, nil
+}
+
+// 内存存储器, 令牌只在本次运行中使用
+type memoryStorage struct {
+	data   map[string]string
+	expire map[string]*platform.ExpireData
+	mu     sync.Mutex
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: make(map[string]string), expire: make(map[string]*platform.ExpireData)}
+}
+
+func (m *memoryStorage) Store(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *memoryStorage) Read(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memoryStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	delete(m.expire, key)
+	return nil
+}
+
+type memoryExpireStorage struct {
+	*memoryStorage
+}
+
+func (m memoryExpireStorage) Store(key string, data *platform.ExpireData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire[key] = data
+	return nil
+}
+
+func (m memoryExpireStorage) Read(key string) (*platform.ExpireData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expire[key], nil
+}
+
+// 令牌存储器, 优先使用本次运行中获取的令牌, 没有时从令牌服务读取, 未设置令牌服务时返回 nil
+type tokenStorage struct {
+	memoryExpireStorage
+	url    string
+	client *http.Client
+}
+
+func (ts *tokenStorage) Read(key string) (*platform.ExpireData, error) {
+	data, err := ts.memoryExpireStorage.Read(key)
+	if err != nil || data != nil || ts.url == "" {
+		return data, err
+	}
+	resp, err := ts.client.Get(strings.Replace(ts.url, "{appid}", url.QueryEscape(key), -1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s: read access token: %s", key, resp.Status)
+	}
+	res := &struct {
+		access.Token
+		wechat.Error
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(res)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: read access token", key)
+	}
+	if res.ErrCode != 0 {
+		return nil, errors.Wrapf(&res.Error, "%s: read access token", key)
+	}
+	if res.Value == "" {
+		return nil, errors.Errorf("%s: empty access token", key)
+	}
+	// 令牌服务未返回有效期时只在本次运行中使用, 失效后重新读取
+	expiresIn := time.Duration(res.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+	return &platform.ExpireData{Value: res.Value, ExpireAt: time.Now().Add(expiresIn)}, nil
+}
+
+func main() {
+	appsFile := flag.String("apps", "apps.yaml", "公众号列表文件, JSON 或 YAML 格式")
+	menuFile := flag.String("menu", "", "默认的菜单定义文件, JSON 或 YAML 格式")
+	only := flag.String("appid", "", "只同步指定的公众号, 多个 appid 以逗号分隔")
+	dryRun := flag.Bool("dry-run", false, "只显示差异, 不更新菜单")
+	timeout := flag.Duration("timeout", 5*time.Minute, "总超时时间")
+	tokenURL := flag.String("token-url", "", "读取 access_token 的令牌服务地址, {appid} 替换为公众号 appid")
+	newToken := flag.Bool("new-token", false, "允许使用 secret 重新获取 access_token, 其他服务正在使用的令牌将在 5 分钟后失效")
+	flag.Parse()
+
+	os.Exit(run(*appsFile, *menuFile, *only, *tokenURL, *newToken, *dryRun, *timeout))
+}
+
+func run(appsFile, menuFile, only, tokenURL string, newToken, dryRun bool, timeout time.Duration) int {
+	if tokenURL == "" && !newToken {
+		fmt.Fprintln(os.Stderr, "no access token source, use -token-url, or -new-token to request new tokens with secrets")
+		return 1
+	}
+	cfg, err := loadConfig(appsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	selected := make(map[string]bool)
+	for _, appid := range strings.Split(only, ",") {
+		if appid = strings.TrimSpace(appid); appid != "" {
+			selected[appid] = true
+		}
+	}
+
+	secrets := make(map[string]string, len(cfg.Apps))
+	// 按菜单文件分组, 保持公众号在文件中的顺序
+	var files []string
+	groups := make(map[string][]string)
+	for _, a := range cfg.Apps {
+		if len(selected) > 0 && !selected[a.Appid] {
+			continue
+		}
+		file := a.Menu
+		if file == "" {
+			file = menuFile
+		}
+		if file == "" {
+			fmt.Fprintf(os.Stderr, "%s: no menu file, use -menu or set menu in %s\n", a.Appid, appsFile)
+			return 1
+		}
+		if _, ok := groups[file]; !ok {
+			files = append(files, file)
+		}
+		if newToken && a.Secret == "" {
+			fmt.Fprintf(os.Stderr, "%s: no secret in %s, required by -new-token\n", a.Appid, appsFile)
+			return 1
+		}
+		groups[file] = append(groups[file], a.Appid)
+		secrets[a.Appid] = a.Secret
+	}
+	if len(secrets) == 0 {
+		fmt.Fprintln(os.Stderr, "no apps to sync")
+		return 1
+	}
+
+	ms := newMemoryStorage()
+	storage := platform.NewStorage(ms, memoryExpireStorage{ms})
+	storage.AppAccessToken = &tokenStorage{memoryExpireStorage: memoryExpireStorage{ms}, url: tokenURL, client: &http.Client{Timeout: 30 * time.Second}}
+	access := platform.NewAccessContainer(
+		storage,
+		func(appid string) (string, error) {
+			if !newToken {
+				return "", errors.Errorf("%s: access token unavailable or invalid, use -new-token to request a new one", appid)
+			}
+			if secret, ok := secrets[appid]; ok {
+				return secret, nil
+			}
+			return "", errors.Errorf("unknown appid: %s", appid)
+		},
+		func(appid string) (string, string, error) { return "", "", nil },
+		func(appid string) (string, error) { return "", nil },
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	code := 0
+	for _, file := range files {
+		desired, err := wechat.LoadMenus(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			code = 1
+			continue
+		}
+		for _, res := range access.SyncMenusContext(ctx, groups[file], desired, dryRun) {
+			switch {
+			case res.Err != nil:
+				fmt.Printf("%s: error: %v\n", res.Appid, res.Err)
+				code = 1
+			case res.Diff == "":
+				fmt.Printf("%s: up to date\n", res.Appid)
+			case res.Applied:
+				fmt.Printf("%s: updated\n%s", res.Appid, res.Diff)
+			default:
+				fmt.Printf("%s: differs (dry run)\n%s", res.Appid, res.Diff)
+				if code == 0 {
+					code = 2
+				}
+			}
+		}
+	}
+	return code
+}
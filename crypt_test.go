@@ -0,0 +1,107 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// 语料文件名对应的解密错误, 与 fuzz.go 中的模糊测试入口共用 testdata/fuzz 中的语料
+var corpusErrors = map[string]error{
+	"valid":        nil,
+	"empty":        ErrInvalidCiphertext,
+	"not-base64":   ErrInvalidCiphertext,
+	"short-block":  ErrInvalidCiphertext,
+	"padding-only": ErrInvalidMsgLength,
+	"zero-padding": ErrInvalidPadding,
+	"huge-length":  ErrInvalidMsgLength,
+}
+
+func newTestCrypt(t *testing.T) *WXBizMsgCrypt {
+	t.Helper()
+	crypt, err := NewWXBizMsgCrypt("token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", "wxfuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypt
+}
+
+// 遍历 testdata/fuzz/<name>/corpus 中的语料
+func readCorpus(t *testing.T, name string, fn func(file string, data []byte)) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "fuzz", name, "corpus", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no corpus for %s", name)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(filepath.Base(file), data)
+	}
+}
+
+func TestDecryptMsgCorpus(t *testing.T) {
+	crypt := newTestCrypt(t)
+	readCorpus(t, "DecryptMsg", func(file string, data []byte) {
+		want, ok := corpusErrors[file]
+		if !ok {
+			t.Fatalf("%s: unknown corpus", file)
+		}
+		buf := bytes.NewBufferString("<xml><Encrypt><![CDATA[")
+		buf.Write(data)
+		buf.WriteString("]]></Encrypt></xml>")
+		signature := SignParams("token", "1", "nonce", string(data))
+		msg, err := crypt.DecryptMsg(signature, "1", "nonce", buf.Bytes())
+		if err != want {
+			t.Errorf("%s: got %v, want %v", file, err, want)
+		}
+		if err == nil && len(msg) == 0 {
+			t.Errorf("%s: got empty message", file)
+		}
+	})
+
+	// 签名错误时不解密
+	_, err := crypt.DecryptMsg("bad", "1", "nonce", []byte("<xml><Encrypt><![CDATA[c2hvcnQ=]]></Encrypt></xml>"))
+	if err != ErrValidateSignature {
+		t.Errorf("got %v, want %v", err, ErrValidateSignature)
+	}
+}
+
+func TestDecryptRequestCorpus(t *testing.T) {
+	crypt := newTestCrypt(t)
+	readCorpus(t, "DecryptRequest", func(file string, data []byte) {
+		msg := &DecryptedMsg{}
+		xmlErr := xml.Unmarshal(data, msg)
+		q := url.Values{"timestamp": {"1"}, "nonce": {"nonce"}, "msg_signature": {SignParams("token", "1", "nonce", msg.Encrypt)}}
+		req := httptest.NewRequest(http.MethodPost, "/?"+q.Encode(), bytes.NewReader(data))
+		_, err := crypt.DecryptRequest(req)
+		if xmlErr != nil {
+			// 非 XML 的请求体返回解析错误
+			if err == nil {
+				t.Errorf("%s: want error", file)
+			}
+			return
+		}
+		want, ok := corpusErrors[file]
+		if !ok {
+			t.Fatalf("%s: unknown corpus", file)
+		}
+		if err != want {
+			t.Errorf("%s: got %v, want %v", file, err, want)
+		}
+	})
+}
@@ -8,7 +8,6 @@ package customer_service
 import (
 	"encoding/json"
 	"github.com/orivil/wechat"
-	"io/ioutil"
 	"net/http"
 )
 
@@ -28,34 +27,54 @@ type PostCS struct {
 
 // 创建客服
 func CreateCS(token string, cs *PostCS) error {
-	return wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/add?access_token="+token, cs, nil)
+	return CreateCSWith(wechat.DefaultClient, token, cs)
+}
+
+// CreateCSWith 同 CreateCS, 通过客户端 c 发送请求
+func CreateCSWith(c *wechat.Client, token string, cs *PostCS) error {
+	return c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/add?access_token="+token, cs, nil)
 }
 
 // 修改客服
 func UpdateCS(token string, cs *PostCS) error {
-	return wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/update?access_token="+token, cs, nil)
+	return UpdateCSWith(wechat.DefaultClient, token, cs)
+}
+
+// UpdateCSWith 同 UpdateCS, 通过客户端 c 发送请求
+func UpdateCSWith(c *wechat.Client, token string, cs *PostCS) error {
+	return c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/update?access_token="+token, cs, nil)
 }
 
 // 删除客服
 func DeleteCS(token string, cs *PostCS) error {
-	return wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/del?access_token="+token, cs, nil)
+	return DeleteCSWith(wechat.DefaultClient, token, cs)
+}
+
+// DeleteCSWith 同 DeleteCS, 通过客户端 c 发送请求
+func DeleteCSWith(c *wechat.Client, token string, cs *PostCS) error {
+	return c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/customservice/kfaccount/del?access_token="+token, cs, nil)
 }
 
 // 上传头像
 func UploadAvatar(r http.Request, token string, kfAccount string) error {
-	url := "http://api.weixin.qq.com/customservice/kfaccount/uploadheadimg"
+	return UploadAvatarWith(wechat.DefaultClient, r, token, kfAccount)
+}
+
+// UploadAvatarWith 同 UploadAvatar, 通过客户端 c 发送请求
+func UploadAvatarWith(c *wechat.Client, r http.Request, token string, kfAccount string) error {
+	url := "https://api.weixin.qq.com/customservice/kfaccount/uploadheadimg"
 	url += "?access_token=" + token + "&kf_account=" + kfAccount
 	req, err := http.NewRequest("POST", url, r.Body)
 	if err != nil {
 		return err
 	}
-	res, err := http.DefaultClient.Do(req)
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	data, err := c.Do(req)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
 	resErr := &wechat.Error{}
-	err = json.NewDecoder(res.Body).Decode(resErr)
+	err = json.Unmarshal(data, resErr)
 	if err != nil {
 		return err
 	} else {
@@ -73,13 +92,13 @@ type customerServiceResponse struct {
 
 // 获得所有客服列表
 func GetAllCS(token string) (css []*CustomerService, err error) {
+	return GetAllCSWith(wechat.DefaultClient, token)
+}
+
+// GetAllCSWith 同 GetAllCS, 通过客户端 c 发送请求
+func GetAllCSWith(c *wechat.Client, token string) (css []*CustomerService, err error) {
 	url := "https://api.weixin.qq.com/cgi-bin/customservice/getkflist?access_token=" + token
-	res, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-	data, err := ioutil.ReadAll(res.Body)
+	data, err := c.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,154 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// draft 为草稿箱接口, 草稿可通过 publish 包发布
+package draft
+
+import (
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/internal/paging"
+	"github.com/orivil/wechat/material"
+)
+
+// 批量获取草稿时每次最多获取的数量
+const MaxBatchCount = 20
+
+// 新建草稿, 返回草稿的 media_id
+func Add(articles []*material.Article, token string) (mediaID string, err error) {
+	return AddWith(wechat.DefaultClient, articles, token)
+}
+
+// AddWith 同 Add, 通过客户端 c 发送请求
+func AddWith(c *wechat.Client, articles []*material.Article, token string) (mediaID string, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/draft/add?access_token=" + token
+	res := &struct {
+		MediaID string `json:"media_id"`
+	}{}
+	err = c.PostSchema(wechat.KindJson, ul, &material.News{Articles: articles}, res)
+	if err != nil {
+		return "", err
+	} else {
+		return res.MediaID, nil
+	}
+}
+
+// 获取草稿中的文章
+func Get(mediaID, token string) (articles []*material.Article, err error) {
+	return GetWith(wechat.DefaultClient, mediaID, token)
+}
+
+// GetWith 同 Get, 通过客户端 c 发送请求
+func GetWith(c *wechat.Client, mediaID, token string) (articles []*material.Article, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/draft/get?access_token=" + token
+	res := &material.NewsArticles{}
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]string{"media_id": mediaID}, res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.NewsItem, nil
+	}
+}
+
+// 修改草稿中的一篇文章, index 为文章在草稿中的位置, 第一篇为 0
+func Update(mediaID string, index int, article *material.Article, token string) error {
+	return UpdateWith(wechat.DefaultClient, mediaID, index, article, token)
+}
+
+// UpdateWith 同 Update, 通过客户端 c 发送请求
+func UpdateWith(c *wechat.Client, mediaID string, index int, article *material.Article, token string) error {
+	ul := "https://api.weixin.qq.com/cgi-bin/draft/update?access_token=" + token
+	return c.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+		"media_id": mediaID,
+		"index":    index,
+		"articles": article,
+	}, nil)
+}
+
+// 删除草稿
+func Delete(mediaID, token string) error {
+	return DeleteWith(wechat.DefaultClient, mediaID, token)
+}
+
+// DeleteWith 同 Delete, 通过客户端 c 发送请求
+func DeleteWith(c *wechat.Client, mediaID, token string) error {
+	ul := "https://api.weixin.qq.com/cgi-bin/draft/delete?access_token=" + token
+	return c.PostSchema(wechat.KindJson, ul, map[string]string{"media_id": mediaID}, nil)
+}
+
+type List struct {
+	TotalCount int     `json:"total_count"`
+	ItemCount  int     `json:"item_count"`
+	Item       []*Item `json:"item"`
+}
+
+type Item struct {
+	MediaID    string                `json:"media_id"`
+	Content    material.NewsArticles `json:"content"`
+	UpdateTime int64                 `json:"update_time"`
+}
+
+// 获取草稿列表, count 取值 1~20. noContent 为 true 时不返回文章内容(content 字段)
+func BatchGet(offset, count int, noContent bool, token string) (list *List, err error) {
+	return BatchGetWith(wechat.DefaultClient, offset, count, noContent, token)
+}
+
+// BatchGetWith 同 BatchGet, 通过客户端 c 发送请求
+func BatchGetWith(c *wechat.Client, offset, count int, noContent bool, token string) (list *List, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/draft/batchget?access_token=" + token
+	noContentValue := 0
+	if noContent {
+		noContentValue = 1
+	}
+	list = &List{}
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]interface{}{
+		"offset":     offset,
+		"count":      count,
+		"no_content": noContentValue,
+	}, list)
+	if err != nil {
+		return nil, err
+	} else {
+		return list, nil
+	}
+}
+
+// 遍历所有草稿, 每次获取 MaxBatchCount 个
+func Walk(noContent bool, token string, fn func(item *Item) error) error {
+	return WalkWith(wechat.DefaultClient, noContent, token, fn)
+}
+
+// WalkWith 同 Walk, 通过客户端 c 发送请求
+func WalkWith(c *wechat.Client, noContent bool, token string, fn func(item *Item) error) error {
+	return paging.Walk(func(offset int) (int, int, error) {
+		list, err := BatchGetWith(c, offset, MaxBatchCount, noContent, token)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, item := range list.Item {
+			err = fn(item)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(list.Item), list.TotalCount, nil
+	})
+}
+
+// 获取草稿总数
+func Count(token string) (count int, err error) {
+	return CountWith(wechat.DefaultClient, token)
+}
+
+// CountWith 同 Count, 通过客户端 c 发送请求
+func CountWith(c *wechat.Client, token string) (count int, err error) {
+	res := &struct {
+		TotalCount int `json:"total_count"`
+	}{}
+	err = c.GetJson("https://api.weixin.qq.com/cgi-bin/draft/count?access_token="+token, res)
+	if err != nil {
+		return 0, err
+	} else {
+		return res.TotalCount, nil
+	}
+}
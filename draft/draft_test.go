@@ -0,0 +1,47 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package draft_test
+
+import (
+	"testing"
+
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/draft"
+	"github.com/orivil/wechat/material"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func TestWalk(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	c := srv.Client()
+	token, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		_, err = draft.AddWith(c, []*material.Article{{Title: "title"}}, token.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, noContent := range []bool{false, true} {
+		n := 0
+		err = draft.WalkWith(c, noContent, token.Value, func(item *draft.Item) error {
+			n++
+			if got := len(item.Content.NewsItem); noContent && got != 0 || !noContent && got != 1 {
+				t.Fatalf("noContent=%v: got %d articles", noContent, got)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 25 {
+			t.Errorf("got %d drafts, want 25", n)
+		}
+	}
+}
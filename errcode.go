@@ -0,0 +1,262 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"errors"
+	"strings"
+)
+
+// 全局返回码, see: https://developers.weixin.qq.com/doc/offiaccount/Getting_Started/Global_Return_Code.html
+const (
+	ErrCodeSystemBusy = -1 // 系统繁忙
+
+	ErrCodeInvalidAppSecret      = 40001 // AppSecret 错误或者 access_token 无效
+	ErrCodeInvalidCredentialType = 40002 // 不合法的凭证类型
+	ErrCodeInvalidOpenid         = 40003 // 不合法的 OpenID
+	ErrCodeInvalidMediaType      = 40004 // 不合法的媒体文件类型
+	ErrCodeInvalidFileType       = 40005 // 不合法的文件类型
+	ErrCodeInvalidFileSize       = 40006 // 不合法的文件大小
+	ErrCodeInvalidMediaID        = 40007 // 不合法的媒体文件 id
+	ErrCodeInvalidMsgType        = 40008 // 不合法的消息类型
+	ErrCodeInvalidAppid          = 40013 // 不合法的 AppID
+	ErrCodeInvalidAccessToken    = 40014 // 不合法的 access_token
+	ErrCodeInvalidMenuType       = 40015 // 不合法的菜单类型
+	ErrCodeInvalidButtonCount    = 40016 // 不合法的按钮个数
+	ErrCodeInvalidButtonName     = 40018 // 不合法的按钮名字长度
+	ErrCodeInvalidButtonKey      = 40019 // 不合法的按钮 KEY 长度
+	ErrCodeInvalidButtonURL      = 40020 // 不合法的按钮 URL 长度
+	ErrCodeInvalidSubButtonCount = 40023 // 不合法的子菜单按钮个数
+	ErrCodeInvalidOAuthCode      = 40029 // 不合法的 oauth_code
+	ErrCodeInvalidRefreshToken   = 40030 // 不合法的 refresh_token
+	ErrCodeInvalidTemplateSize   = 40036 // 不合法的 template_id 长度
+	ErrCodeInvalidTemplateID     = 40037 // 不合法的 template_id
+	ErrCodeInvalidURLDomain      = 40048 // 无效的 url
+	ErrCodeInvalidAppSecretKey   = 40125 // 无效的 appsecret
+	ErrCodeOAuthCodeUsed         = 40163 // oauth_code 已使用
+	ErrCodeIPNotWhitelisted      = 40164 // 调用接口的 IP 地址不在白名单中
+
+	ErrCodeMissingAccessToken = 41001 // 缺少 access_token 参数
+	ErrCodeMissingAppid       = 41002 // 缺少 appid 参数
+	ErrCodeMissingOAuthCode   = 41008 // 缺少 oauth code
+	ErrCodeMissingOpenid      = 41009 // 缺少 openid
+
+	ErrCodeAccessTokenExpired  = 42001 // access_token 超时
+	ErrCodeRefreshTokenExpired = 42002 // refresh_token 超时
+	ErrCodeOAuthCodeExpired    = 42003 // oauth_code 超时
+	ErrCodeUserChangedPassword = 42007 // 用户修改微信密码, accesstoken 和 refreshtoken 失效, 需要重新授权
+
+	ErrCodeRequireGET       = 43001 // 需要 GET 请求
+	ErrCodeRequirePOST      = 43002 // 需要 POST 请求
+	ErrCodeRequireHTTPS     = 43003 // 需要 HTTPS 请求
+	ErrCodeRequireSubscribe = 43004 // 需要接收者关注
+	ErrCodeRequireFriend    = 43005 // 需要好友关系
+	ErrCodeUserInBlacklist  = 43019 // 需要将接收者从黑名单中移除
+
+	ErrCodeEmptyMediaData = 44001 // 多媒体文件为空
+	ErrCodeEmptyPostData  = 44002 // POST 的数据包为空
+
+	ErrCodeMediaSizeOutOfLimit     = 45001 // 多媒体文件大小超过限制
+	ErrCodeContentSizeOutOfLimit   = 45002 // 消息内容超过限制
+	ErrCodeAPIDailyQuota           = 45009 // 接口调用超过限制
+	ErrCodeAPIMinuteQuota          = 45011 // API 调用太频繁, 请稍候再试
+	ErrCodeResponseOutOfTime       = 45015 // 回复时间超过限制
+	ErrCodeCustomerMsgOutOfLimit   = 45047 // 客服接口下行条数超过上限
+	ErrCodeGroupMsgAlreadySent     = 45065 // 相同 clientmsgid 已存在群发记录
+	ErrCodeGroupMsgSendTooFast     = 45066 // 相同 clientmsgid 重试速度过快
+	ErrCodeGroupMsgClientIDTooLong = 45067 // clientmsgid 长度超过限制
+
+	ErrCodeMediaNotExist = 46001 // 不存在媒体数据
+	ErrCodeMenuNotExist  = 46003 // 不存在的菜单数据
+	ErrCodeUserNotExist  = 46004 // 不存在的用户
+
+	ErrCodeInvalidJSON = 47001 // 解析 JSON/XML 内容错误
+
+	ErrCodeAPIUnauthorized        = 48001 // api 功能未授权
+	ErrCodeUserRefuseMessage      = 48002 // 粉丝拒收消息
+	ErrCodeAPIBlocked             = 48004 // api 接口被封禁
+	ErrCodeMaterialReferenced     = 48005 // api 禁止删除被自动回复和自定义菜单引用的素材
+	ErrCodeClearQuotaOutOfLimit   = 48006 // api 禁止清零调用次数, 因为清零次数达到上限
+	ErrCodeNoPermissionForMsgType = 48008 // 没有该类型消息的发送权限
+
+	ErrCodeUserUnauthorized = 50001 // 用户未授权该 api
+	ErrCodeUserLimited      = 50002 // 用户受限, 可能是违规后接口被封禁
+)
+
+// ErrCategory 是错误码的分类, 一个错误码可以同时属于多个分类.
+// ErrCategory 实现了 error 接口, 可通过 errors.Is(err, wechat.ErrCategoryRetryable) 判断错误所属分类.
+type ErrCategory uint
+
+const (
+	// 可立即重试的错误, 如系统繁忙, 默认重试策略 ShouldRetry 只重试这类错误.
+	// 需要等待较长时间才能重试的错误(如 45011, 45066)不属于该分类
+	ErrCategoryRetryable ErrCategory = 1 << iota
+
+	// 令牌失效, 需要刷新 access token 后重试
+	ErrCategoryTokenInvalid
+
+	// 超出调用频率或每日调用次数限制
+	ErrCategoryQuotaExceeded
+
+	// 接口未授权或被封禁
+	ErrCategoryPermissionDenied
+
+	// 由用户状态导致的错误, 如未关注, 拒收消息, 超过回复时限等
+	ErrCategoryUserState
+)
+
+var categoryNames = []struct {
+	category ErrCategory
+	name     string
+}{
+	{ErrCategoryRetryable, "retryable"},
+	{ErrCategoryTokenInvalid, "token-invalid"},
+	{ErrCategoryQuotaExceeded, "quota-exceeded"},
+	{ErrCategoryPermissionDenied, "permission-denied"},
+	{ErrCategoryUserState, "user-state"},
+}
+
+func (ec ErrCategory) Error() string {
+	var names []string
+	for _, cn := range categoryNames {
+		if ec&cn.category != 0 {
+			names = append(names, cn.name)
+		}
+	}
+	return "wechat error category: " + strings.Join(names, "|")
+}
+
+type errCodeInfo struct {
+	category ErrCategory
+	zh       string
+	en       string
+}
+
+var errCodes = map[int]errCodeInfo{
+	ErrCodeSystemBusy: {ErrCategoryRetryable, "系统繁忙，此时请开发者稍候再试", "system is busy, please try again later"},
+
+	ErrCodeInvalidAppSecret:      {ErrCategoryTokenInvalid, "获取 access_token 时 AppSecret 错误，或者 access_token 无效", "invalid credential, access_token is invalid or not latest"},
+	ErrCodeInvalidCredentialType: {0, "不合法的凭证类型", "invalid credential type"},
+	ErrCodeInvalidOpenid:         {0, "不合法的 OpenID", "invalid openid"},
+	ErrCodeInvalidMediaType:      {0, "不合法的媒体文件类型", "invalid media type"},
+	ErrCodeInvalidFileType:       {0, "不合法的文件类型", "invalid file type"},
+	ErrCodeInvalidFileSize:       {0, "不合法的文件大小", "invalid file size"},
+	ErrCodeInvalidMediaID:        {0, "不合法的媒体文件 id", "invalid media_id"},
+	ErrCodeInvalidMsgType:        {0, "不合法的消息类型", "invalid message type"},
+	ErrCodeInvalidAppid:          {0, "不合法的 AppID", "invalid appid"},
+	ErrCodeInvalidAccessToken:    {ErrCategoryTokenInvalid, "不合法的 access_token", "invalid access_token"},
+	ErrCodeInvalidMenuType:       {0, "不合法的菜单类型", "invalid menu type"},
+	ErrCodeInvalidButtonCount:    {0, "不合法的按钮个数", "invalid button size"},
+	ErrCodeInvalidButtonName:     {0, "不合法的按钮名字长度", "invalid button name size"},
+	ErrCodeInvalidButtonKey:      {0, "不合法的按钮 KEY 长度", "invalid button key size"},
+	ErrCodeInvalidButtonURL:      {0, "不合法的按钮 URL 长度", "invalid button url size"},
+	ErrCodeInvalidSubButtonCount: {0, "不合法的子菜单按钮个数", "invalid sub button size"},
+	ErrCodeInvalidOAuthCode:      {0, "不合法的 oauth_code", "invalid code"},
+	ErrCodeInvalidRefreshToken:   {0, "不合法的 refresh_token", "invalid refresh_token"},
+	ErrCodeInvalidTemplateSize:   {0, "不合法的 template_id 长度", "invalid template_id size"},
+	ErrCodeInvalidTemplateID:     {0, "不合法的 template_id", "invalid template_id"},
+	ErrCodeInvalidURLDomain:      {0, "无效的 url", "invalid url domain"},
+	ErrCodeInvalidAppSecretKey:   {0, "无效的 appsecret", "invalid appsecret"},
+	ErrCodeOAuthCodeUsed:         {0, "oauth_code 已使用", "code been used"},
+	ErrCodeIPNotWhitelisted:      {ErrCategoryPermissionDenied, "调用接口的 IP 地址不在白名单中", "invalid ip, not in whitelist"},
+
+	ErrCodeMissingAccessToken: {0, "缺少 access_token 参数", "access_token missing"},
+	ErrCodeMissingAppid:       {0, "缺少 appid 参数", "appid missing"},
+	ErrCodeMissingOAuthCode:   {0, "缺少 oauth code", "code missing"},
+	ErrCodeMissingOpenid:      {0, "缺少 openid", "openid missing"},
+
+	ErrCodeAccessTokenExpired:  {ErrCategoryTokenInvalid, "access_token 超时", "access_token expired"},
+	ErrCodeRefreshTokenExpired: {ErrCategoryUserState, "refresh_token 超时", "refresh_token expired"},
+	ErrCodeOAuthCodeExpired:    {0, "oauth_code 超时", "code expired"},
+	ErrCodeUserChangedPassword: {ErrCategoryUserState, "用户修改微信密码，accesstoken 和 refreshtoken 失效，需要重新授权", "user changed password, access_token and refresh_token are invalid"},
+
+	ErrCodeRequireGET:       {0, "需要 GET 请求", "require GET method"},
+	ErrCodeRequirePOST:      {0, "需要 POST 请求", "require POST method"},
+	ErrCodeRequireHTTPS:     {0, "需要 HTTPS 请求", "require https"},
+	ErrCodeRequireSubscribe: {ErrCategoryUserState, "需要接收者关注", "require subscribe"},
+	ErrCodeRequireFriend:    {ErrCategoryUserState, "需要好友关系", "require friend relations"},
+	ErrCodeUserInBlacklist:  {ErrCategoryUserState, "需要将接收者从黑名单中移除", "user is in blacklist"},
+
+	ErrCodeEmptyMediaData: {0, "多媒体文件为空", "empty media data"},
+	ErrCodeEmptyPostData:  {0, "POST 的数据包为空", "empty post data"},
+
+	ErrCodeMediaSizeOutOfLimit:     {0, "多媒体文件大小超过限制", "media size out of limit"},
+	ErrCodeContentSizeOutOfLimit:   {0, "消息内容超过限制", "content size out of limit"},
+	ErrCodeAPIDailyQuota:           {ErrCategoryQuotaExceeded, "接口调用超过每日限制", "reach max api daily quota limit"},
+	ErrCodeAPIMinuteQuota:          {ErrCategoryQuotaExceeded, "API 调用太频繁，请稍候再试", "api minute-quota reach limit, must slower, retry next minute"},
+	ErrCodeResponseOutOfTime:       {ErrCategoryUserState, "回复时间超过限制", "response out of time limit"},
+	ErrCodeCustomerMsgOutOfLimit:   {ErrCategoryUserState, "客服接口下行条数超过上限", "customer message out of limit"},
+	ErrCodeGroupMsgAlreadySent:     {0, "相同 clientmsgid 已存在群发记录，返回数据中带有已存在的群发任务的 msgid", "clientmsgid already exists, msgid of the existing task is returned"},
+	ErrCodeGroupMsgSendTooFast:     {0, "相同 clientmsgid 重试速度过快，请间隔1分钟重试", "clientmsgid retried too fast, retry after 1 minute"},
+	ErrCodeGroupMsgClientIDTooLong: {0, "clientmsgid 长度超过限制", "clientmsgid is too long"},
+
+	ErrCodeMediaNotExist: {0, "不存在媒体数据，media_id 不存在或已过期", "media data not exist"},
+	ErrCodeMenuNotExist:  {0, "不存在的菜单数据", "menu not exist"},
+	ErrCodeUserNotExist:  {0, "不存在的用户", "user not exist"},
+
+	ErrCodeInvalidJSON: {0, "解析 JSON/XML 内容错误", "data format error"},
+
+	ErrCodeAPIUnauthorized:        {ErrCategoryPermissionDenied, "api 功能未授权，请确认公众号已获得该接口", "api unauthorized"},
+	ErrCodeUserRefuseMessage:      {ErrCategoryPermissionDenied | ErrCategoryUserState, "粉丝拒收消息（粉丝在公众号选项中，关闭了“接收消息”）", "user refuses to receive messages"},
+	ErrCodeAPIBlocked:             {ErrCategoryPermissionDenied, "api 接口被封禁，请登录 mp.weixin.qq.com 查看详情", "api forbidden"},
+	ErrCodeMaterialReferenced:     {ErrCategoryPermissionDenied, "api 禁止删除被自动回复和自定义菜单引用的素材", "forbid to delete material used by auto-reply or menu"},
+	ErrCodeClearQuotaOutOfLimit:   {ErrCategoryPermissionDenied, "api 禁止清零调用次数，因为清零次数达到上限", "forbid to clear quota because of reaching the limit"},
+	ErrCodeNoPermissionForMsgType: {ErrCategoryPermissionDenied, "没有该类型消息的发送权限", "no permission to send this type of message"},
+
+	ErrCodeUserUnauthorized: {ErrCategoryPermissionDenied | ErrCategoryUserState, "用户未授权该 api", "user unauthorized"},
+	ErrCodeUserLimited:      {ErrCategoryPermissionDenied | ErrCategoryUserState, "用户受限，可能是违规后接口被封禁", "user limited"},
+}
+
+// ErrCodeCategory 返回错误码所属分类, 未收录的错误码返回 0
+func ErrCodeCategory(code int) ErrCategory {
+	return errCodes[code].category
+}
+
+// ErrCodeText 返回错误码的说明, lang 为 "en" 时返回英文说明, 否则返回中文说明.
+// 未收录的错误码返回空字符串.
+func ErrCodeText(code int, lang string) string {
+	info, ok := errCodes[code]
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(lang, "en") {
+		return info.en
+	}
+	return info.zh
+}
+
+// ErrCodeOf 返回错误链中的微信错误码
+func ErrCodeOf(err error) (code int, ok bool) {
+	var we *Error
+	if errors.As(err, &we) {
+		return we.ErrCode, true
+	}
+	return 0, false
+}
+
+// 是否可立即重试的错误, 如系统繁忙
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrCategoryRetryable)
+}
+
+// 是否令牌失效错误
+func IsTokenInvalid(err error) bool {
+	return errors.Is(err, ErrCategoryTokenInvalid)
+}
+
+// 是否超出调用频率或调用次数限制
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrCategoryQuotaExceeded)
+}
+
+// 是否接口未授权或被封禁
+func IsPermissionDenied(err error) bool {
+	return errors.Is(err, ErrCategoryPermissionDenied)
+}
+
+// 是否由用户状态导致的错误
+func IsUserState(err error) bool {
+	return errors.Is(err, ErrCategoryUserState)
+}
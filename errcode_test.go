@@ -0,0 +1,150 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestErrCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		ok   bool
+	}{
+		{nil, 0, false},
+		{errors.New("network error"), 0, false},
+		{&Error{ErrCode: ErrCodeInvalidAppSecret}, ErrCodeInvalidAppSecret, true},
+		{fmt.Errorf("get menus: %w", &Error{ErrCode: ErrCodeMenuNotExist}), ErrCodeMenuNotExist, true},
+		{pkgerrors.Wrap(&Error{ErrCode: ErrCodeSystemBusy}, "get menus"), ErrCodeSystemBusy, true},
+		{&QuotaError{Appid: "wx1"}, 0, false},
+	}
+	for _, tt := range tests {
+		code, ok := ErrCodeOf(tt.err)
+		if code != tt.code || ok != tt.ok {
+			t.Errorf("%v: got %d %v, want %d %v", tt.err, code, ok, tt.code, tt.ok)
+		}
+	}
+}
+
+func TestErrorIsCategory(t *testing.T) {
+	tests := []struct {
+		code     int
+		category ErrCategory
+	}{
+		{ErrCodeSystemBusy, ErrCategoryRetryable},
+		{ErrCodeInvalidAppSecret, ErrCategoryTokenInvalid},
+		{ErrCodeInvalidAccessToken, ErrCategoryTokenInvalid},
+		{ErrCodeAccessTokenExpired, ErrCategoryTokenInvalid},
+		{ErrCodeAPIDailyQuota, ErrCategoryQuotaExceeded},
+		{ErrCodeAPIMinuteQuota, ErrCategoryQuotaExceeded},
+		{ErrCodeGroupMsgSendTooFast, 0},
+		{ErrCodeIPNotWhitelisted, ErrCategoryPermissionDenied},
+		{ErrCodeAPIUnauthorized, ErrCategoryPermissionDenied},
+		{ErrCodeRequireSubscribe, ErrCategoryUserState},
+		{ErrCodeResponseOutOfTime, ErrCategoryUserState},
+		{ErrCodeUserRefuseMessage, ErrCategoryPermissionDenied | ErrCategoryUserState},
+		{ErrCodeUserLimited, ErrCategoryPermissionDenied | ErrCategoryUserState},
+		{ErrCodeMenuNotExist, 0},
+		{99999, 0},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("call: %w", &Error{ErrCode: tt.code})
+		if got := (&Error{ErrCode: tt.code}).Category(); got != tt.category {
+			t.Errorf("%d: got category %b, want %b", tt.code, got, tt.category)
+		}
+		for _, cn := range categoryNames {
+			want := tt.category&cn.category != 0
+			if got := errors.Is(err, cn.category); got != want {
+				t.Errorf("%d: errors.Is(%s) got %v, want %v", tt.code, cn.name, got, want)
+			}
+		}
+		// 组合分类要求同时属于所有分类, 0 不匹配任何错误
+		if tt.category != 0 && !errors.Is(err, tt.category) {
+			t.Errorf("%d: want errors.Is(%s)", tt.code, tt.category)
+		}
+		if errors.Is(err, ErrCategory(0)) {
+			t.Errorf("%d: errors.Is(0) should be false", tt.code)
+		}
+	}
+	if errors.Is(&Error{ErrCode: ErrCodeAPIUnauthorized}, ErrCategoryPermissionDenied|ErrCategoryUserState) {
+		t.Error("48001 should not match user-state")
+	}
+	if !errors.Is(&Error{ErrCode: ErrCodeMenuNotExist}, &Error{ErrCode: ErrCodeMenuNotExist}) {
+		t.Error("errors with the same code should match")
+	}
+}
+
+func TestIsCategory(t *testing.T) {
+	tests := []struct {
+		err                                error
+		retryable, tokenInvalid, overQuota bool
+	}{
+		{nil, false, false, false},
+		{errors.New("network error"), false, false, false},
+		{&Error{ErrCode: ErrCodeSystemBusy}, true, false, false},
+		{&Error{ErrCode: ErrCodeInvalidAppSecret}, false, true, false},
+		{&Error{ErrCode: ErrCodeAccessTokenExpired}, false, true, false},
+		{pkgerrors.Wrap(&Error{ErrCode: ErrCodeInvalidAccessToken}, "call"), false, true, false},
+		{&Error{ErrCode: ErrCodeAPIDailyQuota}, false, false, true},
+		{&QuotaError{Appid: "wx1"}, false, false, true},
+		{&Error{ErrCode: ErrCodeMenuNotExist}, false, false, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("IsRetryable(%v) = %v", tt.err, got)
+		}
+		if got := IsTokenInvalid(tt.err); got != tt.tokenInvalid {
+			t.Errorf("IsTokenInvalid(%v) = %v", tt.err, got)
+		}
+		if got := IsQuotaExceeded(tt.err); got != tt.overQuota {
+			t.Errorf("IsQuotaExceeded(%v) = %v", tt.err, got)
+		}
+	}
+}
+
+// 默认重试策略与 ErrCategoryRetryable 保持一致
+func TestShouldRetryCategory(t *testing.T) {
+	for code := range errCodes {
+		a := &Attempt{Endpoint: "/cgi-bin/menu/get", Idempotent: true, StatusCode: 200,
+			Data: []byte(`{"errcode":` + strconv.Itoa(code) + `,"errmsg":"error"}`)}
+		if got, want := ShouldRetry(a), ErrCodeCategory(code)&ErrCategoryRetryable != 0; got != want {
+			t.Errorf("%d: ShouldRetry got %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestErrCodeText(t *testing.T) {
+	tests := []struct {
+		code int
+		lang string
+		text string
+	}{
+		{ErrCodeSystemBusy, "zh", "系统繁忙，此时请开发者稍候再试"},
+		{ErrCodeSystemBusy, "", "系统繁忙，此时请开发者稍候再试"},
+		{ErrCodeSystemBusy, "en", "system is busy, please try again later"},
+		{ErrCodeSystemBusy, "en-US", "system is busy, please try again later"},
+		{ErrCodeMenuNotExist, "zh-CN", "不存在的菜单数据"},
+		{ErrCodeMenuNotExist, "en", "menu not exist"},
+		{99999, "zh", ""},
+		{99999, "en", ""},
+	}
+	for _, tt := range tests {
+		if got := ErrCodeText(tt.code, tt.lang); got != tt.text {
+			t.Errorf("%d %q: got %q, want %q", tt.code, tt.lang, got, tt.text)
+		}
+	}
+	// 所有收录的错误码都有中英文说明
+	for code, info := range errCodes {
+		if info.zh == "" || info.en == "" {
+			t.Errorf("%d: missing text", code)
+		}
+	}
+}
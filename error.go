@@ -14,3 +14,30 @@ type Error struct {
 func (we *Error) Error() string {
 	return fmt.Sprintf("code:[%d] error:[%s]", we.ErrCode, we.ErrMsg)
 }
+
+// Is 用于支持 errors.Is, target 可以是相同错误码的 *Error, 也可以是 ErrCategory, 如:
+//
+//	errors.Is(err, &wechat.Error{ErrCode: wechat.ErrCodeAPIDailyQuota})
+//	errors.Is(err, wechat.ErrCategoryQuotaExceeded)
+func (we *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *Error:
+		return t != nil && t.ErrCode == we.ErrCode
+	case ErrCategory:
+		return t != 0 && ErrCodeCategory(we.ErrCode)&t == t
+	}
+	return false
+}
+
+// 错误码所属分类
+func (we *Error) Category() ErrCategory {
+	return ErrCodeCategory(we.ErrCode)
+}
+
+// 错误码说明, lang 为 "en" 时返回英文说明, 否则返回中文说明. 未收录的错误码返回 ErrMsg.
+func (we *Error) Text(lang string) string {
+	if text := ErrCodeText(we.ErrCode, lang); text != "" {
+		return text
+	}
+	return we.ErrMsg
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+//go:build gofuzz
+// +build gofuzz
+
+package wechat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// 模糊测试入口, 用于证明任意输入都不会导致解密时 panic. 使用 go-fuzz 运行:
+//
+//	go-fuzz-build -func FuzzDecryptMsg github.com/orivil/wechat
+//	go-fuzz -bin wechat-fuzz.zip -workdir testdata/fuzz/DecryptMsg
+//
+// testdata/fuzz/DecryptMsg 及 testdata/fuzz/DecryptRequest 中分别保存了两个入口的初始语料,
+// 包括合法密文、非 base64 数据、长度不足一个块、补位错误及消息长度越界等情况.
+
+var fuzzCrypt *WXBizMsgCrypt
+
+func init() {
+	var err error
+	fuzzCrypt, err = NewWXBizMsgCrypt("token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", "wxfuzz")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// FuzzDecryptMsg 将 data 作为 Encrypt 字段的值, 以正确的签名调用 DecryptMsg, 使输入能够到达解密流程
+func FuzzDecryptMsg(data []byte) int {
+	buf := bytes.NewBufferString("<xml><Encrypt><![CDATA[")
+	buf.Write(data)
+	buf.WriteString("]]></Encrypt></xml>")
+	signature := SignParams("token", "1", "nonce", string(data))
+	_, err := fuzzCrypt.DecryptMsg(signature, "1", "nonce", buf.Bytes())
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+// FuzzDecryptRequest 将 data 作为请求体调用 DecryptRequest, 签名按请求体中的 Encrypt 字段计算
+func FuzzDecryptRequest(data []byte) int {
+	msg := &DecryptedMsg{}
+	_ = xml.Unmarshal(data, msg)
+	q := url.Values{"timestamp": {"1"}, "nonce": {"nonce"}, "msg_signature": {SignParams("token", "1", "nonce", msg.Encrypt)}}
+	req := httptest.NewRequest(http.MethodPost, "/?"+q.Encode(), bytes.NewReader(data))
+	_, err := fuzzCrypt.DecryptRequest(req)
+	if err != nil {
+		return 0
+	}
+	return 1
+}
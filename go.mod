@@ -6,4 +6,6 @@ require (
 	github.com/google/go-querystring v1.0.0
 	github.com/orivil/utils v0.0.0-20200310053055-00f8e83cbd0b
 	github.com/pkg/errors v0.9.1
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -9,7 +9,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
-	"io/ioutil"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -18,30 +18,29 @@ import (
 var ErrResponseIsNil = errors.New("the response schema is nil")
 
 func GetJson(Url string, response interface{}) (err error) {
-	var data []byte
+	return DefaultClient.GetJson(Url, response)
+}
+
+func (c *Client) GetJson(Url string, response interface{}) (err error) {
 	if response == nil {
 		return ErrResponseIsNil
 	}
-	resp, err := http.Get(Url)
+	data, err := c.Get(Url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	data, err = ioutil.ReadAll(resp.Body)
+	err = ParseError(data)
 	if err != nil {
 		return err
 	}
-	if bytes.Contains(data, []byte("errcode")) {
-		var werr = &Error{}
-		_ = json.Unmarshal(data, werr)
-		if werr.ErrCode != 0 {
-			return werr
-		}
-	}
 	return json.Unmarshal(data, response)
 }
 
 func PostSchema(kind cryptKind, url string, schema, response interface{}) error {
+	return DefaultClient.PostSchema(kind, url, schema, response)
+}
+
+func (c *Client) PostSchema(kind cryptKind, url string, schema, response interface{}) error {
 	schemaBuf := bytes.NewBuffer(nil)
 	var encoder encoder
 	switch kind {
@@ -57,7 +56,7 @@ func PostSchema(kind cryptKind, url string, schema, response interface{}) error
 	if err != nil {
 		return err
 	} else {
-		return PostData(kind, url, schemaBuf.Bytes(), response)
+		return c.PostData(kind, url, schemaBuf.Bytes(), response)
 	}
 }
 
@@ -79,6 +78,10 @@ type encoder interface {
 }
 
 func PostData(kind cryptKind, url string, data []byte, response interface{}) error {
+	return DefaultClient.PostData(kind, url, data, response)
+}
+
+func (c *Client) PostData(kind cryptKind, url string, data []byte, response interface{}) error {
 	var contentType string
 	var resDecoder func(data []byte) decoder
 	switch kind {
@@ -93,12 +96,7 @@ func PostData(kind cryptKind, url string, data []byte, response interface{}) err
 			return xml.NewDecoder(bytes.NewReader(data))
 		}
 	}
-	resp, err := http.Post(url, contentType, bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	data, err = ioutil.ReadAll(resp.Body)
+	data, err := c.Post(url, contentType, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
@@ -118,46 +116,81 @@ func PostData(kind cryptKind, url string, data []byte, response interface{}) err
 }
 
 func UploadFile(uri string, data []byte, fieldName, fileName string, values url.Values, res interface{}) error {
-	buf := &bytes.Buffer{}
-	mulWriter := multipart.NewWriter(buf)
-	fileWriter, err := mulWriter.CreateFormFile(fieldName, fileName)
+	return DefaultClient.UploadFile(uri, data, fieldName, fileName, values, res)
+}
+
+func (c *Client) UploadFile(uri string, data []byte, fieldName, fileName string, values url.Values, res interface{}) error {
+	return c.UploadReader(uri, bytes.NewReader(data), int64(len(data)), fieldName, fileName, values, res)
+}
+
+func UploadReader(uri string, r io.Reader, size int64, fieldName, fileName string, values url.Values, res interface{}) error {
+	return DefaultClient.UploadReader(uri, r, size, fieldName, fileName, values, res)
+}
+
+// UploadReader 同 UploadFile, 文件数据从 r 读取并通过 io.Pipe 边读边上传, 不会将整个文件读入内存.
+// size 为文件大小, 用于设置请求的 Content-Length, 未知时为 -1, 此时使用分块传输.
+// 请求体无法重复读取, 因此失败时不会重试
+func (c *Client) UploadReader(uri string, r io.Reader, size int64, fieldName, fileName string, values url.Values, res interface{}) error {
+	pr, pw := io.Pipe()
+	mulWriter := multipart.NewWriter(pw)
+	req, err := http.NewRequest(http.MethodPost, uri, pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mulWriter.FormDataContentType())
+	if size >= 0 {
+		req.ContentLength, err = multipartLength(mulWriter.Boundary(), size, fieldName, fileName, values)
+		if err != nil {
+			return err
+		}
+	}
+	go func() {
+		pw.CloseWithError(writeMultipart(mulWriter, r, fieldName, fileName, values))
+	}()
+	data, err := c.Do(req)
+	// 请求提前结束时使写入方退出
+	_ = pr.Close()
 	if err != nil {
 		return err
 	}
-	_, err = fileWriter.Write(data)
+	err = ParseError(data)
 	if err != nil {
 		return err
 	}
+	return json.Unmarshal(data, res)
+}
+
+// 计算文件大小为 size 时 multipart 请求体的长度
+func multipartLength(boundary string, size int64, fieldName, fileName string, values url.Values) (int64, error) {
+	buf := &bytes.Buffer{}
+	mulWriter := multipart.NewWriter(buf)
+	err := mulWriter.SetBoundary(boundary)
+	if err != nil {
+		return 0, err
+	}
+	err = writeMultipart(mulWriter, bytes.NewReader(nil), fieldName, fileName, values)
+	if err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()) + size, nil
+}
+
+func writeMultipart(mulWriter *multipart.Writer, r io.Reader, fieldName, fileName string, values url.Values) error {
 	for key, vs := range values {
 		for _, value := range vs {
-			partWriter, err := mulWriter.CreateFormField(key)
+			err := mulWriter.WriteField(key, value)
 			if err != nil {
 				return err
-			} else {
-				_, err = partWriter.Write([]byte(value))
-				if err != nil {
-					return err
-				}
 			}
 		}
 	}
-	contentType := mulWriter.FormDataContentType()
-	_ = mulWriter.Close()
-	resp, err := http.Post(uri, contentType, buf)
+	fileWriter, err := mulWriter.CreateFormFile(fieldName, fileName)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	data, err = ioutil.ReadAll(resp.Body)
+	_, err = io.Copy(fileWriter, r)
 	if err != nil {
 		return err
 	}
-	if bytes.Contains(data, []byte("errcode")) {
-		e := &Error{}
-		_ = json.Unmarshal(data, e)
-		if e.ErrCode != 0 {
-			return e
-		}
-	}
-	return json.Unmarshal(data, res)
+	return mulWriter.Close()
 }
@@ -0,0 +1,192 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Call 描述一次接口调用, 由拦截器链传递.
+// 调用 next 之前只有请求相关的字段可用, next 返回后响应相关的字段才会被填充.
+type Call struct {
+	// 请求所使用的 context, 拦截器可以替换(如附加链路追踪的 span), 替换后的值将用于实际请求
+	Context context.Context
+
+	// 发起请求的公众号 appid, 未绑定时为空, 见 Client.WithAppid
+	Appid string
+
+	// 接口路径, 如 "/cgi-bin/message/custom/send"
+	Endpoint string
+
+	Method string
+
+	// 完整请求地址, 其中包含 access_token 等敏感参数, 输出前应使用 RedactURL 处理
+	URL string
+
+	// 请求头, 拦截器可以写入(如注入链路追踪信息)
+	Header http.Header
+
+	// 请求数据, 无法重复读取的请求体为 nil
+	RequestBody []byte
+
+	// HTTP 状态码, 网络错误时为 0
+	StatusCode int
+
+	ResponseBody []byte
+
+	// 响应数据中的 errcode
+	ErrCode int
+
+	// 网络或限流等错误, 不包含 errcode 错误
+	Err error
+
+	// 从发送请求到读取完响应数据的耗时, 包含重试及限流等待时间
+	Latency time.Duration
+
+	// 实际发送次数
+	Attempts int
+}
+
+// Invoker 执行接口调用
+type Invoker func(call *Call) error
+
+// Interceptor 拦截接口调用, 可在调用 next 前后处理, 也可以不调用 next 而直接返回错误.
+// 多个拦截器按 Client.Interceptors 中的顺序由外向内包裹.
+type Interceptor func(call *Call, next Invoker) error
+
+func newCall(appid, endpoint string, req *http.Request) *Call {
+	call := &Call{
+		Context:  req.Context(),
+		Appid:    appid,
+		Endpoint: endpoint,
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Header:   req.Header,
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			call.RequestBody, _ = ioutil.ReadAll(body)
+			_ = body.Close()
+		}
+	}
+	return call
+}
+
+func (c *Client) intercept(call *Call, invoker Invoker) error {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.Interceptors[i], invoker
+		invoker = func(call *Call) error {
+			return interceptor(call, next)
+		}
+	}
+	return invoker(call)
+}
+
+// 需要隐藏的敏感参数
+var redactKeys = []string{
+	"access_token",
+	"component_access_token",
+	"authorizer_access_token",
+	"refresh_token",
+	"authorizer_refresh_token",
+	"secret",
+	"appsecret",
+	"component_appsecret",
+	"component_verify_ticket",
+	"ticket",
+	"code",
+}
+
+var redactJSON = regexp.MustCompile(`("(?:` + strings.Join(redactKeys, "|") + `)"\s*:\s*)"[^"]*"`)
+
+// RedactURL 将地址中的 access_token 等敏感参数替换为 "***"
+func RedactURL(rawurl string) string {
+	i := strings.IndexByte(rawurl, '?')
+	if i < 0 {
+		return rawurl
+	}
+	params := strings.Split(rawurl[i+1:], "&")
+	for j, param := range params {
+		key := param
+		if k := strings.IndexByte(param, '='); k >= 0 {
+			key = param[:k]
+		}
+		for _, redactKey := range redactKeys {
+			if key == redactKey {
+				params[j] = key + "=***"
+				break
+			}
+		}
+	}
+	return rawurl[:i+1] + strings.Join(params, "&")
+}
+
+// RedactBody 将 json 数据中的 access_token、appsecret 等敏感字段值替换为 "***"
+func RedactBody(data []byte) []byte {
+	return redactJSON.ReplaceAll(data, []byte(`$1"***"`))
+}
+
+// LogFunc 输出结构化日志, keyvals 为键值对, 如 "appid", "wx123", "latency", time.Second
+type LogFunc func(msg string, keyvals ...interface{})
+
+// LogInterceptor 返回记录每次接口调用的拦截器, 地址及数据中的敏感参数均已隐藏.
+// withBody 为 true 时同时记录请求及响应数据.
+func LogInterceptor(log LogFunc, withBody bool) Interceptor {
+	return func(call *Call, next Invoker) error {
+		err := next(call)
+		keyvals := []interface{}{
+			"appid", call.Appid,
+			"endpoint", call.Endpoint,
+			"method", call.Method,
+			"url", RedactURL(call.URL),
+			"status", call.StatusCode,
+			"errcode", call.ErrCode,
+			"attempts", call.Attempts,
+			"latency", call.Latency,
+		}
+		if withBody {
+			keyvals = append(keyvals,
+				"request", string(RedactBody(call.RequestBody)),
+				"response", string(RedactBody(call.ResponseBody)),
+			)
+		}
+		if err != nil {
+			keyvals = append(keyvals, "error", err.Error())
+		}
+		log("wechat api call", keyvals...)
+		return err
+	}
+}
+
+// TraceInterceptor 返回用于链路追踪的拦截器, 便于接入 OpenTelemetry 等追踪系统.
+// start 在请求发送前调用, 可返回包含 span 的 ctx 并向 call.Header 注入追踪信息,
+// 返回的 end 函数在请求完成后调用, 可在其中根据 call 记录属性并结束 span, 如:
+//
+//	wechat.TraceInterceptor(func(call *wechat.Call) (context.Context, func(*wechat.Call)) {
+//		ctx, span := tracer.Start(call.Context, call.Endpoint)
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(call.Header))
+//		return ctx, func(call *wechat.Call) {
+//			span.SetAttributes(attribute.Int("wechat.errcode", call.ErrCode))
+//			span.End()
+//		}
+//	})
+func TraceInterceptor(start func(call *Call) (ctx context.Context, end func(call *Call))) Interceptor {
+	return func(call *Call, next Invoker) error {
+		ctx, end := start(call)
+		if ctx != nil {
+			call.Context = ctx
+		}
+		err := next(call)
+		if end != nil {
+			end(call)
+		}
+		return err
+	}
+}
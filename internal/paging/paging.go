@@ -0,0 +1,21 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// Package paging 提供按 offset 分页遍历列表接口(素材、草稿及已发布文章等)的公共逻辑
+package paging
+
+// Walk 从 offset 为 0 开始依次调用 page 获取并处理每一页数据, 直到获取的数量达到总数或某一页为空.
+// page 返回本页的数量及列表总数, 返回错误时(包括处理数据时的错误)停止遍历并原样返回该错误
+func Walk(page func(offset int) (count, total int, err error)) error {
+	for offset := 0; ; {
+		count, total, err := page(offset)
+		if err != nil {
+			return err
+		}
+		offset += count
+		if count == 0 || offset >= total {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package paging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	cases := []struct {
+		name   string
+		total  int
+		counts []int // 每一页返回的数量
+		want   []int // 每次请求的 offset
+	}{
+		{"empty", 0, []int{0}, []int{0}},
+		{"one page", 3, []int{3}, []int{0}},
+		{"pages", 45, []int{20, 20, 5}, []int{0, 20, 40}},
+		// 总数变化导致提前返回空页时停止
+		{"short", 45, []int{20, 0}, []int{0, 20}},
+	}
+	for _, c := range cases {
+		var offsets []int
+		err := Walk(func(offset int) (int, int, error) {
+			offsets = append(offsets, offset)
+			if len(offsets) > len(c.counts) {
+				t.Fatalf("%s: too many pages", c.name)
+			}
+			return c.counts[len(offsets)-1], c.total, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(offsets, c.want) {
+			t.Errorf("%s: got offsets %v, want %v", c.name, offsets, c.want)
+		}
+	}
+
+	want := errors.New("stop")
+	n := 0
+	err := Walk(func(offset int) (int, int, error) {
+		n++
+		return 0, 0, want
+	})
+	if err != want || n != 1 {
+		t.Errorf("got %v after %d pages, want %v", err, n, want)
+	}
+}
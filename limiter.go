@@ -0,0 +1,193 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter 用于限制发送到微信服务器的请求频率.
+// endpoint 为接口路径, 如 "/cgi-bin/user/info/batchget"
+type RateLimiter interface {
+	// 发送请求前调用, 阻塞直到允许发送请求. 当 ctx 结束或接口配额已耗尽时返回错误
+	Wait(ctx context.Context, appid, endpoint string) error
+
+	// 获得响应后调用, err 为响应中的微信错误, 没有错误时为 nil
+	Observe(appid, endpoint string, err error)
+
+	// 清除 appid 的配额耗尽记录, 调用 clear_quota 接口成功后调用
+	Reset(appid string)
+}
+
+// QuotaError 表示接口的每日调用次数已耗尽, 在 ResetAt 之前的请求都将直接返回该错误.
+// QuotaError 属于 ErrCategoryQuotaExceeded 分类, 可通过 IsQuotaExceeded 判断.
+type QuotaError struct {
+	Appid    string
+	Endpoint string
+	ResetAt  time.Time
+}
+
+func (qe *QuotaError) Error() string {
+	return fmt.Sprintf("%s(appid) %s reach max api daily quota limit, reset at %s", qe.Appid, qe.Endpoint, qe.ResetAt.Format(time.RFC3339))
+}
+
+func (qe *QuotaError) Is(target error) bool {
+	switch t := target.(type) {
+	case *Error:
+		return t != nil && t.ErrCode == ErrCodeAPIDailyQuota
+	case ErrCategory:
+		return t != 0 && ErrCategoryQuotaExceeded&t == t
+	}
+	return false
+}
+
+// 每秒请求数及突发请求数
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+// Limiter 是按 appid 及接口分别计算的令牌桶限流器. 当接口返回 45009(每日调用次数超过限制)后,
+// 在北京时间次日零点配额重置之前, 对该接口的请求将直接返回 *QuotaError.
+type Limiter struct {
+	// 默认频率, Limit 小于等于 0 时不限制频率
+	Rate Rate
+
+	// 单独设置某些接口的频率, key 为接口路径
+	Endpoints map[string]Rate
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	exhausted map[string]time.Time
+}
+
+// 新建限流器, limit 为每个 appid 的每个接口每秒最多请求次数, burst 为最多突发请求次数
+func NewLimiter(limit float64, burst int) *Limiter {
+	return &Limiter{Rate: Rate{Limit: limit, Burst: burst}}
+}
+
+func limiterKey(appid, endpoint string) string {
+	return appid + " " + endpoint
+}
+
+// 北京时间
+var beijing = time.FixedZone("CST", 8*3600)
+
+// 配额重置时间, 为北京时间次日零点
+func nextQuotaReset(now time.Time) time.Time {
+	y, m, d := now.In(beijing).Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, beijing)
+}
+
+func (l *Limiter) Wait(ctx context.Context, appid, endpoint string) error {
+	key := limiterKey(appid, endpoint)
+	now := time.Now()
+	l.mu.Lock()
+	if resetAt, ok := l.exhausted[key]; ok {
+		if now.Before(resetAt) {
+			l.mu.Unlock()
+			return &QuotaError{Appid: appid, Endpoint: endpoint, ResetAt: resetAt}
+		}
+		delete(l.exhausted, key)
+	}
+	rate, ok := l.Endpoints[endpoint]
+	if !ok {
+		rate = l.Rate
+	}
+	if rate.Limit <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket, 5)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(rate, now)
+		l.buckets[key] = b
+	}
+	delay := b.reserve(now)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.cancel()
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (l *Limiter) Observe(appid, endpoint string, err error) {
+	if code, ok := ErrCodeOf(err); ok && code == ErrCodeAPIDailyQuota {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.exhausted == nil {
+			l.exhausted = make(map[string]time.Time, 5)
+		}
+		l.exhausted[limiterKey(appid, endpoint)] = nextQuotaReset(time.Now())
+	}
+}
+
+func (l *Limiter) Reset(appid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := limiterKey(appid, "")
+	for key := range l.exhausted {
+		if strings.HasPrefix(key, prefix) {
+			delete(l.exhausted, key)
+		}
+	}
+}
+
+// 令牌桶
+type bucket struct {
+	tokens float64
+	limit  float64
+	burst  float64
+	last   time.Time
+}
+
+func newBucket(rate Rate, now time.Time) *bucket {
+	burst := float64(rate.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{tokens: burst, limit: rate.Limit, burst: burst, last: now}
+}
+
+// 取出一个令牌, 返回需要等待的时间
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.limit * float64(time.Second))
+}
+
+// 归还未使用的令牌
+func (b *bucket) cancel() {
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
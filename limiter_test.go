@@ -0,0 +1,139 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Rate{Limit: 10, Burst: 2}, now)
+	// 突发请求不需要等待
+	for i := 0; i < 2; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("reserve %d: got delay %s, want 0", i, d)
+		}
+	}
+	if d := b.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("got delay %s, want 100ms", d)
+	}
+	if d := b.reserve(now); d != 200*time.Millisecond {
+		t.Errorf("got delay %s, want 200ms", d)
+	}
+	b.cancel()
+	// 300ms 后补充 3 个令牌, 其中 1 个归还的令牌已计入
+	if d := b.reserve(now.Add(300 * time.Millisecond)); d != 0 {
+		t.Errorf("got delay %s after refill, want 0", d)
+	}
+	// 令牌数不超过 burst
+	b.reserve(now.Add(time.Hour))
+	if b.tokens != 1 {
+		t.Errorf("got %v tokens, want 1", b.tokens)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(20, 1)
+	l.Endpoints = map[string]Rate{"/unlimited": {}}
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "wx1", "/api"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took %s, want at least 100ms", elapsed)
+	}
+	// 不同的 appid 及接口分别计算
+	start = time.Now()
+	for _, appid := range []string{"wx2", "wx3"} {
+		if err := l.Wait(ctx, appid, "/api"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if err := l.Wait(ctx, "wx1", "/unlimited"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("independent buckets took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	l = NewLimiter(1, 1)
+	_ = l.Wait(ctx, "wx1", "/api")
+	if err := l.Wait(ctx, "wx1", "/api"); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestNextQuotaReset(t *testing.T) {
+	cases := []struct {
+		now, want time.Time
+	}{
+		// 北京时间 2020-06-01 10:00
+		{time.Date(2020, 6, 1, 2, 0, 0, 0, time.UTC), time.Date(2020, 6, 1, 16, 0, 0, 0, time.UTC)},
+		// 北京时间 2020-06-02 07:00, UTC 日期仍为 6 月 1 日
+		{time.Date(2020, 6, 1, 23, 0, 0, 0, time.UTC), time.Date(2020, 6, 2, 16, 0, 0, 0, time.UTC)},
+		// 北京时间零点
+		{time.Date(2020, 6, 30, 16, 0, 0, 0, time.UTC), time.Date(2020, 7, 1, 16, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 12, 31, 15, 59, 0, 0, time.UTC), time.Date(2020, 12, 31, 16, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if got := nextQuotaReset(c.now); !got.Equal(c.want) {
+			t.Errorf("%s: got %s, want %s", c.now, got.UTC(), c.want)
+		}
+	}
+}
+
+func TestLimiterQuota(t *testing.T) {
+	l := NewLimiter(0, 0)
+	ctx := context.Background()
+	l.Observe("wx1", "/api", &Error{ErrCode: ErrCodeInvalidAccessToken})
+	if err := l.Wait(ctx, "wx1", "/api"); err != nil {
+		t.Fatalf("other errors should not exhaust quota: %v", err)
+	}
+	l.Observe("wx1", "/api", &Error{ErrCode: ErrCodeAPIDailyQuota})
+	err := l.Wait(ctx, "wx1", "/api")
+	qe, ok := err.(*QuotaError)
+	if !ok {
+		t.Fatalf("got %v, want *QuotaError", err)
+	}
+	if !qe.ResetAt.Equal(nextQuotaReset(time.Now())) {
+		t.Errorf("got ResetAt %s", qe.ResetAt)
+	}
+	if !errors.Is(err, &Error{ErrCode: ErrCodeAPIDailyQuota}) || !IsQuotaExceeded(err) {
+		t.Error("QuotaError should match 45009")
+	}
+	if err := l.Wait(ctx, "wx1", "/other"); err != nil {
+		t.Errorf("other endpoints should not be affected: %v", err)
+	}
+	if err := l.Wait(ctx, "wx2", "/api"); err != nil {
+		t.Errorf("other appids should not be affected: %v", err)
+	}
+
+	// 配额重置时间已过
+	l.exhausted[limiterKey("wx1", "/api")] = time.Now().Add(-time.Second)
+	if err := l.Wait(ctx, "wx1", "/api"); err != nil {
+		t.Errorf("expired record: %v", err)
+	}
+
+	l.Observe("wx1", "/api", &Error{ErrCode: ErrCodeAPIDailyQuota})
+	l.Observe("wx10", "/api", &Error{ErrCode: ErrCodeAPIDailyQuota})
+	l.Reset("wx1")
+	if err := l.Wait(ctx, "wx1", "/api"); err != nil {
+		t.Errorf("after Reset: %v", err)
+	}
+	if _, ok := l.Wait(ctx, "wx10", "/api").(*QuotaError); !ok {
+		t.Error("Reset should only clear the given appid")
+	}
+}
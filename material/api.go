@@ -8,10 +8,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/orivil/wechat"
-	"io/ioutil"
+	"github.com/orivil/wechat/internal/paging"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
-	"strings"
 )
 
 type UploadedMedia struct {
@@ -24,35 +25,76 @@ type VideoDescription struct {
 	Introduction string `json:"introduction"`
 }
 
-// 上传一个或多个永久素材
+// 上传请求中的一个或多个永久素材, 素材文件从请求中逐个读取并直接上传, 不会保存在内存或临时文件中
 func UploadMaterials(req *http.Request, kind MediaType, videoDesc *VideoDescription, token string) (results []*UploadedMedia, err error) {
-	err = req.ParseMultipartForm(0)
-	if err != nil {
-		return nil, err
-	}
-	for _, headers := range req.MultipartForm.File {
-		for _, header := range headers {
-			f, err := header.Open()
-			if err != nil {
-				return nil, err
-			}
-			data, err := ioutil.ReadAll(f)
-			if err != nil {
-				return nil, err
-			} else {
-				result, err := UploadMaterial(kind, data, header.Filename, token, videoDesc)
+	return UploadMaterialsWith(wechat.DefaultClient, req, kind, videoDesc, token)
+}
+
+// UploadMaterialsWith 同 UploadMaterials, 通过客户端 c 发送请求
+func UploadMaterialsWith(c *wechat.Client, req *http.Request, kind MediaType, videoDesc *VideoDescription, token string) (results []*UploadedMedia, err error) {
+	// 请求已被解析时使用解析结果
+	if req.MultipartForm != nil {
+		for _, headers := range req.MultipartForm.File {
+			for _, header := range headers {
+				result, err := uploadFileHeader(c, header, kind, videoDesc, token)
 				if err != nil {
 					return nil, err
-				} else {
-					results = append(results, result)
 				}
+				results = append(results, result)
 			}
 		}
+		return results, nil
 	}
-	return results, nil
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		result, err := UploadMaterialReaderWith(c, kind, part, part.FileName(), token, videoDesc)
+		_ = part.Close()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+}
+
+func uploadFileHeader(c *wechat.Client, header *multipart.FileHeader, kind MediaType, videoDesc *VideoDescription, token string) (*UploadedMedia, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return UploadMaterialReaderWith(c, kind, io.NewSectionReader(f, 0, header.Size), header.Filename, token, videoDesc)
 }
 
 func UploadMaterial(mediaType MediaType, data []byte, filename, token string, videoDesc *VideoDescription) (res *UploadedMedia, err error) {
+	return UploadMaterialWith(wechat.DefaultClient, mediaType, data, filename, token, videoDesc)
+}
+
+// UploadMaterialWith 同 UploadMaterial, 通过客户端 c 发送请求
+func UploadMaterialWith(c *wechat.Client, mediaType MediaType, data []byte, filename, token string, videoDesc *VideoDescription) (res *UploadedMedia, err error) {
+	return UploadMaterialReaderWith(c, mediaType, bytes.NewReader(data), filename, token, videoDesc)
+}
+
+// 上传永久素材, 文件数据从 r 中读取并直接上传. 上传前按素材类型校验文件, 见 CheckMediaFile.
+// videoDesc 为视频素材的描述信息, 只有上传视频素材时需要
+func UploadMaterialReader(mediaType MediaType, r io.Reader, filename, token string, videoDesc *VideoDescription) (res *UploadedMedia, err error) {
+	return UploadMaterialReaderWith(wechat.DefaultClient, mediaType, r, filename, token, videoDesc)
+}
+
+// UploadMaterialReaderWith 同 UploadMaterialReader, 通过客户端 c 发送请求
+func UploadMaterialReaderWith(c *wechat.Client, mediaType MediaType, r io.Reader, filename, token string, videoDesc *VideoDescription) (res *UploadedMedia, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/material/add_material?access_token=" + token + "&type=" + string(mediaType)
 	var vs url.Values
 	if videoDesc != nil {
@@ -64,7 +106,7 @@ func UploadMaterial(mediaType MediaType, data []byte, filename, token string, vi
 		}
 	}
 	res = &UploadedMedia{}
-	err = wechat.UploadFile(uri, data, "media", filename, vs, res)
+	err = uploadMedia(c, uri, mediaType, r, filename, vs, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -73,8 +115,13 @@ func UploadMaterial(mediaType MediaType, data []byte, filename, token string, vi
 }
 
 func DelMaterial(mediaID, token string) error {
+	return DelMaterialWith(wechat.DefaultClient, mediaID, token)
+}
+
+// DelMaterialWith 同 DelMaterial, 通过客户端 c 发送请求
+func DelMaterialWith(c *wechat.Client, mediaID, token string) error {
 	ul := "https://api.weixin.qq.com/cgi-bin/material/del_material?access_token=" + token
-	return wechat.PostSchema(wechat.KindJson, ul, map[string]string{"media_id": mediaID}, nil)
+	return c.PostSchema(wechat.KindJson, ul, map[string]string{"media_id": mediaID}, nil)
 }
 
 type Count struct {
@@ -86,8 +133,13 @@ type Count struct {
 
 // 获得素材统计
 func CountMaterials(token string) (count *Count, err error) {
+	return CountMaterialsWith(wechat.DefaultClient, token)
+}
+
+// CountMaterialsWith 同 CountMaterials, 通过客户端 c 发送请求
+func CountMaterialsWith(c *wechat.Client, token string) (count *Count, err error) {
 	count = &Count{}
-	err = wechat.GetJson("https://api.weixin.qq.com/cgi-bin/material/get_materialcount?access_token="+token, count)
+	err = c.GetJson("https://api.weixin.qq.com/cgi-bin/material/get_materialcount?access_token="+token, count)
 	if err != nil {
 		return nil, err
 	} else {
@@ -108,10 +160,18 @@ type MediaItem struct {
 	Url        string `json:"url"`
 }
 
+// 批量获取素材时每次最多获取的数量
+const MaxBatchCount = 20
+
 // 获得素材列表
 func GetMedias(kind MediaType, token string, limit, offset int) (res *MediaList, err error) {
+	return GetMediasWith(wechat.DefaultClient, kind, token, limit, offset)
+}
+
+// GetMediasWith 同 GetMedias, 通过客户端 c 发送请求
+func GetMediasWith(c *wechat.Client, kind MediaType, token string, limit, offset int) (res *MediaList, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/material/batchget_material?access_token=" + token
-	err = wechat.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]interface{}{
 		"type":   kind,
 		"offset": offset,
 		"count":  limit,
@@ -119,25 +179,57 @@ func GetMedias(kind MediaType, token string, limit, offset int) (res *MediaList,
 	return
 }
 
+// 遍历 kind 类型的所有永久素材, 每次获取 MaxBatchCount 个, 图文素材使用 WalkNews
+func WalkMedias(kind MediaType, token string, fn func(item *MediaItem) error) error {
+	return WalkMediasWith(wechat.DefaultClient, kind, token, fn)
+}
+
+// WalkMediasWith 同 WalkMedias, 通过客户端 c 发送请求
+func WalkMediasWith(c *wechat.Client, kind MediaType, token string, fn func(item *MediaItem) error) error {
+	return paging.Walk(func(offset int) (int, int, error) {
+		list, err := GetMediasWith(c, kind, token, MaxBatchCount, offset)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := range list.Item {
+			err = fn(&list.Item[i])
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(list.Item), list.TotalCount, nil
+	})
+}
+
 // 获得素材内容, image 及 voice 类型直接返回二进制文件, video 及 news 返回 json 文件
 func GetMedia(token, mediaID string) (data []byte, err error) {
+	return GetMediaWith(wechat.DefaultClient, token, mediaID)
+}
+
+// GetMediaWith 同 GetMedia, 通过客户端 c 发送请求
+func GetMediaWith(c *wechat.Client, token, mediaID string) (data []byte, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/material/get_material?access_token=" + token
-	res, err := http.Post(uri, "application/json;charset=utf-8", strings.NewReader(`{"media_id": "`+mediaID+`"}`))
+	body, err := json.Marshal(map[string]string{"media_id": mediaID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	// 按响应类型区分 json 数据及二进制文件, 二进制文件中可能包含 "errcode" 字符串
+	buf := &bytes.Buffer{}
+	_, data, err = c.Idempotent().Download(req, buf)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return buf.Bytes(), nil
+	}
+	err = wechat.ParseError(data)
 	if err != nil {
 		return nil, err
-	} else {
-		defer res.Body.Close()
-		data, err = ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, err
-		}
-		if bytes.Contains(data, []byte("errcode")) {
-			e := &wechat.Error{}
-			_ = json.Unmarshal(data, e)
-			if e.ErrCode != 0 {
-				return nil, e
-			}
-		}
-		return data, nil
 	}
+	return data, nil
 }
@@ -0,0 +1,52 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/orivil/wechat/material"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func TestWalkMedias(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	app := srv.App("wx123")
+	for i := 0; i < 45; i++ {
+		app.Materials = append(app.Materials, &wechattest.Material{MediaID: strconv.Itoa(i), Type: "image"})
+	}
+	app.Materials = append(app.Materials, &wechattest.Material{MediaID: "voice", Type: "voice"})
+
+	var ids []string
+	err := material.WalkMediasWith(srv.Client(), material.IMAGE, token, func(item *material.MediaItem) error {
+		ids = append(ids, item.MediaID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 45 || ids[0] != "0" || ids[44] != "44" {
+		t.Errorf("got %d items: %v", len(ids), ids)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/material/batchget_material")); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = material.WalkMediasWith(srv.Client(), material.IMAGE, token, func(item *material.MediaItem) error {
+		n++
+		if n == 25 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 25 {
+		t.Errorf("got %v after %d items, want %v", err, n, stop)
+	}
+}
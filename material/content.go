@@ -0,0 +1,308 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/orivil/wechat"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 图文内容的限制
+const (
+	MaxContentChars = 20000   // 必须少于2万字符
+	MaxContentBytes = 1 << 20 // 必须小于1M
+
+	// 图文内容中的图片只支持 jpg 及 png 格式, 必须在1MB以下
+	MaxContentImageSize = 1 << 20
+)
+
+var ErrContentTooLarge = errors.New("图文内容超过限制")
+
+// CheckContent 校验图文内容的长度, 超过限制时返回 ErrContentTooLarge
+func CheckContent(content string) error {
+	if n := utf8.RuneCountInString(content); n >= MaxContentChars {
+		return errors.Wrapf(ErrContentTooLarge, "%d 个字符, 必须少于 %d 个字符", n, MaxContentChars)
+	}
+	if len(content) >= MaxContentBytes {
+		return errors.Wrapf(ErrContentTooLarge, "%d 字节, 必须小于 %d 字节", len(content), MaxContentBytes)
+	}
+	return nil
+}
+
+// ImageFetcher 下载图文内容中的外部图片
+type ImageFetcher interface {
+	FetchImage(ctx context.Context, rawurl string) (data []byte, err error)
+}
+
+type ImageFetcherFunc func(ctx context.Context, rawurl string) (data []byte, err error)
+
+func (f ImageFetcherFunc) FetchImage(ctx context.Context, rawurl string) (data []byte, err error) {
+	return f(ctx, rawurl)
+}
+
+// HTTPImageFetcher 通过 HTTP 下载图片, 超过 MaxContentImageSize 的图片返回 ErrMediaTooLarge
+type HTTPImageFetcher struct {
+	// 为 nil 时使用 http.DefaultClient
+	Client *http.Client
+}
+
+func (f *HTTPImageFetcher) FetchImage(ctx context.Context, rawurl string) (data []byte, err error) {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch image %s: %s", rawurl, resp.Status)
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(resp.Body, MaxContentImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxContentImageSize {
+		return nil, errors.Wrapf(ErrMediaTooLarge, "%s: 超过 %d 字节", rawurl, MaxContentImageSize)
+	}
+	return data, nil
+}
+
+// ImageCache 以图片数据的 sha256 值保存已上传图片的地址, 避免重复上传. 未保存时 Read 返回空字符串.
+// platform.DataStorage 实现了该接口, 可用于多个进程共用缓存
+type ImageCache interface {
+	Read(hash string) (url string, err error)
+	Store(hash, url string) error
+}
+
+// MemoryImageCache 是保存在内存中的图片缓存
+type MemoryImageCache struct {
+	urls map[string]string
+	mu   sync.RWMutex
+}
+
+func NewMemoryImageCache() *MemoryImageCache {
+	return &MemoryImageCache{urls: make(map[string]string)}
+}
+
+func (m *MemoryImageCache) Read(hash string) (url string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.urls[hash], nil
+}
+
+func (m *MemoryImageCache) Store(hash, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.urls == nil {
+		m.urls = make(map[string]string)
+	}
+	m.urls[hash] = url
+	return nil
+}
+
+// IsWechatImage 判断图片是否已经存放在微信服务器上(通过上传图文消息内的图片接口获得)
+func IsWechatImage(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return strings.HasSuffix(host, ".qpic.cn") || strings.HasSuffix(host, ".qlogo.cn")
+}
+
+// ContentRewriter 将图文内容中的外部图片上传到微信服务器并替换图片地址, 否则外部图片将被微信过滤:
+//
+//	rw := &material.ContentRewriter{Cache: material.NewMemoryImageCache()}
+//	err := rw.RewriteArticle(article, token)
+type ContentRewriter struct {
+	// 上传图片的客户端, 为 nil 时使用 wechat.DefaultClient. 下载图片时使用其 Context
+	Client *wechat.Client
+
+	// 为 nil 时使用 HTTPImageFetcher
+	Fetcher ImageFetcher
+
+	// 为 nil 时不缓存, 同一内容中相同的图片仍只上传一次
+	Cache ImageCache
+
+	// 判断图片是否不需要上传, 为 nil 时使用 IsWechatImage
+	IsHosted func(u *url.URL) bool
+}
+
+// rewriteImages 解析图文内容中的 html 标记, 对每个带有 src 属性的 img 标签以 src 的值调用 fn,
+// fn 返回 true 时将该 src 属性替换为返回的地址. 注释、脚本等非标签内容中的文字不会被处理, 其余内容保持不变
+func rewriteImages(content string, fn func(src string) (string, bool)) string {
+	var buf strings.Builder
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// 内容已读取完毕, 剩余的不完整标记原样保留
+			buf.Write(z.Raw())
+			return buf.String()
+		}
+		raw := z.Raw()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			buf.Write(raw)
+			continue
+		}
+		raw = append([]byte(nil), raw...)
+		token := z.Token()
+		if token.DataAtom != atom.Img || !replaceSrc(&token, fn) {
+			buf.Write(raw)
+			continue
+		}
+		buf.WriteString(token.String())
+	}
+}
+
+// 替换 img 标签中的第一个 src 属性, 未替换时返回 false
+func replaceSrc(token *html.Token, fn func(src string) (string, bool)) bool {
+	for i, attr := range token.Attr {
+		if attr.Namespace != "" || attr.Key != "src" {
+			continue
+		}
+		src, ok := fn(strings.TrimSpace(attr.Val))
+		if ok {
+			token.Attr[i].Val = src
+		}
+		return ok
+	}
+	return false
+}
+
+// ExternalImages 返回图文内容中需要上传的外部图片地址, 相同的地址只返回一次
+func (r *ContentRewriter) ExternalImages(content string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	rewriteImages(content, func(src string) (string, bool) {
+		if !seen[src] && r.external(src) {
+			seen[src] = true
+			urls = append(urls, src)
+		}
+		return "", false
+	})
+	return urls
+}
+
+// 只处理 http 及 https 图片, 相对地址及 data URI 无法上传
+func (r *ContentRewriter) external(src string) bool {
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	isHosted := r.IsHosted
+	if isHosted == nil {
+		isHosted = IsWechatImage
+	}
+	return !isHosted(u)
+}
+
+// Rewrite 下载并上传图文内容中的外部图片, 返回替换图片地址后的内容. 任意图片处理失败时返回错误.
+// 上传图片之前及替换地址之后都会通过 CheckContent 校验内容
+func (r *ContentRewriter) Rewrite(content, token string) (string, error) {
+	err := CheckContent(content)
+	if err != nil {
+		return "", err
+	}
+	urls := make(map[string]string)
+	for _, src := range r.ExternalImages(content) {
+		uploaded, err := r.upload(src, token)
+		if err != nil {
+			return "", err
+		}
+		urls[src] = uploaded
+	}
+	if len(urls) == 0 {
+		return content, nil
+	}
+	content = rewriteImages(content, func(src string) (string, bool) {
+		uploaded, ok := urls[src]
+		return uploaded, ok
+	})
+	err = CheckContent(content)
+	if err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// RewriteArticle 替换 article.Content 中的外部图片, 出错时不修改 article
+func (r *ContentRewriter) RewriteArticle(article *Article, token string) error {
+	content, err := r.Rewrite(article.Content, token)
+	if err != nil {
+		return err
+	}
+	article.Content = content
+	return nil
+}
+
+// 下载并上传图片 src, 返回上传后的地址
+func (r *ContentRewriter) upload(src, token string) (string, error) {
+	fetcher := r.Fetcher
+	if fetcher == nil {
+		fetcher = &HTTPImageFetcher{}
+	}
+	data, err := fetcher.FetchImage(r.Client.Context(), src)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if r.Cache != nil {
+		uploaded, err := r.Cache.Read(hash)
+		if err != nil {
+			return "", err
+		}
+		if uploaded != "" {
+			return uploaded, nil
+		}
+	}
+	filename, err := contentImageName(src, data)
+	if err != nil {
+		return "", err
+	}
+	uploaded, err := UploadNewsContentImageWith(r.Client, filename, token, data)
+	if err != nil {
+		return "", errors.WithMessage(err, src)
+	}
+	if r.Cache != nil {
+		err = r.Cache.Store(hash, uploaded)
+		if err != nil {
+			return "", err
+		}
+	}
+	return uploaded, nil
+}
+
+// 校验图片格式及大小, 按文件内容返回上传时使用的文件名
+func contentImageName(src string, data []byte) (string, error) {
+	if len(data) > MaxContentImageSize {
+		return "", errors.Wrapf(ErrMediaTooLarge, "%s: %d 字节, 图文内容中的图片不能超过 %d 字节", src, len(data), MaxContentImageSize)
+	}
+	switch contentType := http.DetectContentType(data); contentType {
+	case "image/jpeg":
+		return "image.jpg", nil
+	case "image/png":
+		return "image.png", nil
+	default:
+		return "", errors.Wrapf(ErrMediaFormat, "%s: 文件内容为 %s, 图文内容中的图片只支持 jpg 及 png 格式", src, contentType)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/material"
+	"github.com/orivil/wechat/wechattest"
+	pkgerrors "github.com/pkg/errors"
+)
+
+var jpegData = []byte("\xff\xd8\xff\xe0jpeg")
+
+// 返回的图片数据由 images 指定, 并记录下载次数
+type testFetcher struct {
+	images  map[string][]byte
+	fetched map[string]int
+}
+
+func (f *testFetcher) FetchImage(ctx context.Context, rawurl string) ([]byte, error) {
+	f.fetched[rawurl]++
+	data, ok := f.images[rawurl]
+	if !ok {
+		return nil, fmt.Errorf("fetch image %s: 404 Not Found", rawurl)
+	}
+	return data, nil
+}
+
+func newRewriter(t *testing.T) (*wechattest.Server, string, *material.ContentRewriter, *testFetcher) {
+	t.Helper()
+	srv, token := newServer(t)
+	fetcher := &testFetcher{
+		images: map[string][]byte{
+			"http://a.com/1.png": pngData,
+			"http://a.com/2.jpg": jpegData,
+			"http://b.com/1.png": pngData,
+			"http://a.com/x.gif": []byte("GIF89a"),
+		},
+		fetched: make(map[string]int),
+	}
+	rw := &material.ContentRewriter{Client: srv.Client(), Fetcher: fetcher, Cache: material.NewMemoryImageCache()}
+	return srv, token, rw, fetcher
+}
+
+func TestExternalImages(t *testing.T) {
+	rw := &material.ContentRewriter{}
+	content := `<p><img src="http://a.com/1.png"><IMG alt=x SRC='http://a.com/2.jpg?a=1&amp;b=2'/>` +
+		`<img src=https://a.com/3.png><img src="http://a.com/1.png">` +
+		`<img data-src="http://a.com/lazy.png"><img src="/relative.png"><img src="data:image/png;base64,AAAA">` +
+		`<img src="http://mmbiz.qpic.cn/a.png"><!-- <img src="http://a.com/comment.png"> -->` +
+		`<script>var s = '<img src="http://a.com/script.png">';</script>` +
+		`<p>src="http://a.com/text.png"</p><img src=" http://a.com/4.png ">`
+	want := []string{"http://a.com/1.png", "http://a.com/2.jpg?a=1&b=2", "https://a.com/3.png", "http://a.com/4.png"}
+	if got := rw.ExternalImages(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	srv, token, rw, fetcher := newRewriter(t)
+	defer srv.Close()
+
+	content := `<p title='<img src="http://a.com/1.png">'>a &amp; b</p>` +
+		`<img alt="1" src="http://a.com/1.png"/><!-- <img src="http://a.com/1.png"> -->` +
+		`<img class=c src=http://a.com/2.jpg><img src="http://a.com/1.png">` +
+		`<img data-src="http://a.com/2.jpg" src="/local.png">`
+	got, err := rw.Rewrite(content, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url1, url2 := srv.URL+"/mmbiz/IMAGE_2", srv.URL+"/mmbiz/IMAGE_3"
+	want := `<p title='<img src="http://a.com/1.png">'>a &amp; b</p>` +
+		`<img alt="1" src="` + url1 + `"/><!-- <img src="http://a.com/1.png"> -->` +
+		`<img class="c" src="` + url2 + `"><img src="` + url1 + `">` +
+		`<img data-src="http://a.com/2.jpg" src="/local.png">`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	// 相同的图片只上传一次
+	if n := len(srv.RequestsTo("/cgi-bin/media/uploadimg")); n != 2 {
+		t.Errorf("got %d uploads, want 2", n)
+	}
+
+	// 不同地址的相同图片使用缓存
+	got, err = rw.Rewrite(`<img src="http://b.com/1.png">`, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<img src="` + url1 + `">`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/media/uploadimg")); n != 2 {
+		t.Errorf("got %d uploads, want 2", n)
+	}
+	if fetcher.fetched["http://b.com/1.png"] != 1 {
+		t.Error("image not fetched")
+	}
+
+	// 结尾不完整的标记原样保留
+	got, err = rw.Rewrite(`<img src="http://a.com/1.png"><p>a<img src="http://a.com`, token)
+	if want := `<img src="` + url1 + `"><p>a<img src="http://a.com`; err != nil || got != want {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+
+	// 没有外部图片时原样返回
+	content = `<img src=/local.png><p>text</p>`
+	got, err = rw.Rewrite(content, token)
+	if err != nil || got != content {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// 零值的缓存可以直接使用
+	rw.Cache = &material.MemoryImageCache{}
+	got, err = rw.Rewrite(`<img src="http://a.com/1.png">`, token)
+	if want := `<img src="` + srv.URL + `/mmbiz/IMAGE_4">`; err != nil || got != want {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+}
+
+func TestRewriteErrors(t *testing.T) {
+	srv, token, rw, fetcher := newRewriter(t)
+	defer srv.Close()
+
+	// 在下载及上传图片之前校验内容长度
+	content := `<img src="http://a.com/1.png">` + strings.Repeat("字", material.MaxContentChars)
+	_, err := rw.Rewrite(content, token)
+	if pkgerrors.Cause(err) != material.ErrContentTooLarge {
+		t.Errorf("got %v, want ErrContentTooLarge", err)
+	}
+	if len(fetcher.fetched) != 0 || len(srv.RequestsTo("/cgi-bin/media/uploadimg")) != 0 {
+		t.Error("images processed before checking content")
+	}
+
+	_, err = rw.Rewrite(`<img src="http://a.com/x.gif">`, token)
+	if pkgerrors.Cause(err) != material.ErrMediaFormat {
+		t.Errorf("got %v, want ErrMediaFormat", err)
+	}
+
+	// 出错时不修改文章
+	article := &material.Article{Content: `<img src="http://a.com/1.png"><img src="http://a.com/404.png">`}
+	err = rw.RewriteArticle(article, token)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, want fetch error", err)
+	}
+	if article.Content != `<img src="http://a.com/1.png"><img src="http://a.com/404.png">` {
+		t.Errorf("article modified: %s", article.Content)
+	}
+
+	srv.InjectError("/cgi-bin/media/uploadimg", wechat.ErrCodeInvalidFileType, 1)
+	_, err = rw.Rewrite(`<img src="http://a.com/2.jpg">`, token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidFileType {
+		t.Errorf("got %v, want upload error", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/orivil/wechat"
+)
+
+// 临时素材在微信服务器上的保存时间
+const MediaLifetime = 3 * 24 * time.Hour
+
+// MediaExpiredError 表示临时素材不存在或已过期, 可通过 errors.As 判断:
+//
+//	var expired *material.MediaExpiredError
+//	if errors.As(err, &expired) {
+//		// 重新上传
+//	}
+type MediaExpiredError struct {
+	MediaID string
+	Err     *wechat.Error
+}
+
+func (e *MediaExpiredError) Error() string {
+	return fmt.Sprintf("media %q expired or not exist: %s", e.MediaID, e.Err)
+}
+
+func (e *MediaExpiredError) Unwrap() error {
+	return e.Err
+}
+
+// 将 media_id 无效的错误转换为 *MediaExpiredError
+func mediaError(mediaID string, err error) error {
+	var we *wechat.Error
+	if errors.As(err, &we) {
+		switch we.ErrCode {
+		case wechat.ErrCodeInvalidMediaID, wechat.ErrCodeMediaNotExist:
+			return &MediaExpiredError{MediaID: mediaID, Err: we}
+		}
+	}
+	return err
+}
+
+// 临时素材
+type Media struct {
+	Type MediaType `json:"type"`
+
+	// 缩略图(thumb)类型的临时素材也使用该字段
+	MediaID string `json:"media_id"`
+
+	// 上传时间戳
+	CreatedAt int64 `json:"created_at"`
+}
+
+// 过期时间
+func (m *Media) ExpiresAt() time.Time {
+	return time.Unix(m.CreatedAt, 0).Add(MediaLifetime)
+}
+
+// 上传临时素材, 文件数据从 r 中读取并直接上传. 临时素材保存3天, 可用于发送客服消息及被动回复消息等.
+// 上传前按素材类型校验文件, 见 CheckMediaFile
+func UploadMedia(mediaType MediaType, r io.Reader, filename, token string) (media *Media, err error) {
+	return UploadMediaWith(wechat.DefaultClient, mediaType, r, filename, token)
+}
+
+// UploadMediaWith 同 UploadMedia, 通过客户端 c 发送请求
+func UploadMediaWith(c *wechat.Client, mediaType MediaType, r io.Reader, filename, token string) (media *Media, err error) {
+	uri := "https://api.weixin.qq.com/cgi-bin/media/upload?access_token=" + token + "&type=" + string(mediaType)
+	res := &struct {
+		Media
+		ThumbMediaID string `json:"thumb_media_id"`
+	}{}
+	err = uploadMedia(c, uri, mediaType, r, filename, nil, res)
+	if err != nil {
+		return nil, err
+	}
+	media = &res.Media
+	if media.MediaID == "" {
+		media.MediaID = res.ThumbMediaID
+	}
+	return media, nil
+}
+
+// 下载的临时素材信息
+type MediaFile struct {
+	// 文件类型, 如 "image/jpeg"
+	ContentType string
+
+	// 文件名, 微信未提供时为空
+	Filename string
+
+	// 视频素材不返回文件数据, 而是返回视频下载地址
+	VideoURL string
+}
+
+// 下载临时素材并写入 w, 视频素材不写入 w, 而是返回下载地址 MediaFile.VideoURL.
+// 素材不存在或已过期时返回 *MediaExpiredError
+func DownloadMedia(token, mediaID string, w io.Writer) (file *MediaFile, err error) {
+	return DownloadMediaWith(wechat.DefaultClient, token, mediaID, w)
+}
+
+// DownloadMediaWith 同 DownloadMedia, 通过客户端 c 发送请求
+func DownloadMediaWith(c *wechat.Client, token, mediaID string, w io.Writer) (file *MediaFile, err error) {
+	uri := "https://api.weixin.qq.com/cgi-bin/media/get?access_token=" + token + "&media_id=" + url.QueryEscape(mediaID)
+	return downloadMedia(c, uri, mediaID, w)
+}
+
+// 下载 JSSDK 上传的高清语音素材(speex 格式, 16K 采样率)并写入 w.
+// 素材不存在或已过期时返回 *MediaExpiredError
+func DownloadJssdkVoice(token, mediaID string, w io.Writer) (file *MediaFile, err error) {
+	return DownloadJssdkVoiceWith(wechat.DefaultClient, token, mediaID, w)
+}
+
+// DownloadJssdkVoiceWith 同 DownloadJssdkVoice, 通过客户端 c 发送请求
+func DownloadJssdkVoiceWith(c *wechat.Client, token, mediaID string, w io.Writer) (file *MediaFile, err error) {
+	uri := "https://api.weixin.qq.com/cgi-bin/media/get/jssdk?access_token=" + token + "&media_id=" + url.QueryEscape(mediaID)
+	return downloadMedia(c, uri, mediaID, w)
+}
+
+func downloadMedia(c *wechat.Client, uri, mediaID string, w io.Writer) (file *MediaFile, err error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	header, data, err := c.Download(req, w)
+	if err != nil {
+		return nil, mediaError(mediaID, err)
+	}
+	file = &MediaFile{ContentType: header.Get("Content-Type")}
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		file.Filename = params["filename"]
+	}
+	if data == nil {
+		return file, nil
+	}
+	// json 数据为错误信息或视频下载地址
+	err = wechat.ParseError(data)
+	if err != nil {
+		return nil, mediaError(mediaID, err)
+	}
+	res := &struct {
+		VideoURL string `json:"video_url"`
+	}{}
+	err = json.Unmarshal(data, res)
+	if err != nil {
+		return nil, err
+	}
+	if res.VideoURL == "" {
+		return nil, fmt.Errorf("unexpected media response: %s", data)
+	}
+	file.VideoURL = res.VideoURL
+	return file, nil
+}
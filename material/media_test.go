@@ -0,0 +1,128 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/material"
+	"github.com/orivil/wechat/wechattest"
+)
+
+var (
+	pngData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+	mp4Data = append([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), bytes.Repeat([]byte{1}, 100)...)
+)
+
+// 返回已添加公众号 wx123 的服务器及 access_token
+func newServer(t *testing.T) (*wechattest.Server, string) {
+	t.Helper()
+	srv := wechattest.NewServer()
+	srv.AddApp("wx123", "secret")
+	token, err := access.GetAccessTokenWith(srv.Client(), "wx123", "secret")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return srv, token.Value
+}
+
+func TestMedia(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	c := srv.Client()
+
+	image, err := material.UploadMediaWith(c, material.IMAGE, bytes.NewReader(pngData), "a.png", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Type != material.IMAGE || image.MediaID == "" || image.ExpiresAt().Before(time.Now()) {
+		t.Fatalf("got media %+v", image)
+	}
+	buf := &bytes.Buffer{}
+	file, err := material.DownloadMediaWith(c, token, image.MediaID, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), pngData) {
+		t.Errorf("got %d bytes, want %d", buf.Len(), len(pngData))
+	}
+	if file.Filename != "a.png" || file.ContentType != "application/octet-stream" || file.VideoURL != "" {
+		t.Errorf("got file %+v", file)
+	}
+
+	// 视频素材返回 json 格式的下载地址, 不写入 w
+	video, err := material.UploadMediaWith(c, material.VIDEO, bytes.NewReader(mp4Data), "a.mp4", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	file, err = material.DownloadMediaWith(c, token, video.MediaID, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.VideoURL == "" || buf.Len() != 0 {
+		t.Errorf("got file %+v and %d bytes", file, buf.Len())
+	}
+
+	// 缩略图使用 thumb_media_id 返回
+	thumb, err := material.UploadMediaWith(c, material.THUMB, bytes.NewReader([]byte("\xff\xd8\xff\xe0")), "a.jpg", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.MediaID == "" {
+		t.Error("empty thumb media id")
+	}
+}
+
+func TestMediaExpired(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	c := srv.Client()
+	image, err := material.UploadMediaWith(c, material.IMAGE, bytes.NewReader(pngData), "a.png", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.App("wx123").Media[0].CreatedAt -= int64(material.MediaLifetime/time.Second) + 1
+
+	for _, mediaID := range []string{image.MediaID, "unknown"} {
+		buf := &bytes.Buffer{}
+		_, err = material.DownloadMediaWith(c, token, mediaID, buf)
+		var expired *material.MediaExpiredError
+		if !errors.As(err, &expired) || expired.MediaID != mediaID {
+			t.Fatalf("%s: got %v, want *MediaExpiredError", mediaID, err)
+		}
+		if !errors.Is(err, &wechat.Error{ErrCode: wechat.ErrCodeInvalidMediaID}) {
+			t.Errorf("%s: got %v, want 40007", mediaID, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: error written to w", mediaID)
+		}
+	}
+
+	// 拦截器包装后的错误
+	c.Interceptors = []wechat.Interceptor{func(call *wechat.Call, next wechat.Invoker) error {
+		return fmt.Errorf("intercepted: %w", &wechat.Error{ErrCode: wechat.ErrCodeMediaNotExist})
+	}}
+	_, err = material.DownloadMediaWith(c, token, image.MediaID, &bytes.Buffer{})
+	var expired *material.MediaExpiredError
+	if !errors.As(err, &expired) || expired.MediaID != image.MediaID {
+		t.Errorf("wrapped error: got %v, want *MediaExpiredError", err)
+	}
+	c.Interceptors = nil
+
+	// 其他错误不转换
+	_, err = material.DownloadJssdkVoiceWith(c, "invalid", image.MediaID, &bytes.Buffer{})
+	expired = nil
+	if errors.As(err, &expired) {
+		t.Errorf("got %v, want invalid access token", err)
+	}
+}
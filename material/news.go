@@ -6,6 +6,7 @@ package material
 
 import (
 	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/internal/paging"
 )
 
 type News struct {
@@ -33,6 +34,7 @@ type Article struct {
 	ShowCoverPic int `json:"show_cover_pic"`
 
 	// 图文消息的具体内容，支持HTML标签，必须少于2万字符，小于1M，且此处会去除JS,涉及图片url必须来源 '上传图文消息内的图片获取URL'接口获取。外部图片url将被过滤。
+	// 可通过 ContentRewriter 上传其中的外部图片
 	Content string `json:"content"`
 
 	// 图文消息的原文地址，即点击“阅读原文”后的URL
@@ -50,11 +52,16 @@ type Article struct {
 
 // 上传图文并获得图文信息
 func UploadNews(news *News, token string) (mediaID string, err error) {
+	return UploadNewsWith(wechat.DefaultClient, news, token)
+}
+
+// UploadNewsWith 同 UploadNews, 通过客户端 c 发送请求
+func UploadNewsWith(c *wechat.Client, news *News, token string) (mediaID string, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/material/add_news?access_token=" + token
 	res := &struct {
 		MediaID string `json:"media_id"`
 	}{}
-	err = wechat.PostSchema(wechat.KindJson, ul, news, &res)
+	err = c.PostSchema(wechat.KindJson, ul, news, &res)
 	if err != nil {
 		return "", err
 	} else {
@@ -67,9 +74,14 @@ type ContentImage struct {
 }
 
 func UploadNewsContentImage(fileName, token string, data []byte) (uri string, err error) {
+	return UploadNewsContentImageWith(wechat.DefaultClient, fileName, token, data)
+}
+
+// UploadNewsContentImageWith 同 UploadNewsContentImage, 通过客户端 c 发送请求
+func UploadNewsContentImageWith(c *wechat.Client, fileName, token string, data []byte) (uri string, err error) {
 	uri = "https://api.weixin.qq.com/cgi-bin/media/uploadimg?access_token=" + token
 	res := &ContentImage{}
-	err = wechat.UploadFile(uri, data, "media", fileName, nil, res)
+	err = c.UploadFile(uri, data, "media", fileName, nil, res)
 	if err != nil {
 		return "", err
 	} else {
@@ -77,10 +89,30 @@ func UploadNewsContentImage(fileName, token string, data []byte) (uri string, er
 	}
 }
 
+// 修改永久图文素材中的一篇文章, index 为文章在图文中的位置, 第一篇为 0
+func UpdateNewsArticle(mediaID string, index int, article *Article, token string) error {
+	return UpdateNewsArticleWith(wechat.DefaultClient, mediaID, index, article, token)
+}
+
+// UpdateNewsArticleWith 同 UpdateNewsArticle, 通过客户端 c 发送请求
+func UpdateNewsArticleWith(c *wechat.Client, mediaID string, index int, article *Article, token string) error {
+	ul := "https://api.weixin.qq.com/cgi-bin/material/update_news?access_token=" + token
+	return c.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+		"media_id": mediaID,
+		"index":    index,
+		"articles": article,
+	}, nil)
+}
+
 func GetNewsArticles(mediaID, token string) (articles []*Article, err error) {
+	return GetNewsArticlesWith(wechat.DefaultClient, mediaID, token)
+}
+
+// GetNewsArticlesWith 同 GetNewsArticles, 通过客户端 c 发送请求
+func GetNewsArticlesWith(c *wechat.Client, mediaID, token string) (articles []*Article, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/material/get_material?access_token=" + token
 	res := &NewsArticles{}
-	err = wechat.PostSchema(wechat.KindJson, uri, map[string]string{"media_id": mediaID}, res)
+	err = c.Idempotent().PostSchema(wechat.KindJson, uri, map[string]string{"media_id": mediaID}, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -102,11 +134,38 @@ type NewItem struct {
 
 // 获得图文列表
 func GetNews(token string, limit, offset int) (res *NewsList, err error) {
+	return GetNewsWith(wechat.DefaultClient, token, limit, offset)
+}
+
+// GetNewsWith 同 GetNews, 通过客户端 c 发送请求
+func GetNewsWith(c *wechat.Client, token string, limit, offset int) (res *NewsList, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/material/batchget_material?access_token=" + token
-	err = wechat.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]interface{}{
 		"type":   NEWS,
 		"offset": offset,
 		"count":  limit,
 	}, &res)
 	return
 }
+
+// 遍历所有永久图文素材, 每次获取 MaxBatchCount 个
+func WalkNews(token string, fn func(item *NewItem) error) error {
+	return WalkNewsWith(wechat.DefaultClient, token, fn)
+}
+
+// WalkNewsWith 同 WalkNews, 通过客户端 c 发送请求
+func WalkNewsWith(c *wechat.Client, token string, fn func(item *NewItem) error) error {
+	return paging.Walk(func(offset int) (int, int, error) {
+		list, err := GetNewsWith(c, token, MaxBatchCount, offset)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := range list.Item {
+			err = fn(&list.Item[i])
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(list.Item), list.TotalCount, nil
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/orivil/wechat"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrMediaTooLarge = errors.New("素材文件大小超过限制")
+	ErrMediaFormat   = errors.New("素材文件格式错误")
+)
+
+// 素材文件的限制
+type mediaFormat struct {
+	maxSize int64
+
+	// 扩展名
+	extensions []string
+
+	// 通过 detectContentType 检测出的文件类型
+	contentTypes []string
+}
+
+var mediaFormats = map[MediaType]*mediaFormat{
+	IMAGE: {10 << 20, []string{".bmp", ".png", ".jpeg", ".jpg", ".gif"}, []string{"image/bmp", "image/png", "image/jpeg", "image/gif"}},
+	// amr 及 wma 格式无法通过 http.DetectContentType 检测, 见 detectContentType
+	VOICE: {2 << 20, []string{".mp3", ".wma", ".wav", ".amr"}, []string{"audio/mpeg", "audio/wave", "audio/amr", "audio/x-ms-wma"}},
+	VIDEO: {10 << 20, []string{".mp4"}, []string{"video/mp4"}},
+	THUMB: {64 << 10, []string{".jpg", ".jpeg"}, []string{"image/jpeg"}},
+}
+
+// 素材文件的大小限制, 图文(news)等不能上传文件的类型返回 0
+func (t MediaType) MaxSize() int64 {
+	if f, ok := mediaFormats[t]; ok {
+		return f.maxSize
+	}
+	return 0
+}
+
+// CheckMediaFile 按素材类型校验文件扩展名及文件大小, size 未知时为 -1, 此时只校验扩展名
+func CheckMediaFile(mediaType MediaType, filename string, size int64) error {
+	f, ok := mediaFormats[mediaType]
+	if !ok {
+		return errors.Wrapf(ErrMediaFormat, "%s 类型的素材不能上传文件", mediaType)
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !containsString(f.extensions, ext) {
+		return errors.Wrapf(ErrMediaFormat, "%s: %s 类型的素材只支持 %s 格式", filename, mediaType, strings.Join(f.extensions, ", "))
+	}
+	if size > f.maxSize {
+		return errors.Wrapf(ErrMediaTooLarge, "%s: %d 字节, %s 类型的素材不能超过 %d 字节", filename, size, mediaType, f.maxSize)
+	}
+	return nil
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 获得剩余数据的长度, 未知时返回 -1
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *io.SectionReader:
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return v.Size() - offset
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// 超过限制时返回 ErrMediaTooLarge 的 reader
+type limitedReader struct {
+	r        io.Reader
+	filename string
+	n, max   int64
+}
+
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+	n, err = l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, errors.Wrapf(ErrMediaTooLarge, "%s: 超过 %d 字节", l.filename, l.max)
+	}
+	return n, err
+}
+
+// checkMedia 在上传之前校验文件扩展名、大小及文件头, 返回用于上传的 reader 及文件大小(未知时为 -1).
+// 大小未知的文件在上传过程中超过限制时, 上传将中止并返回 ErrMediaTooLarge
+func checkMedia(mediaType MediaType, r io.Reader, filename string) (io.Reader, int64, error) {
+	size := readerSize(r)
+	err := CheckMediaFile(mediaType, filename, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	f := mediaFormats[mediaType]
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	contentType := detectContentType(head)
+	if !containsString(f.contentTypes, contentType) {
+		return nil, 0, errors.Wrapf(ErrMediaFormat, "%s: 文件内容为 %s, 与 %s 类型的素材不符", filename, contentType, mediaType)
+	}
+	return &limitedReader{r: br, filename: filename, max: f.maxSize}, size, nil
+}
+
+// 文件头
+var (
+	amrMagic = []byte("#!AMR")
+
+	// ASF 头对象的 GUID, wma 文件以此开头
+	asfMagic = []byte{0x30, 0x26, 0xb2, 0x75, 0x8e, 0x66, 0xcf, 0x11, 0xa6, 0xd9, 0x00, 0xaa, 0x00, 0x62, 0xce, 0x6c}
+)
+
+// 检测文件类型, 在 http.DetectContentType 的基础上识别 amr 及 wma 格式
+func detectContentType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, amrMagic):
+		return "audio/amr"
+	case bytes.HasPrefix(head, asfMagic):
+		return "audio/x-ms-wma"
+	}
+	return http.DetectContentType(head)
+}
+
+// 校验并上传素材文件
+func uploadMedia(c *wechat.Client, uri string, mediaType MediaType, r io.Reader, filename string, values url.Values, res interface{}) error {
+	r, size, err := checkMedia(mediaType, r, filename)
+	if err != nil {
+		return err
+	}
+	return c.UploadReader(uri, r, size, "media", filename, values, res)
+}
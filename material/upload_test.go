@@ -0,0 +1,73 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package material
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCheckMedia(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00")
+	amr := []byte("#!AMR\n\x00\x00")
+	wma := append(append([]byte{}, asfMagic...), 0, 0)
+	mp3 := []byte("ID3\x03\x00\x00\x00")
+	cases := []struct {
+		name      string
+		mediaType MediaType
+		filename  string
+		data      []byte
+		want      error
+	}{
+		{"png", IMAGE, "a.PNG", png, nil},
+		{"amr", VOICE, "a.amr", amr, nil},
+		{"wma", VOICE, "a.wma", wma, nil},
+		{"mp3", VOICE, "a.mp3", mp3, nil},
+		{"extension", IMAGE, "a.txt", png, ErrMediaFormat},
+		{"news", NEWS, "a.png", png, ErrMediaFormat},
+		{"content mismatch", IMAGE, "a.jpg", amr, ErrMediaFormat},
+		// 未知格式的语音文件不再通过 application/octet-stream 放行
+		{"unknown voice", VOICE, "a.amr", []byte{0, 1, 2, 3}, ErrMediaFormat},
+		{"too large", THUMB, "a.jpg", append([]byte("\xff\xd8\xff"), make([]byte, 64<<10)...), ErrMediaTooLarge},
+	}
+	for _, c := range cases {
+		r, size, err := checkMedia(c.mediaType, bytes.NewReader(c.data), c.filename)
+		if errors.Cause(err) != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if size != int64(len(c.data)) {
+			t.Errorf("%s: got size %d, want %d", c.name, size, len(c.data))
+		}
+		// 检测文件头之后仍能读取完整数据
+		data, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(data, c.data) {
+			t.Errorf("%s: got %q, %v", c.name, data, err)
+		}
+	}
+}
+
+func TestCheckMediaStream(t *testing.T) {
+	// 大小未知的文件在读取时超过限制
+	data := append([]byte("\xff\xd8\xff"), make([]byte, 64<<10)...)
+	r, size, err := checkMedia(THUMB, io.MultiReader(bytes.NewReader(data)), "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != -1 {
+		t.Errorf("got size %d, want -1", size)
+	}
+	_, err = ioutil.ReadAll(r)
+	if errors.Cause(err) != ErrMediaTooLarge {
+		t.Errorf("got %v, want ErrMediaTooLarge", err)
+	}
+}
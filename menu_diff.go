@@ -0,0 +1,98 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MenuLines 将菜单按固定格式输出为文本行, 每个菜单一行, 用于比较及显示菜单. ms 为 nil 时返回 nil.
+// 包含子菜单的一级菜单只输出标题, 与微信忽略其响应动作的行为一致.
+func MenuLines(ms *Menus) []string {
+	if ms == nil {
+		return nil
+	}
+	var lines []string
+	for i, b := range ms.Buttons {
+		path := fmt.Sprintf("button[%d]", i)
+		if b == nil {
+			continue
+		}
+		if len(b.SubButtons) > 0 {
+			lines = append(lines, fmt.Sprintf("%s name=%q", path, b.Name))
+			for j, sb := range b.SubButtons {
+				if sb != nil {
+					lines = append(lines, menuButtonLine(fmt.Sprintf("  %s.sub_button[%d]", path, j), sb))
+				}
+			}
+		} else {
+			lines = append(lines, menuButtonLine(path, b))
+		}
+	}
+	return lines
+}
+
+func menuButtonLine(path string, b *MenuButton) string {
+	line := fmt.Sprintf("%s type=%s name=%q", path, b.Type, b.Name)
+	for _, f := range []struct{ name, value string }{
+		{"key", b.Key},
+		{"url", b.Url},
+		{"media_id", b.MediaID},
+		{"article_id", b.ArticleID},
+		{"appid", b.AppID},
+		{"pagepath", b.PagePath},
+	} {
+		if f.value != "" {
+			line += fmt.Sprintf(" %s=%q", f.name, f.value)
+		}
+	}
+	return line
+}
+
+// DiffMenus 比较当前菜单 current 及目标菜单 desired, 返回逐行的差异, 删除的行以 "- " 开头, 新增的行以 "+ " 开头,
+// 未变化的行以 "  " 开头. 两个菜单相同时返回空字符串. current 为 nil 表示当前没有菜单.
+func DiffMenus(current, desired *Menus) string {
+	a, b := MenuLines(current), MenuLines(desired)
+	// 最长公共子序列
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var buf strings.Builder
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			buf.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			buf.WriteString("- " + a[i] + "\n")
+			changed = true
+			i++
+		default:
+			buf.WriteString("+ " + b[j] + "\n")
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return buf.String()
+}
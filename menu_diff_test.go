@@ -0,0 +1,75 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMenuLines(t *testing.T) {
+	ms := &Menus{Buttons: []*MenuButton{
+		{Type: MenuButtonClick, Name: "歌曲", Key: "V1001"},
+		// 包含子菜单时忽略一级菜单的响应动作
+		{Type: MenuButtonClick, Name: "菜单", Key: "IGNORED", SubButtons: []*MenuButton{
+			{Type: MenuButtonView, Name: "搜索", Url: "http://www.soso.com/"},
+		}},
+	}}
+	want := []string{
+		`button[0] type=click name="歌曲" key="V1001"`,
+		`button[1] name="菜单"`,
+		`  button[1].sub_button[0] type=view name="搜索" url="http://www.soso.com/"`,
+	}
+	if got := MenuLines(ms); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if MenuLines(nil) != nil {
+		t.Error("nil menus should have no lines")
+	}
+}
+
+func TestDiffMenus(t *testing.T) {
+	a := &MenuButton{Type: MenuButtonClick, Name: "a", Key: "a"}
+	b := &MenuButton{Type: MenuButtonClick, Name: "b", Key: "b"}
+	c := &MenuButton{Type: MenuButtonClick, Name: "c", Key: "c"}
+	b2 := &MenuButton{Type: MenuButtonView, Name: "b", Url: "http://b"}
+	menus := func(buttons ...*MenuButton) *Menus {
+		return &Menus{Buttons: buttons}
+	}
+	cases := []struct {
+		name             string
+		current, desired *Menus
+		want             string
+	}{
+		{"same", menus(a, b), menus(a, b), ""},
+		{"both nil", nil, nil, ""},
+		{"create", nil, menus(a), "+ button[0] type=click name=\"a\" key=\"a\"\n"},
+		{"delete", menus(a), nil, "- button[0] type=click name=\"a\" key=\"a\"\n"},
+		{
+			"change",
+			menus(a, b, c),
+			menus(a, b2, c),
+			"  button[0] type=click name=\"a\" key=\"a\"\n" +
+				"- button[1] type=click name=\"b\" key=\"b\"\n" +
+				"+ button[1] type=view name=\"b\" url=\"http://b\"\n" +
+				"  button[2] type=click name=\"c\" key=\"c\"\n",
+		},
+		{
+			// 位置变化的菜单视为不同
+			"reorder",
+			menus(a, b),
+			menus(b, a),
+			"- button[0] type=click name=\"a\" key=\"a\"\n" +
+				"- button[1] type=click name=\"b\" key=\"b\"\n" +
+				"+ button[0] type=click name=\"b\" key=\"b\"\n" +
+				"+ button[1] type=click name=\"a\" key=\"a\"\n",
+		},
+	}
+	for _, c := range cases {
+		if got := DiffMenus(c.current, c.desired); got != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ButtonType 是菜单的响应动作类型, 即 MenuButton.Type 的取值
+type ButtonType string
+
+const (
+	MenuButtonClick           ButtonType = "click"                // 点击推事件
+	MenuButtonView            ButtonType = "view"                 // 跳转URL
+	MenuButtonScanCodePush    ButtonType = "scancode_push"        // 扫码推事件
+	MenuButtonScanCodeWaitMsg ButtonType = "scancode_waitmsg"     // 扫码推事件且弹出“消息接收中”提示框
+	MenuButtonPicSysPhoto     ButtonType = "pic_sysphoto"         // 弹出系统拍照发图
+	MenuButtonPicPhotoOrAlbum ButtonType = "pic_photo_or_album"   // 弹出拍照或者相册发图
+	MenuButtonPicWeixin       ButtonType = "pic_weixin"           // 弹出微信相册发图器
+	MenuButtonLocationSelect  ButtonType = "location_select"      // 弹出地理位置选择器
+	MenuButtonMediaID         ButtonType = "media_id"             // 下发消息（除文本消息）
+	MenuButtonViewLimited     ButtonType = "view_limited"         // 跳转图文消息URL
+	MenuButtonArticleID       ButtonType = "article_id"           // 下发已发布的图文消息
+	MenuButtonArticleLimited  ButtonType = "article_view_limited" // 跳转已发布的图文消息URL
+	MenuButtonMiniProgram     ButtonType = "miniprogram"          // 跳转小程序
+)
+
+// 菜单限制
+const (
+	MaxMenuButtons      = 3    // 一级菜单最多3个
+	MaxMenuSubButtons   = 5    // 二级菜单最多5个
+	MaxMenuNameBytes    = 16   // 一级菜单标题不超过16个字节
+	MaxMenuSubNameBytes = 60   // 二级菜单标题不超过60个字节
+	MaxMenuKeyBytes     = 128  // 菜单KEY值不超过128字节
+	MaxMenuUrlBytes     = 1024 // 网页链接不超过1024字节
+)
+
+// 各类型菜单的必填字段
+var menuButtonRequired = map[ButtonType][]string{
+	MenuButtonClick:           {"key"},
+	MenuButtonView:            {"url"},
+	MenuButtonScanCodePush:    {"key"},
+	MenuButtonScanCodeWaitMsg: {"key"},
+	MenuButtonPicSysPhoto:     {"key"},
+	MenuButtonPicPhotoOrAlbum: {"key"},
+	MenuButtonPicWeixin:       {"key"},
+	MenuButtonLocationSelect:  {"key"},
+	MenuButtonMediaID:         {"media_id"},
+	MenuButtonViewLimited:     {"media_id"},
+	MenuButtonArticleID:       {"article_id"},
+	MenuButtonArticleLimited:  {"article_id"},
+	MenuButtonMiniProgram:     {"url", "appid", "pagepath"},
+}
+
+func (b *MenuButton) field(name string) string {
+	switch name {
+	case "key":
+		return b.Key
+	case "url":
+		return b.Url
+	case "media_id":
+		return b.MediaID
+	case "article_id":
+		return b.ArticleID
+	case "appid":
+		return b.AppID
+	case "pagepath":
+		return b.PagePath
+	}
+	return ""
+}
+
+// MenuError 是菜单定义中的一处错误, Path 为错误字段的位置, 如 "button[1].sub_button[0].key"
+type MenuError struct {
+	Path    string
+	Message string
+}
+
+func (e *MenuError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// MenuErrors 是菜单定义中的所有错误
+type MenuErrors []*MenuError
+
+func (es MenuErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return "菜单定义错误:\n" + strings.Join(msgs, "\n")
+}
+
+type menuValidator struct {
+	errs MenuErrors
+}
+
+func (v *menuValidator) add(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &MenuError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *menuValidator) button(path string, b *MenuButton, sub bool) {
+	if b == nil {
+		v.add(path, "菜单不能为空")
+		return
+	}
+	maxName := MaxMenuNameBytes
+	if sub {
+		maxName = MaxMenuSubNameBytes
+	}
+	if b.Name == "" {
+		v.add(path+".name", "菜单标题不能为空")
+	} else if len(b.Name) > maxName {
+		v.add(path+".name", "菜单标题 %d 字节, 不能超过 %d 字节", len(b.Name), maxName)
+	}
+	if len(b.Key) > MaxMenuKeyBytes {
+		v.add(path+".key", "KEY值 %d 字节, 不能超过 %d 字节", len(b.Key), MaxMenuKeyBytes)
+	}
+	if len(b.Url) > MaxMenuUrlBytes {
+		v.add(path+".url", "链接 %d 字节, 不能超过 %d 字节", len(b.Url), MaxMenuUrlBytes)
+	}
+	if len(b.SubButtons) > 0 {
+		if sub {
+			v.add(path+".sub_button", "二级菜单不能包含子菜单")
+			return
+		}
+		if len(b.SubButtons) > MaxMenuSubButtons {
+			v.add(path+".sub_button", "二级菜单 %d 个, 不能超过 %d 个", len(b.SubButtons), MaxMenuSubButtons)
+		}
+		for i, sb := range b.SubButtons {
+			v.button(fmt.Sprintf("%s.sub_button[%d]", path, i), sb, true)
+		}
+		// 包含子菜单的一级菜单不需要响应动作
+		return
+	}
+	required, ok := menuButtonRequired[b.Type]
+	if !ok {
+		if b.Type == "" {
+			v.add(path+".type", "菜单类型不能为空")
+		} else {
+			v.add(path+".type", "未知的菜单类型 %q", b.Type)
+		}
+		return
+	}
+	for _, name := range required {
+		if b.field(name) == "" {
+			v.add(path+"."+name, "%s 类型的菜单必须设置 %s", b.Type, name)
+		}
+	}
+}
+
+func (v *menuValidator) matchRule(r *MatchRule) {
+	if *r == (MatchRule{}) {
+		v.add("matchrule", "匹配规则不能为空")
+	}
+	if r.Province != "" && r.Country == "" {
+		v.add("matchrule.country", "设置省份时必须设置国家")
+	}
+	if r.City != "" && r.Province == "" {
+		v.add("matchrule.province", "设置城市时必须设置省份")
+	}
+	switch r.Sex {
+	case "", "1", "2":
+	default:
+		v.add("matchrule.sex", "性别只能为 1 或 2")
+	}
+	switch r.ClientPlatformType {
+	case "", "1", "2", "3":
+	default:
+		v.add("matchrule.client_platform_type", "客户端版本只能为 1、2 或 3")
+	}
+}
+
+// Validate 按微信的限制校验菜单定义, 返回包含所有错误的 MenuErrors, 没有错误时返回 nil
+func (ms *Menus) Validate() error {
+	v := &menuValidator{}
+	if len(ms.Buttons) == 0 || len(ms.Buttons) > MaxMenuButtons {
+		v.add("button", "一级菜单 %d 个, 应为 1~%d 个", len(ms.Buttons), MaxMenuButtons)
+	}
+	for i, b := range ms.Buttons {
+		v.button(fmt.Sprintf("button[%d]", i), b, false)
+	}
+	if ms.MatchRule != nil {
+		v.matchRule(ms.MatchRule)
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// ParseMenusJSON 解析 JSON 格式的菜单定义, 格式与创建菜单接口相同
+func ParseMenusJSON(data []byte) (*Menus, error) {
+	ms := &Menus{}
+	err := json.Unmarshal(data, ms)
+	if err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// ParseMenusYAML 解析 YAML 格式的菜单定义, 字段名与 JSON 格式相同, 数字形式的值(如 tag_id)需要加引号:
+//
+//	button:
+//	  - {type: click, name: 今日歌曲, key: V1001_TODAY_MUSIC}
+//	  - name: 菜单
+//	    sub_button:
+//	      - {type: view, name: 搜索, url: "http://www.soso.com/"}
+func ParseMenusYAML(data []byte) (*Menus, error) {
+	data, err := YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMenusJSON(data)
+}
+
+// LoadMenus 按扩展名读取 .json、.yaml 或 .yml 格式的菜单定义文件并校验, 校验失败时返回 MenuErrors
+func LoadMenus(filename string) (*Menus, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var ms *Menus
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		ms, err = ParseMenusJSON(data)
+	case ".yaml", ".yml":
+		ms, err = ParseMenusYAML(data)
+	default:
+		return nil, errors.Errorf("unsupported menu file: %s", filename)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, filename)
+	}
+	err = ms.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func menuErrorPaths(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(MenuErrors)
+	if !ok {
+		t.Fatalf("got %T, want MenuErrors", err)
+	}
+	paths := make([]string, len(errs))
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+	return paths
+}
+
+func TestMenusValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		menus *Menus
+		paths []string
+	}{
+		{
+			name: "valid",
+			menus: &Menus{Buttons: []*MenuButton{
+				{Type: MenuButtonClick, Name: "今日歌曲", Key: "V1001"},
+				{Name: "菜单", SubButtons: []*MenuButton{
+					{Type: MenuButtonView, Name: "搜索", Url: "http://www.soso.com/"},
+					{Type: MenuButtonMiniProgram, Name: "小程序", Url: "http://mp.weixin.qq.com", AppID: "wx1", PagePath: "pages/index"},
+				}},
+			}},
+		},
+		{
+			name:  "no buttons",
+			menus: &Menus{},
+			paths: []string{"button"},
+		},
+		{
+			name: "too many buttons",
+			menus: &Menus{Buttons: []*MenuButton{
+				{Type: "click", Name: "1", Key: "1"},
+				{Type: "click", Name: "2", Key: "2"},
+				{Type: "click", Name: "3", Key: "3"},
+				{Type: "click", Name: "4", Key: "4"},
+			}},
+			paths: []string{"button"},
+		},
+		{
+			name: "button fields",
+			menus: &Menus{Buttons: []*MenuButton{
+				nil,
+				{Type: "click", Name: strings.Repeat("a", MaxMenuNameBytes+1), Key: strings.Repeat("k", MaxMenuKeyBytes+1)},
+				{Type: "unknown", Name: "x"},
+			}},
+			paths: []string{"button[0]", "button[1].name", "button[1].key", "button[2].type"},
+		},
+		{
+			name: "required fields",
+			menus: &Menus{Buttons: []*MenuButton{
+				{Name: "x"},
+				{Type: MenuButtonView, Name: "y"},
+				{Type: MenuButtonMiniProgram, Name: "z", Url: "http://a"},
+			}},
+			paths: []string{"button[0].type", "button[1].url", "button[2].appid", "button[2].pagepath"},
+		},
+		{
+			name: "sub buttons",
+			menus: &Menus{Buttons: []*MenuButton{
+				{Name: "x", SubButtons: []*MenuButton{
+					{Type: "click", Name: "1", Key: "1"},
+					{Type: "click", Name: "2", Key: "2"},
+					{Type: "click", Name: "3", Key: "3"},
+					{Type: "click", Name: "4", Key: "4"},
+					{Type: "click", Name: "5", Key: "5"},
+					{Type: "click", Name: "6"},
+				}},
+				{Name: "y", SubButtons: []*MenuButton{
+					{Name: strings.Repeat("a", MaxMenuSubNameBytes), SubButtons: []*MenuButton{{Type: "click", Name: "z", Key: "z"}}},
+				}},
+			}},
+			paths: []string{"button[0].sub_button", "button[0].sub_button[5].key", "button[1].sub_button[0].sub_button"},
+		},
+		{
+			name: "match rule",
+			menus: &Menus{
+				Buttons:   []*MenuButton{{Type: "click", Name: "x", Key: "x"}},
+				MatchRule: &MatchRule{City: "广州", Sex: "3", ClientPlatformType: "4"},
+			},
+			paths: []string{"matchrule.province", "matchrule.sex", "matchrule.client_platform_type"},
+		},
+		{
+			name: "empty match rule",
+			menus: &Menus{
+				Buttons:   []*MenuButton{{Type: "click", Name: "x", Key: "x"}},
+				MatchRule: &MatchRule{},
+			},
+			paths: []string{"matchrule"},
+		},
+	}
+	for _, c := range cases {
+		paths := menuErrorPaths(t, c.menus.Validate())
+		if !reflect.DeepEqual(paths, c.paths) {
+			t.Errorf("%s: got %q, want %q", c.name, paths, c.paths)
+		}
+	}
+}
+
+func TestLoadMenus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlFile := filepath.Join(dir, "menu.yaml")
+	err = ioutil.WriteFile(yamlFile, []byte(`
+button:
+  - {type: click, name: 今日歌曲, key: V1001_TODAY_MUSIC}
+  - name: 菜单
+    sub_button:
+      - {type: view, name: 搜索, url: "http://www.soso.com/"}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := LoadMenus(yamlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms.Buttons) != 2 || ms.Buttons[0].Key != "V1001_TODAY_MUSIC" || ms.Buttons[1].SubButtons[0].Url != "http://www.soso.com/" {
+		t.Errorf("unexpected menus: %+v", ms)
+	}
+
+	jsonFile := filepath.Join(dir, "menu.json")
+	err = ioutil.WriteFile(jsonFile, []byte(`{"button": [{"type": "view", "name": "x"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadMenus(jsonFile)
+	if paths := menuErrorPaths(t, err); !reflect.DeepEqual(paths, []string{"button[0].url"}) {
+		t.Errorf("got %q", paths)
+	}
+
+	_, err = LoadMenus(filepath.Join(dir, "menu.txt"))
+	if err == nil {
+		t.Error("expected error for unsupported file")
+	}
+}
@@ -4,20 +4,59 @@
 
 package wechat
 
+import "encoding/json"
+
+// 自定义菜单接口, see: https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Creating_Custom-Defined_Menu.html
+
 type Menus struct {
 	Buttons []*MenuButton `json:"button"`
+
+	// 个性化菜单的匹配规则, 只用于个性化菜单
+	MatchRule *MatchRule `json:"matchrule,omitempty"`
+
+	// 菜单ID, 查询菜单时返回
+	MenuID json.Number `json:"menuid,omitempty"`
+}
+
+// 个性化菜单的匹配规则, 至少设置一个字段, 未设置的字段不参与匹配
+type MatchRule struct {
+	// 用户标签的id，可通过用户标签管理接口获取
+	TagID string `json:"tag_id,omitempty"`
+
+	// 性别：男（1）女（2）
+	Sex string `json:"sex,omitempty"`
+
+	// 客户端版本，当前只具体到系统型号：IOS(1), Android(2),Others(3)
+	ClientPlatformType string `json:"client_platform_type,omitempty"`
+
+	// 国家信息，是用户在微信中设置的地区
+	Country string `json:"country,omitempty"`
+
+	// 省份信息，是用户在微信中设置的地区, 设置时必须同时设置 Country
+	Province string `json:"province,omitempty"`
+
+	// 城市信息，是用户在微信中设置的地区, 设置时必须同时设置 Country 及 Province
+	City string `json:"city,omitempty"`
+
+	// 语言信息，是用户在微信中设置的语言，如 zh_CN、zh_TW、en 等
+	Language string `json:"language,omitempty"`
 }
 
 // 生成公众号菜单
 func GenerateMenus(accessToken string, ms *Menus) error {
+	return GenerateMenusWith(DefaultClient, accessToken, ms)
+}
+
+// GenerateMenusWith 同 GenerateMenus, 通过客户端 c 发送请求
+func GenerateMenusWith(c *Client, accessToken string, ms *Menus) error {
 	// 提交到公众号平台
 	u := "https://api.weixin.qq.com/cgi-bin/menu/create?access_token=" + accessToken
-	return PostSchema(KindJson, u, ms, nil)
+	return c.PostSchema(KindJson, u, ms, nil)
 }
 
 type MenuButton struct {
-	// 菜单的响应动作类型，view表示网页类型，click表示点击类型，miniprogram表示小程序类型
-	Type string `json:"type"`
+	// 菜单的响应动作类型，view表示网页类型，click表示点击类型，miniprogram表示小程序类型, 见 MenuButtonClick 等常量
+	Type ButtonType `json:"type"`
 
 	// 	菜单标题，不超过16个字节，子菜单不超过60个字节
 	Name string `json:"name"`
@@ -40,4 +79,181 @@ type MenuButton struct {
 
 	// 小程序的页面路径
 	PagePath string `json:"pagepath"`
+
+	// article_id类型和article_view_limited类型必须, 发布后获得的合法 article_id
+	ArticleID string `json:"article_id,omitempty"`
+}
+
+// 通过接口设置的菜单, 包括默认菜单及个性化菜单
+type MenuConfig struct {
+	// 默认菜单
+	Menu *Menus `json:"menu"`
+
+	// 个性化菜单
+	ConditionalMenus []*Menus `json:"conditionalmenu"`
+}
+
+// 查询通过接口设置的菜单, 未创建菜单时返回 ErrCodeMenuNotExist 错误
+func GetMenus(accessToken string) (config *MenuConfig, err error) {
+	return GetMenusWith(DefaultClient, accessToken)
+}
+
+// GetMenusWith 同 GetMenus, 通过客户端 c 发送请求
+func GetMenusWith(c *Client, accessToken string) (config *MenuConfig, err error) {
+	u := "https://api.weixin.qq.com/cgi-bin/menu/get?access_token=" + accessToken
+	config = &MenuConfig{}
+	err = c.GetJson(u, config)
+	if err != nil {
+		return nil, err
+	} else {
+		return config, nil
+	}
+}
+
+// 公众号当前使用的菜单, 包括在公众平台官网设置的菜单
+type SelfMenuInfo struct {
+	// 菜单是否开启，0代表未开启，1代表开启
+	IsMenuOpen int `json:"is_menu_open"`
+
+	Buttons []*SelfMenuButton `json:"-"`
+}
+
+func (si *SelfMenuInfo) UnmarshalJSON(data []byte) error {
+	res := &struct {
+		IsMenuOpen   int `json:"is_menu_open"`
+		SelfMenuInfo struct {
+			Buttons []*SelfMenuButton `json:"button"`
+		} `json:"selfmenu_info"`
+	}{}
+	err := json.Unmarshal(data, res)
+	if err != nil {
+		return err
+	}
+	si.IsMenuOpen = res.IsMenuOpen
+	si.Buttons = res.SelfMenuInfo.Buttons
+	return nil
+}
+
+type SelfMenuButton struct {
+	// 菜单类型, 除接口设置的菜单类型外, 官网设置的菜单可能为 text、img、voice、video、news 类型.
+	// 可转换为 ButtonType 后与 MenuButtonClick 等常量比较
+	Type string `json:"type"`
+
+	Name string `json:"name"`
+
+	// click 等类型的 KEY 值
+	Key string `json:"key,omitempty"`
+
+	// view 类型的网页链接
+	Url string `json:"url,omitempty"`
+
+	// 官网设置的菜单: text 类型为文本内容, img、voice 类型为 mediaID, video 类型为视频下载链接, news 类型为图文消息的 mediaID
+	Value string `json:"value,omitempty"`
+
+	// 小程序的appid及页面路径
+	AppID    string `json:"appid,omitempty"`
+	PagePath string `json:"pagepath,omitempty"`
+
+	// 图文消息的信息, 只有 news 类型有该字段
+	NewsInfo *SelfMenuNewsInfo `json:"news_info,omitempty"`
+
+	// 二级菜单
+	SubButton *SelfMenuSubButton `json:"sub_button,omitempty"`
+}
+
+type SelfMenuSubButton struct {
+	List []*SelfMenuButton `json:"list"`
+}
+
+type SelfMenuNewsInfo struct {
+	List []*SelfMenuNews `json:"list"`
+}
+
+type SelfMenuNews struct {
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	Digest     string `json:"digest"`
+	ShowCover  int    `json:"show_cover"`
+	CoverUrl   string `json:"cover_url"`
+	ContentUrl string `json:"content_url"`
+	SourceUrl  string `json:"source_url"`
+}
+
+// 查询公众号当前使用的自定义菜单, 包括在公众平台官网通过网站功能发布的菜单
+func GetCurrentSelfMenuInfo(accessToken string) (info *SelfMenuInfo, err error) {
+	return GetCurrentSelfMenuInfoWith(DefaultClient, accessToken)
+}
+
+// GetCurrentSelfMenuInfoWith 同 GetCurrentSelfMenuInfo, 通过客户端 c 发送请求
+func GetCurrentSelfMenuInfoWith(c *Client, accessToken string) (info *SelfMenuInfo, err error) {
+	u := "https://api.weixin.qq.com/cgi-bin/get_current_selfmenu_info?access_token=" + accessToken
+	info = &SelfMenuInfo{}
+	err = c.GetJson(u, info)
+	if err != nil {
+		return nil, err
+	} else {
+		return info, nil
+	}
+}
+
+// 删除所有菜单, 包括默认菜单及全部个性化菜单
+func DeleteMenus(accessToken string) error {
+	return DeleteMenusWith(DefaultClient, accessToken)
+}
+
+// DeleteMenusWith 同 DeleteMenus, 通过客户端 c 发送请求
+func DeleteMenusWith(c *Client, accessToken string) error {
+	u := "https://api.weixin.qq.com/cgi-bin/menu/delete?access_token=" + accessToken
+	data, err := c.Get(u)
+	if err != nil {
+		return err
+	}
+	return ParseError(data)
+}
+
+// 创建个性化菜单, ms.MatchRule 不能为空. 创建个性化菜单之前必须先创建默认菜单
+func AddConditionalMenus(accessToken string, ms *Menus) (menuID string, err error) {
+	return AddConditionalMenusWith(DefaultClient, accessToken, ms)
+}
+
+// AddConditionalMenusWith 同 AddConditionalMenus, 通过客户端 c 发送请求
+func AddConditionalMenusWith(c *Client, accessToken string, ms *Menus) (menuID string, err error) {
+	u := "https://api.weixin.qq.com/cgi-bin/menu/addconditional?access_token=" + accessToken
+	res := &struct {
+		MenuID json.Number `json:"menuid"`
+	}{}
+	err = c.PostSchema(KindJson, u, ms, res)
+	if err != nil {
+		return "", err
+	} else {
+		return res.MenuID.String(), nil
+	}
+}
+
+// 删除个性化菜单
+func DeleteConditionalMenus(accessToken, menuID string) error {
+	return DeleteConditionalMenusWith(DefaultClient, accessToken, menuID)
+}
+
+// DeleteConditionalMenusWith 同 DeleteConditionalMenus, 通过客户端 c 发送请求
+func DeleteConditionalMenusWith(c *Client, accessToken, menuID string) error {
+	u := "https://api.weixin.qq.com/cgi-bin/menu/delconditional?access_token=" + accessToken
+	return c.PostSchema(KindJson, u, map[string]string{"menuid": menuID}, nil)
+}
+
+// 测试个性化菜单匹配结果, userID 可以是粉丝的 OpenID, 也可以是粉丝的微信号
+func TryMatchMenus(accessToken, userID string) (ms *Menus, err error) {
+	return TryMatchMenusWith(DefaultClient, accessToken, userID)
+}
+
+// TryMatchMenusWith 同 TryMatchMenus, 通过客户端 c 发送请求
+func TryMatchMenusWith(c *Client, accessToken, userID string) (ms *Menus, err error) {
+	u := "https://api.weixin.qq.com/cgi-bin/menu/trymatch?access_token=" + accessToken
+	ms = &Menus{}
+	err = c.Idempotent().PostSchema(KindJson, u, map[string]string{"user_id": userID}, ms)
+	if err != nil {
+		return nil, err
+	} else {
+		return ms, nil
+	}
 }
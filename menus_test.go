@@ -0,0 +1,133 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat_test
+
+import (
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func TestMenus(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	srv.AddUsers("wx123",
+		&wechattest.User{Openid: "o1", Subscribe: 1, TagIDList: []int{2}},
+		&wechattest.User{Openid: "o2", Subscribe: 1},
+	)
+	c := srv.Client()
+	at, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := at.Value
+
+	// 未创建菜单
+	_, err = wechat.GetMenusWith(c, token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeMenuNotExist {
+		t.Fatalf("got %v, want menu not exist", err)
+	}
+	info, err := wechat.GetCurrentSelfMenuInfoWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IsMenuOpen != 0 || len(info.Buttons) != 0 {
+		t.Errorf("got self menu info %+v", info)
+	}
+
+	menus := &wechat.Menus{Buttons: []*wechat.MenuButton{
+		{Type: wechat.MenuButtonClick, Name: "歌曲", Key: "V1001"},
+		{Name: "菜单", SubButtons: []*wechat.MenuButton{
+			{Type: wechat.MenuButtonView, Name: "搜索", Url: "http://www.soso.com/"},
+		}},
+	}}
+	err = wechat.GenerateMenusWith(c, token, menus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := wechat.GetMenusWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := wechat.DiffMenus(config.Menu, menus); diff != "" || config.Menu.MenuID == "" {
+		t.Errorf("got menu %s, diff:\n%s", config.Menu.MenuID, diff)
+	}
+	info, err = wechat.GetCurrentSelfMenuInfoWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IsMenuOpen != 1 || len(info.Buttons) != 2 || info.Buttons[0].Key != "V1001" {
+		t.Fatalf("got self menu info %+v", info)
+	}
+	if sub := info.Buttons[1].SubButton; sub == nil || len(sub.List) != 1 || sub.List[0].Url != "http://www.soso.com/" {
+		t.Errorf("got sub buttons %+v", sub)
+	}
+
+	// 个性化菜单
+	conditional := &wechat.Menus{
+		Buttons:   []*wechat.MenuButton{{Type: wechat.MenuButtonClick, Name: "会员", Key: "VIP"}},
+		MatchRule: &wechat.MatchRule{TagID: "2"},
+	}
+	menuID, err := wechat.AddConditionalMenusWith(c, token, conditional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = wechat.GetMenusWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.ConditionalMenus) != 1 {
+		t.Fatalf("got %d conditional menus, want 1", len(config.ConditionalMenus))
+	}
+	if cm := config.ConditionalMenus[0]; cm.MenuID.String() != menuID || cm.MatchRule == nil || cm.MatchRule.TagID != "2" {
+		t.Errorf("got conditional menu %+v", cm)
+	}
+
+	matched, err := wechat.TryMatchMenusWith(c, token, "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched.Buttons) != 1 || matched.Buttons[0].Key != "VIP" {
+		t.Errorf("o1: got %s", wechat.MenuLines(matched))
+	}
+	matched, err = wechat.TryMatchMenusWith(c, token, "o2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := wechat.DiffMenus(matched, menus); diff != "" {
+		t.Errorf("o2: want default menu, diff:\n%s", diff)
+	}
+	_, err = wechat.TryMatchMenusWith(c, token, "unknown")
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidOpenid {
+		t.Errorf("unknown user: got %v", err)
+	}
+
+	err = wechat.DeleteConditionalMenusWith(c, token, menuID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = wechat.DeleteConditionalMenusWith(c, token, menuID); err == nil {
+		t.Error("deleted conditional menu should not exist")
+	}
+	if n := len(srv.App("wx123").ConditionalMenus); n != 0 {
+		t.Errorf("got %d conditional menus after delete", n)
+	}
+
+	err = wechat.DeleteMenusWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wechat.GetMenusWith(c, token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeMenuNotExist {
+		t.Errorf("after delete: got %v, want menu not exist", err)
+	}
+	// 没有默认菜单时不能创建个性化菜单
+	if _, err = wechat.AddConditionalMenusWith(c, token, conditional); err == nil {
+		t.Error("want error without default menu")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/platform"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAsyncQueueFull = errors.New("异步回复队列已满")
+	ErrAsyncClosed    = errors.New("异步回复已关闭")
+)
+
+const (
+	// 默认工作协程数量
+	DefaultAsyncWorkers = 16
+
+	// 默认队列长度
+	DefaultAsyncQueueSize = 1024
+)
+
+// AsyncReply 是消息服务器的异步回复模式. 服务器收到消息后立即响应 "success", 由工作协程调用 Handler,
+// 并将 Handler 返回的被动回复消息转换为客服消息发送给用户, 适用于处理时间可能超过 5 秒的 Handler.
+// 注意客服消息只能在用户与公众号互动后的 48 小时内发送.
+type AsyncReply struct {
+	// 获取发送客服消息所需的令牌
+	Access *platform.AccessContainer
+
+	// 公众号 appid
+	Appid string
+
+	// 获取消息所属公众号的 appid, 用于第三方平台同时接收多个公众号的消息, 不为 nil 时忽略 Appid
+	AppidFunc func(req *Request) (appid string, err error)
+
+	// 工作协程数量, 为 0 时为 DefaultAsyncWorkers
+	Workers int
+
+	// 等待处理的消息队列长度, 为 0 时为 DefaultAsyncQueueSize. 队列已满时服务器以 503 及 ErrAsyncQueueFull
+	// 调用 Server.ErrorHandler, 微信将重试该消息
+	QueueSize int
+
+	// 每条消息的处理及发送时限, 为 0 时不限制
+	Timeout time.Duration
+
+	// 处理或发送失败时调用, 为 nil 时忽略错误
+	OnError func(req *Request, err error)
+
+	jobs   chan *asyncJob
+	wg     sync.WaitGroup
+	once   sync.Once
+	closed bool
+	mu     sync.RWMutex
+}
+
+type asyncJob struct {
+	handler Handler
+	req     *Request
+}
+
+func NewAsyncReply(access *platform.AccessContainer, appid string) *AsyncReply {
+	return &AsyncReply{Access: access, Appid: appid}
+}
+
+func (a *AsyncReply) start() {
+	workers := a.Workers
+	if workers <= 0 {
+		workers = DefaultAsyncWorkers
+	}
+	size := a.QueueSize
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	a.jobs = make(chan *asyncJob, size)
+	a.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer a.wg.Done()
+			for job := range a.jobs {
+				a.run(job)
+			}
+		}()
+	}
+}
+
+// 将消息加入队列, 队列已满时返回 ErrAsyncQueueFull
+func (a *AsyncReply) submit(h Handler, req *Request) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrAsyncClosed
+	}
+	a.once.Do(a.start)
+	// 请求结束后其 context 将被取消, 异步处理时只保留 context 中的数据
+	req = req.WithContext(detachedContext{req.Context()})
+	select {
+	case a.jobs <- &asyncJob{handler: h, req: req}:
+		return nil
+	default:
+		return ErrAsyncQueueFull
+	}
+}
+
+// Close 停止接收新消息, 并等待队列中的消息处理完毕
+func (a *AsyncReply) Close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	a.once.Do(func() {})
+	jobs := a.jobs
+	a.mu.Unlock()
+	if jobs != nil {
+		close(jobs)
+		a.wg.Wait()
+	}
+}
+
+func (a *AsyncReply) run(job *asyncJob) {
+	req := job.req
+	defer func() {
+		if p := recover(); p != nil {
+			a.error(req, fmt.Errorf("message: async handler panic: %v", p))
+		}
+	}()
+	if a.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), a.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	reply, err := job.handler.ServeMessage(req)
+	if err != nil {
+		a.error(req, err)
+		return
+	}
+	if reply == nil || reply == ReplySuccess {
+		return
+	}
+	err = a.send(req, reply)
+	if err != nil {
+		a.error(req, err)
+	}
+}
+
+// 将被动回复消息转换为客服消息发送给用户
+func (a *AsyncReply) send(req *Request, reply *ResponseMessage) error {
+	appid := a.Appid
+	if a.AppidFunc != nil {
+		var err error
+		appid, err = a.AppidFunc(req)
+		if err != nil {
+			return err
+		}
+	}
+	if reply.MsgType == ResponseMsgTypeTransferCustomerService {
+		return errors.Wrap(ErrReplyNotAllowed, "transfer_customer_service reply in async mode")
+	}
+	msg := reply.ToCustomerMessage()
+	return a.Access.Call(req.Context(), appid, func(c *wechat.Client, token string) error {
+		return msg.SendWith(c, token, req.FromUserName)
+	})
+}
+
+func (a *AsyncReply) error(req *Request, err error) {
+	if a.OnError != nil {
+		a.OnError(req, err)
+	}
+}
+
+// 不会被取消的 context, 只保留 parent 中的数据
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
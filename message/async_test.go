@@ -0,0 +1,132 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/platform"
+	"github.com/orivil/wechat/wechattest"
+)
+
+type memoryExpireStorage struct {
+	data map[string]*platform.ExpireData
+	mu   sync.Mutex
+}
+
+func (m *memoryExpireStorage) Store(key string, data *platform.ExpireData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = data
+	return nil
+}
+
+func (m *memoryExpireStorage) Read(key string) (*platform.ExpireData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memoryExpireStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+// 返回通过 srv 获取令牌的 AccessContainer
+func newTestAccess(srv *wechattest.Server, appid, secret string) *platform.AccessContainer {
+	srv.AddApp(appid, secret)
+	access := platform.NewAccessContainer(
+		platform.NewStorage(newMemoryStorage(), &memoryExpireStorage{data: make(map[string]*platform.ExpireData)}),
+		func(string) (string, error) { return secret, nil },
+		func(string) (string, string, error) { return "", "", nil },
+		func(string) (string, error) { return "", nil },
+	)
+	access.Client = srv.Client()
+	return access
+}
+
+func TestAsyncReply(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	async := message.NewAsyncReply(newTestAccess(srv, "wx123", "secret"), "wx123")
+	srv.AddUsers("wx123", &wechattest.User{Openid: "openid", Subscribe: 1})
+	var errs []error
+	async.OnError = func(req *message.Request, err error) {
+		errs = append(errs, err)
+	}
+	s := message.NewServer(testToken, nil)
+	s.Async = async
+	s.HandleFunc(message.ServerMsgTypeText, replyWith("async"))
+	sim := &wechattest.Simulator{Token: testToken}
+	reply, err := sim.Send(s, wechattest.TextMessage("openid", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != nil {
+		t.Errorf("expected no passive reply, got %+v", reply)
+	}
+	async.Close()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	msgs := srv.App("wx123").CustomMessages
+	if len(msgs) != 1 {
+		t.Fatalf("got %d customer messages, want 1", len(msgs))
+	}
+	msg := &struct {
+		ToUser  string `json:"touser"`
+		MsgType string `json:"msgtype"`
+		Text    struct {
+			Content string `json:"content"`
+		} `json:"text"`
+	}{}
+	err = json.Unmarshal(msgs[0], msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ToUser != "openid" || msg.MsgType != "text" || msg.Text.Content != "async" {
+		t.Errorf("unexpected customer message: %s", msgs[0])
+	}
+}
+
+func TestAsyncReplySubmitFailed(t *testing.T) {
+	async := message.NewAsyncReply(nil, "wx123")
+	async.Close()
+	var handled error
+	s := message.NewServer(testToken, nil)
+	s.Async = async
+	s.Dedup = message.NewMemoryDedupStore()
+	s.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		message.DefaultErrorHandler(w, r, err)
+	}
+	s.HandleFunc(message.ServerMsgTypeText, replyWith("async"))
+	sim := &wechattest.Simulator{Token: testToken}
+	msg := wechattest.TextMessage("openid", "hello")
+	msg.MsgId = 2000
+	for i := 0; i < 2; i++ {
+		req, err := sim.NewRequest(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		// 未加入队列的消息不能响应 "success", 且微信的重试请求不能被去重
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: got status %d, want %d", i, rec.Code, http.StatusServiceUnavailable)
+		}
+		if !errors.Is(handled, message.ErrAsyncClosed) {
+			t.Fatalf("request %d: got error %v, want ErrAsyncClosed", i, handled)
+		}
+	}
+}
@@ -47,17 +47,11 @@ type CustomerMessage struct {
 	CustomService   *CustomService   `json:"customservice,omitempty"`
 }
 
-//48001	api 功能未授权，请确认公众号已获得该接口，可以在公众平台官网 - 开发者中心页中查看接口权限
-//48002	粉丝拒收消息（粉丝在公众号选项中，关闭了 “ 接收消息 ” ）
-//48004	api 接口被封禁，请登录 mp.weixin.qq.com 查看详情
-//48005	api 禁止删除被自动回复和自定义菜单引用的素材
-//48006	api 禁止清零调用次数，因为清零次数达到上限
-//48008	没有该类型消息的发送权限
-
-// 判断是否是终止错误, 即所有同类型的消息都不可发送. 有可能某一个公众号被封号, 导致 API 功能受限, 出现大量错误信息
+// 判断是否是终止错误, 即所有同类型的消息都不可发送. 有可能某一个公众号被封号, 导致 API 功能受限, 出现大量错误信息.
+// 错误码为 48001 ~ 48008, 见 wechat.ErrCodeAPIUnauthorized 等常量
 func IsBreakError(err error) bool {
-	if we, ok := err.(*wechat.Error); ok {
-		if 48001 <= we.ErrCode && we.ErrCode <= 48008 {
+	if code, ok := wechat.ErrCodeOf(err); ok {
+		if wechat.ErrCodeAPIUnauthorized <= code && code <= wechat.ErrCodeNoPermissionForMsgType {
 			return true
 		}
 	}
@@ -66,18 +60,23 @@ func IsBreakError(err error) bool {
 
 // 主动推送客服消息
 func (m *CustomerMessage) Send(accessToken, toUser string) (err error) {
+	return m.SendWith(wechat.DefaultClient, accessToken, toUser)
+}
+
+// SendWith 同 Send, 通过客户端 c 发送请求
+func (m *CustomerMessage) SendWith(c *wechat.Client, accessToken, toUser string) (err error) {
 	if toUser != "" {
 		m.ToUser = toUser
 	}
 	u := "https://api.weixin.qq.com/cgi-bin/message/custom/send?access_token=" + accessToken
-	return wechat.PostSchema(wechat.KindJson, u, m, nil)
+	return c.PostSchema(wechat.KindJson, u, m, nil)
 }
 
 // 是否客服消息常见错误
 func IsCMsgCommonError(err error) bool {
-	if werr, ok := err.(*wechat.Error); ok {
-		switch werr.ErrCode {
-		case 45015 /*超时回复*/, 43004 /*未关注*/, 45047 /*发送条数超过上限*/ :
+	if code, ok := wechat.ErrCodeOf(err); ok {
+		switch code {
+		case wechat.ErrCodeResponseOutOfTime, wechat.ErrCodeRequireSubscribe, wechat.ErrCodeCustomerMsgOutOfLimit:
 			return true
 		}
 	}
@@ -85,13 +84,8 @@ func IsCMsgCommonError(err error) bool {
 }
 
 func IsSysBusyError(err error) bool {
-	if werr, ok := err.(*wechat.Error); ok {
-		switch werr.ErrCode {
-		case -1:
-			return true
-		}
-	}
-	return false
+	code, ok := wechat.ErrCodeOf(err)
+	return ok && code == wechat.ErrCodeSystemBusy
 }
 
 type MediaID struct {
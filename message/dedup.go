@@ -0,0 +1,132 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat/platform"
+)
+
+// 微信服务器在五秒内收不到响应会断掉连接并重新发起请求, 总共重试三次, 所以同一条消息可能被推送多次.
+
+// DefaultDedupTTL 是消息去重记录的默认有效期, 足以覆盖微信服务器的所有重试
+const DefaultDedupTTL = time.Minute
+
+// DedupStore 记录已处理过的消息, 用于过滤微信服务器的重试请求
+type DedupStore interface {
+	// Seen 检查 key 是否在有效期内出现过, 未出现过则记录 key, 有效期为 ttl
+	Seen(key string, ttl time.Duration) (seen bool, err error)
+
+	// Forget 删除 key 的记录, 消息处理失败时调用, 使重试的请求可以再次被处理
+	Forget(key string) error
+}
+
+// DedupKey 返回消息的去重 key, 普通消息使用 MsgId, 事件消息使用 FromUserName 及 CreateTime
+func DedupKey(msg *ServerMessage) string {
+	if msg.MsgId != 0 {
+		return msg.ToUserName + ":" + strconv.FormatInt(msg.MsgId, 10)
+	}
+	return msg.ToUserName + ":" + msg.FromUserName + ":" + strconv.FormatInt(msg.CreateTime, 10)
+}
+
+// MemoryDedupStore 是保存在内存中的 DedupStore, 只适用于单个进程
+type MemoryDedupStore struct {
+	keys      map[string]time.Time
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{keys: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+func (m *MemoryDedupStore) Seen(key string, ttl time.Duration) (seen bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keys == nil {
+		m.keys = make(map[string]time.Time)
+	}
+	now := time.Now()
+	// 定期清理过期的记录
+	if now.Sub(m.lastSweep) > ttl {
+		for k, expireAt := range m.keys {
+			if now.After(expireAt) {
+				delete(m.keys, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	if expireAt, ok := m.keys[key]; ok && now.Before(expireAt) {
+		return true, nil
+	}
+	m.keys[key] = now.Add(ttl)
+	return false, nil
+}
+
+func (m *MemoryDedupStore) Forget(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// AtomicDataStorage 是支持原子写入的存储器, 如使用 Redis 的 "SET key value NX PX ttl" 实现
+type AtomicDataStorage interface {
+	platform.DataStorage
+
+	// StoreIfAbsent 仅在 key 不存在或已过期时保存 value, 有效期为 ttl, 返回是否已保存
+	StoreIfAbsent(key, value string, ttl time.Duration) (stored bool, err error)
+}
+
+// StorageDedupStore 使用 platform.DataStorage 保存去重记录.
+//
+// 存储器实现了 AtomicDataStorage 时通过 StoreIfAbsent 记录 key, 多个进程共用同一个存储器时可以跨进程去重.
+// 否则先读取再写入, 只能保证同一进程内不重复处理, 记录的值为过期时间, 由于 DataStorage 不支持过期,
+// 过期的记录不会被删除, 存储器应自行清理旧数据.
+type StorageDedupStore struct {
+	storage platform.DataStorage
+	prefix  string
+	mu      sync.Mutex
+}
+
+// NewStorageDedupStore 创建 StorageDedupStore, prefix 为 key 的前缀, 用于与存储器中的其他数据区分
+func NewStorageDedupStore(storage platform.DataStorage, prefix string) *StorageDedupStore {
+	return &StorageDedupStore{storage: storage, prefix: prefix}
+}
+
+func (s *StorageDedupStore) Seen(key string, ttl time.Duration) (seen bool, err error) {
+	key = s.prefix + key
+	now := time.Now()
+	expireAt := strconv.FormatInt(now.Add(ttl).UnixNano(), 10)
+	if as, ok := s.storage.(AtomicDataStorage); ok {
+		stored, err := as.StoreIfAbsent(key, expireAt, ttl)
+		if err != nil {
+			return false, err
+		}
+		return !stored, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, err := s.storage.Read(key)
+	if err != nil {
+		return false, err
+	}
+	if value != "" {
+		expireAt, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && now.UnixNano() < expireAt {
+			return true, nil
+		}
+	}
+	return false, s.storage.Store(key, expireAt)
+}
+
+func (s *StorageDedupStore) Forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.storage.Del(s.prefix + key)
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func TestDedupKey(t *testing.T) {
+	text := &message.ServerMessage{ToUserName: "gh_1", FromUserName: "openid", CreateTime: 100, MsgId: 123}
+	if got, want := message.DedupKey(text), "gh_1:123"; got != want {
+		t.Errorf("message: got %q, want %q", got, want)
+	}
+	event := &message.ServerMessage{ToUserName: "gh_1", FromUserName: "openid", CreateTime: 100}
+	if got, want := message.DedupKey(event), "gh_1:openid:100"; got != want {
+		t.Errorf("event: got %q, want %q", got, want)
+	}
+	other := &message.ServerMessage{ToUserName: "gh_2", FromUserName: "openid", CreateTime: 100, MsgId: 123}
+	if message.DedupKey(other) == message.DedupKey(text) {
+		t.Error("messages of different accounts should have different keys")
+	}
+}
+
+// 内存中的 platform.DataStorage
+type memoryStorage struct {
+	data map[string]string
+	mu   sync.Mutex
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: make(map[string]string)}
+}
+
+func (m *memoryStorage) Read(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memoryStorage) Store(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *memoryStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+// 实现 StoreIfAbsent 的存储器, 忽略有效期
+type atomicStorage struct {
+	*memoryStorage
+	calls int32
+}
+
+func (a *atomicStorage) StoreIfAbsent(key, value string, ttl time.Duration) (bool, error) {
+	atomic.AddInt32(&a.calls, 1)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.data[key]; ok {
+		return false, nil
+	}
+	a.data[key] = value
+	return true, nil
+}
+
+func testDedupStore(t *testing.T, store message.DedupStore) {
+	t.Helper()
+	seen, err := store.Seen("key", time.Minute)
+	if err != nil || seen {
+		t.Fatalf("first Seen: got %v %v, want false", seen, err)
+	}
+	seen, err = store.Seen("key", time.Minute)
+	if err != nil || !seen {
+		t.Fatalf("second Seen: got %v %v, want true", seen, err)
+	}
+	err = store.Forget("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen, err = store.Seen("key", time.Minute)
+	if err != nil || seen {
+		t.Fatalf("Seen after Forget: got %v %v, want false", seen, err)
+	}
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	store := message.NewMemoryDedupStore()
+	testDedupStore(t, store)
+	// 零值可以直接使用
+	testDedupStore(t, &message.MemoryDedupStore{})
+
+	seen, _ := store.Seen("short", time.Millisecond)
+	if seen {
+		t.Fatal("expected not seen")
+	}
+	time.Sleep(5 * time.Millisecond)
+	seen, _ = store.Seen("short", time.Millisecond)
+	if seen {
+		t.Error("expired key should not be seen")
+	}
+}
+
+func TestStorageDedupStore(t *testing.T) {
+	testDedupStore(t, message.NewStorageDedupStore(newMemoryStorage(), "dedup:"))
+
+	as := &atomicStorage{memoryStorage: newMemoryStorage()}
+	testDedupStore(t, message.NewStorageDedupStore(as, "dedup:"))
+	if as.calls != 3 {
+		t.Errorf("StoreIfAbsent called %d times, want 3", as.calls)
+	}
+	if _, ok := as.data["dedup:key"]; !ok {
+		t.Error("key should be stored with prefix")
+	}
+}
+
+func TestServerDedup(t *testing.T) {
+	calls := 0
+	s := message.NewServer(testToken, nil)
+	s.Dedup = message.NewMemoryDedupStore()
+	s.HandleFunc(message.ServerMsgTypeText, func(req *message.Request) (*message.ResponseMessage, error) {
+		calls++
+		return req.ReplyText("ok")
+	})
+	sim := &wechattest.Simulator{Token: testToken}
+	msg := wechattest.TextMessage("openid", "hello")
+	msg.MsgId = 1000
+	reply, err := sim.Send(s, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyContent(t, reply)
+	// 微信服务器的重试请求
+	reply, err = sim.Send(s, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != nil || calls != 1 {
+		t.Errorf("duplicate message: got reply %+v, handler called %d times", reply, calls)
+	}
+}
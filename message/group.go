@@ -8,22 +8,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/orivil/wechat"
-	"io/ioutil"
-	"net/http"
 )
 
+// 群发错误码, 错误说明可通过 wechat.ErrCodeText 获得
 const (
-	ErrGroupMsgCodeAlreadySent          = 45065
-	ErrGroupMsgCodeSendTooFast          = 45066
-	ErrGroupMsgCodeClientMsgIDIsTooLong = 45067
+	ErrGroupMsgCodeAlreadySent          = wechat.ErrCodeGroupMsgAlreadySent
+	ErrGroupMsgCodeSendTooFast          = wechat.ErrCodeGroupMsgSendTooFast
+	ErrGroupMsgCodeClientMsgIDIsTooLong = wechat.ErrCodeGroupMsgClientIDTooLong
 )
 
-var errCodeTexts = map[int]string{
-	ErrGroupMsgCodeAlreadySent:          "相同 clientmsgid 已存在群发记录，返回数据中带有已存在的群发任务的 msgid",
-	ErrGroupMsgCodeSendTooFast:          "相同 clientmsgid 重试速度过快，请间隔1分钟重试",
-	ErrGroupMsgCodeClientMsgIDIsTooLong: "clientmsgid 长度超过限制",
-}
-
 // 群发的消息类型
 type GroupMessageType string
 
@@ -58,16 +51,21 @@ type GroupMessage struct {
 // 优先使用 toWXName(用户微信号)
 // 发送视频消息时需要通过 SetGroupVideoMessageMediaInfo() 设置视频标题及描述
 func (gm *GroupMessage) Preview(toOpenid, toWXName, token string) (msgID, msgDataID int, err error) {
+	return gm.PreviewWith(wechat.DefaultClient, toOpenid, toWXName, token)
+}
+
+// PreviewWith 同 Preview, 通过客户端 c 发送请求
+func (gm *GroupMessage) PreviewWith(c *wechat.Client, toOpenid, toWXName, token string) (msgID, msgDataID int, err error) {
 	msg := &previewGroupMessage{
 		ToUser:       toOpenid,
 		ToWXName:     toWXName,
 		GroupMessage: gm,
 	}
 	uri := "https://api.weixin.qq.com/cgi-bin/message/mass/preview?access_token=" + token
-	return gm.postData(uri, msg)
+	return gm.postData(c, uri, msg)
 }
 
-func (gm *GroupMessage) postData(uri string, value interface{}) (msgID, msgDataID int, err error) {
+func (gm *GroupMessage) postData(c *wechat.Client, uri string, value interface{}) (msgID, msgDataID int, err error) {
 	buf := bytes.NewBuffer(nil)
 	encoder := json.NewEncoder(buf)
 	encoder.SetEscapeHTML(false)
@@ -75,26 +73,21 @@ func (gm *GroupMessage) postData(uri string, value interface{}) (msgID, msgDataI
 	if err != nil {
 		return 0, 0, err
 	}
-	resp, err := http.Post(uri, "application/json;charset=utf-8", bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return 0, 0, nil
-	}
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
+	data, err := c.Post(uri, "application/json;charset=utf-8", bytes.NewReader(buf.Bytes()))
 	if err != nil {
 		return 0, 0, err
 	}
 	res := &struct {
 		MsgID     int `json:"msg_id"`
 		MsgDataID int `json:"msg_data_id"`
-		*wechat.Error
+		wechat.Error
 	}{}
 	err = json.Unmarshal(data, res)
 	if err != nil {
 		return 0, 0, err
 	} else {
-		if res.Error.ErrCode != 0 {
-			err = res.Error
+		if res.ErrCode != 0 {
+			err = &res.Error
 		}
 		return res.MsgID, res.MsgDataID, err
 	}
@@ -117,6 +110,11 @@ func (gm *GroupMessage) postData(uri string, value interface{}) (msgID, msgDataI
 // 获取到对应的图文消息的数据，是图文分析数据接口中的msgid字段中的前半部分，详见图文分析数据接口中
 // 的msgid字段的介绍。
 func (gm *GroupMessage) SendByTag(tagID, clientMsgID int, stopWhenReprint bool, token string) (msgID, msgDataID int, err error) {
+	return gm.SendByTagWith(wechat.DefaultClient, tagID, clientMsgID, stopWhenReprint, token)
+}
+
+// SendByTagWith 同 SendByTag, 通过客户端 c 发送请求
+func (gm *GroupMessage) SendByTagWith(c *wechat.Client, tagID, clientMsgID int, stopWhenReprint bool, token string) (msgID, msgDataID int, err error) {
 	msg := &filterGroupMessage{
 		Filter: &tagFilter{
 			ISToAll: tagID == 0,
@@ -125,11 +123,15 @@ func (gm *GroupMessage) SendByTag(tagID, clientMsgID int, stopWhenReprint bool,
 		GroupMessage: gm,
 		ClientMsgID:  clientMsgID,
 	}
+	if clientMsgID != 0 {
+		// 带有 clientmsgid 的群发可以安全地重复发送, 重复的群发会返回 45065 错误及已存在的群发 msgid
+		c = c.Idempotent()
+	}
 	if !stopWhenReprint {
 		msg.SendIgnoreReprint = 1
 	}
 	uri := "https://api.weixin.qq.com/cgi-bin/message/mass/sendall?access_token=" + token
-	return gm.postData(uri, msg)
+	return gm.postData(c, uri, msg)
 }
 
 // 根据OpenID列表群发【订阅号不可用，服务号认证后可用】
@@ -149,16 +151,25 @@ func (gm *GroupMessage) SendByTag(tagID, clientMsgID int, stopWhenReprint bool,
 // 获取到对应的图文消息的数据，是图文分析数据接口中的msgid字段中的前半部分，详见图文分析数据接口中
 // 的msgid字段的介绍。
 func (gm *GroupMessage) SendByOpenIDs(clientMsgID int, openids []string, stopWhenReprint bool, token string) (msgID, msgDataID int, err error) {
+	return gm.SendByOpenIDsWith(wechat.DefaultClient, clientMsgID, openids, stopWhenReprint, token)
+}
+
+// SendByOpenIDsWith 同 SendByOpenIDs, 通过客户端 c 发送请求
+func (gm *GroupMessage) SendByOpenIDsWith(c *wechat.Client, clientMsgID int, openids []string, stopWhenReprint bool, token string) (msgID, msgDataID int, err error) {
 	msg := &filterGroupMessage{
 		ToUser:       openids,
 		GroupMessage: gm,
 		ClientMsgID:  clientMsgID,
 	}
+	if clientMsgID != 0 {
+		// 带有 clientmsgid 的群发可以安全地重复发送, 重复的群发会返回 45065 错误及已存在的群发 msgid
+		c = c.Idempotent()
+	}
 	if !stopWhenReprint {
 		msg.SendIgnoreReprint = 1
 	}
 	uri := "https://api.weixin.qq.com/cgi-bin/message/mass/send?access_token=" + token
-	return gm.postData(uri, msg)
+	return gm.postData(c, uri, msg)
 }
 
 // 预览群发消息
@@ -213,6 +224,11 @@ type tagFilter struct {
 
 // 设置群发视频的视频信息, 返回新的视频媒体 ID, 通过新的视频媒体 ID 发送给用户
 func SetGroupVideoMessageMediaInfo(mediaID, title, description, token string) (msgMediaID string, createdAt int64, err error) {
+	return SetGroupVideoMessageMediaInfoWith(wechat.DefaultClient, mediaID, title, description, token)
+}
+
+// SetGroupVideoMessageMediaInfoWith 同 SetGroupVideoMessageMediaInfo, 通过客户端 c 发送请求
+func SetGroupVideoMessageMediaInfoWith(c *wechat.Client, mediaID, title, description, token string) (msgMediaID string, createdAt int64, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/media/uploadvideo?access_token=" + token
 	res := &struct {
 		MediaID   string `json:"media_id"`
@@ -223,7 +239,7 @@ func SetGroupVideoMessageMediaInfo(mediaID, title, description, token string) (m
 		"title":       title,
 		"description": description,
 	}
-	err = wechat.PostSchema(wechat.KindJson, uri, data, res)
+	err = c.PostSchema(wechat.KindJson, uri, data, res)
 	if err != nil {
 		return "", 0, err
 	} else {
@@ -236,12 +252,17 @@ func SetGroupVideoMessageMediaInfo(mediaID, title, description, token string) (m
 // speed 群发速度的级别
 // realspeed 群发速度的真实值 单位：万/分钟
 func GetGroupMsgSpeed(token string) (speed, realSpeed int, err error) {
+	return GetGroupMsgSpeedWith(wechat.DefaultClient, token)
+}
+
+// GetGroupMsgSpeedWith 同 GetGroupMsgSpeed, 通过客户端 c 发送请求
+func GetGroupMsgSpeedWith(c *wechat.Client, token string) (speed, realSpeed int, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/message/mass/speed/get?access_token=" + token
 	res := &struct {
 		Speed     int `json:"speed"`
 		RealSpeed int `json:"realspeed"`
 	}{}
-	err = wechat.PostSchema(wechat.KindJson, uri, nil, res)
+	err = c.Idempotent().PostSchema(wechat.KindJson, uri, nil, res)
 	if err != nil {
 		return 0, 0, err
 	} else {
@@ -262,6 +283,11 @@ func GetGroupMsgSpeed(token string) (speed, realSpeed int, err error) {
 // 3	    30w/分钟
 // 4	    10w/分钟
 func SetGroupMsgSpeed(speed int, token string) error {
+	return SetGroupMsgSpeedWith(wechat.DefaultClient, speed, token)
+}
+
+// SetGroupMsgSpeedWith 同 SetGroupMsgSpeed, 通过客户端 c 发送请求
+func SetGroupMsgSpeedWith(c *wechat.Client, speed int, token string) error {
 	uri := "https://api.weixin.qq.com/cgi-bin/message/mass/speed/set?access_token=" + token
-	return wechat.PostSchema(wechat.KindJson, uri, map[string]int{"speed": speed}, nil)
+	return c.PostSchema(wechat.KindJson, uri, map[string]int{"speed": speed}, nil)
 }
@@ -0,0 +1,250 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"encoding/xml"
+
+	"github.com/orivil/wechat/publish"
+)
+
+// 用户发送的普通消息及自定义菜单事件推送
+
+func (sm *ServerMessage) unmarshal(v interface{}) error {
+	return xml.Unmarshal(sm.Data, v)
+}
+
+// MsgType: "image"
+type ImageMessage struct {
+	// 图片链接（由系统生成）
+	PicUrl string
+
+	// 图片消息媒体id，可以调用获取临时素材接口拉取数据
+	MediaId string
+
+	// 消息ID
+	MsgId int64
+}
+
+func (sm *ServerMessage) MarshalImageMessage() (msg *ImageMessage, err error) {
+	msg = &ImageMessage{}
+	err = sm.unmarshal(msg)
+	if err != nil {
+		return nil, err
+	} else {
+		return msg, nil
+	}
+}
+
+// MsgType: "voice"
+type VoiceMessage struct {
+	// 语音消息媒体id，可以调用获取临时素材接口拉取数据
+	MediaId string
+
+	// 语音格式，如amr，speex等
+	Format string
+
+	// 语音识别结果，UTF8编码, 开通语音识别后才有该字段
+	Recognition string
+
+	// 消息ID
+	MsgId int64
+}
+
+func (sm *ServerMessage) MarshalVoiceMessage() (msg *VoiceMessage, err error) {
+	msg = &VoiceMessage{}
+	err = sm.unmarshal(msg)
+	if err != nil {
+		return nil, err
+	} else {
+		return msg, nil
+	}
+}
+
+// MsgType: "video" or "shortvideo"
+type VideoMessage struct {
+	// 视频消息媒体id，可以调用获取临时素材接口拉取数据
+	MediaId string
+
+	// 视频消息缩略图的媒体id，可以调用获取临时素材接口拉取数据
+	ThumbMediaId string
+
+	// 消息ID
+	MsgId int64
+}
+
+func (sm *ServerMessage) MarshalVideoMessage() (msg *VideoMessage, err error) {
+	msg = &VideoMessage{}
+	err = sm.unmarshal(msg)
+	if err != nil {
+		return nil, err
+	} else {
+		return msg, nil
+	}
+}
+
+// MsgType: "location"
+type LocationMessage struct {
+	// 地理位置纬度
+	LocationX float64 `xml:"Location_X"`
+
+	// 地理位置经度
+	LocationY float64 `xml:"Location_Y"`
+
+	// 地图缩放大小
+	Scale int
+
+	// 地理位置信息
+	Label string
+
+	// 消息ID
+	MsgId int64
+}
+
+func (sm *ServerMessage) MarshalLocationMessage() (msg *LocationMessage, err error) {
+	msg = &LocationMessage{}
+	err = sm.unmarshal(msg)
+	if err != nil {
+		return nil, err
+	} else {
+		return msg, nil
+	}
+}
+
+// MsgType: "link"
+type LinkMessage struct {
+	// 消息标题
+	Title string
+
+	// 消息描述
+	Description string
+
+	// 消息链接
+	Url string
+
+	// 消息ID
+	MsgId int64
+}
+
+func (sm *ServerMessage) MarshalLinkMessage() (msg *LinkMessage, err error) {
+	msg = &LinkMessage{}
+	err = sm.unmarshal(msg)
+	if err != nil {
+		return nil, err
+	} else {
+		return msg, nil
+	}
+}
+
+// MsgType: "event", Event: "VIEW" or "view_miniprogram"
+// 点击菜单跳转链接或小程序, 跳转地址保存在 EventKey 中
+type ViewMenu struct {
+	// 指菜单ID，如果是个性化菜单，则可以通过这个字段，知道是哪个规则的菜单被点击了
+	MenuId string
+}
+
+func (sm *ServerMessage) MarshalViewMenu() (menu *ViewMenu, err error) {
+	menu = &ViewMenu{}
+	err = sm.unmarshal(menu)
+	if err != nil {
+		return nil, err
+	} else {
+		return menu, nil
+	}
+}
+
+// MsgType: "event", Event: "scancode_push" or "scancode_waitmsg"
+// 扫描信息
+type ScanCodeInfo struct {
+	// 扫描类型，一般是qrcode
+	ScanType string
+
+	// 扫描结果，即二维码对应的字符串信息
+	ScanResult string
+}
+
+func (sm *ServerMessage) MarshalScanCodeInfo() (info *ScanCodeInfo, err error) {
+	res := &struct {
+		Info *ScanCodeInfo `xml:"ScanCodeInfo"`
+	}{Info: &ScanCodeInfo{}}
+	err = sm.unmarshal(res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.Info, nil
+	}
+}
+
+// MsgType: "event", Event: "pic_sysphoto", "pic_photo_or_album" or "pic_weixin"
+// 发送的图片信息, 图片本身将以图片消息的形式另行推送
+type SendPicsInfo struct {
+	// 发送的图片数量
+	Count int
+
+	// 图片列表
+	PicList []*PicItem `xml:"PicList>item"`
+}
+
+type PicItem struct {
+	// 图片的MD5值，开发者若需要，可用于验证接收到图片
+	PicMd5Sum string
+}
+
+func (sm *ServerMessage) MarshalSendPicsInfo() (info *SendPicsInfo, err error) {
+	res := &struct {
+		Info *SendPicsInfo `xml:"SendPicsInfo"`
+	}{Info: &SendPicsInfo{}}
+	err = sm.unmarshal(res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.Info, nil
+	}
+}
+
+// MsgType: "event", Event: "location_select"
+// 发送的位置信息
+type SendLocationInfo struct {
+	// X坐标信息
+	LocationX float64 `xml:"Location_X"`
+
+	// Y坐标信息
+	LocationY float64 `xml:"Location_Y"`
+
+	// 精度，可理解为精度或者比例尺、越精细的话 scale越高
+	Scale int
+
+	// 地理位置的字符串信息
+	Label string
+
+	// 朋友圈POI的名字，可能为空
+	Poiname string
+}
+
+func (sm *ServerMessage) MarshalSendLocationInfo() (info *SendLocationInfo, err error) {
+	res := &struct {
+		Info *SendLocationInfo `xml:"SendLocationInfo"`
+	}{Info: &SendLocationInfo{}}
+	err = sm.unmarshal(res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.Info, nil
+	}
+}
+
+// MsgType: "event", Event: "PUBLISHJOBFINISH"
+// 草稿发布结果, 包含发布状态及发布成功的文章链接
+func (sm *ServerMessage) MarshalPublishEventInfo() (info *publish.Result, err error) {
+	res := &struct {
+		Info *publish.Result `xml:"PublishEventInfo"`
+	}{Info: &publish.Result{}}
+	err = sm.unmarshal(res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.Info, nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/publish"
+)
+
+// 读取 testdata 中的明文消息
+func readFixture(t *testing.T, name string) *message.ServerMessage {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	msg, err := message.ReadServerMessage(req, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return msg
+}
+
+func TestInboundMessages(t *testing.T) {
+	cases := []struct {
+		file    string
+		msgType message.ServerMsgType
+		msgID   int64
+		parse   func(sm *message.ServerMessage) (interface{}, error)
+		want    interface{}
+	}{
+		{"image.xml", message.ServerMsgTypeImage, 1234567890123456,
+			func(sm *message.ServerMessage) (interface{}, error) { return sm.MarshalImageMessage() },
+			&message.ImageMessage{PicUrl: "http://mmbiz.qpic.cn/a.jpg", MediaId: "media_id", MsgId: 1234567890123456}},
+		{"voice.xml", message.ServerMsgTypeVoice, 1234567890123457,
+			func(sm *message.ServerMessage) (interface{}, error) { return sm.MarshalVoiceMessage() },
+			&message.VoiceMessage{MediaId: "media_id", Format: "amr", Recognition: "腾讯微信团队", MsgId: 1234567890123457}},
+		{"video.xml", message.ServerMsgTypeShortVideo, 1234567890123458,
+			func(sm *message.ServerMessage) (interface{}, error) { return sm.MarshalVideoMessage() },
+			&message.VideoMessage{MediaId: "media_id", ThumbMediaId: "thumb_media_id", MsgId: 1234567890123458}},
+		{"location.xml", message.ServerMsgTypeLocation, 1234567890123459,
+			func(sm *message.ServerMessage) (interface{}, error) { return sm.MarshalLocationMessage() },
+			&message.LocationMessage{LocationX: 23.134521, LocationY: 113.358803, Scale: 20, Label: "位置信息", MsgId: 1234567890123459}},
+		{"link.xml", message.ServerMsgTypeLink, 1234567890123460,
+			func(sm *message.ServerMessage) (interface{}, error) { return sm.MarshalLinkMessage() },
+			&message.LinkMessage{Title: "公众平台官网链接", Description: "公众平台官网链接", Url: "https://mp.weixin.qq.com", MsgId: 1234567890123460}},
+	}
+	for _, c := range cases {
+		sm := readFixture(t, c.file)
+		// 普通消息的 MsgId 直接解析到 ServerMessage 中
+		if sm.MsgType != c.msgType || sm.MsgId != c.msgID || sm.FromUserName != "openid" {
+			t.Errorf("%s: got %+v", c.file, sm)
+		}
+		got, err := c.parse(sm)
+		if err != nil {
+			t.Errorf("%s: %v", c.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.file, got, c.want)
+		}
+	}
+}
+
+func TestMenuEvents(t *testing.T) {
+	sm := readFixture(t, "scancode.xml")
+	if sm.Event != message.EvtUserScanCodePush || sm.EventKey != "6" || sm.MsgId != 0 {
+		t.Errorf("got %+v", sm)
+	}
+	scan, err := sm.MarshalScanCodeInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&message.ScanCodeInfo{ScanType: "qrcode", ScanResult: "1"}); !reflect.DeepEqual(scan, want) {
+		t.Errorf("got %+v, want %+v", scan, want)
+	}
+
+	sm = readFixture(t, "pics.xml")
+	if sm.Event != message.EvtUserPicPhotoOrAlbum {
+		t.Errorf("got event %s", sm.Event)
+	}
+	pics, err := sm.MarshalSendPicsInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPics := &message.SendPicsInfo{Count: 2, PicList: []*message.PicItem{
+		{PicMd5Sum: "5a75aaca956d97be686719218f275c6b"},
+		{PicMd5Sum: "1b5f7c23b5bf75682a53e7b6d163e185"},
+	}}
+	if !reflect.DeepEqual(pics, wantPics) {
+		t.Errorf("got %+v, want %+v", pics, wantPics)
+	}
+
+	sm = readFixture(t, "location_select.xml")
+	if sm.Event != message.EvtUserLocationSelect {
+		t.Errorf("got event %s", sm.Event)
+	}
+	location, err := sm.MarshalSendLocationInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLocation := &message.SendLocationInfo{LocationX: 23, LocationY: 113, Scale: 15, Label: " 广州市海珠区客村艺苑路 106号"}
+	if !reflect.DeepEqual(location, wantLocation) {
+		t.Errorf("got %+v, want %+v", location, wantLocation)
+	}
+}
+
+func TestPublishEvent(t *testing.T) {
+	sm := readFixture(t, "publish.xml")
+	if sm.Event != message.EvtPublishJobFinish {
+		t.Errorf("got event %s", sm.Event)
+	}
+	res, err := sm.MarshalPublishEventInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.PublishID != "2247503051" || res.Status != publish.StatusSuccess || res.ArticleID != "article_id" {
+		t.Errorf("got %+v", res)
+	}
+	if urls, want := res.ArticleURLs(), []string{"http://mp.weixin.qq.com/s/1", "http://mp.weixin.qq.com/s/2"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got urls %v, want %v", urls, want)
+	}
+
+	res, err = readFixture(t, "publish_failed.xml").MarshalPublishEventInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != publish.StatusOriginalFailed || !reflect.DeepEqual(res.FailIdx, []int{1, 2}) || res.ArticleDetail != nil {
+		t.Errorf("got %+v", res)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"github.com/orivil/wechat"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrReplyNotAllowed  = errors.New("该消息不能被动回复")
+	ErrTooManyArticles  = errors.New("被动回复图文消息数量超过限制")
+	ErrEmptyReplyMember = errors.New("被动回复消息缺少必填字段")
+)
+
+// 被动回复图文消息最多可回复的图文数量
+const MaxReplyArticles = 8
+
+// 微信服务器推送但不接收被动回复的事件
+var noReplyEvents = map[EventType]bool{
+	EvtUserUnsubscribe:   true,
+	EvtTemplateMsgResult: true,
+	EvtGroupMsgResult:    true,
+	EvtPublishJobFinish:  true,
+}
+
+// MaxArticles 返回被动回复 sm 时最多可回复的图文数量, 用户发送文本、图片、视频、图文、地理位置这五种消息时只能回复1条,
+// 其余场景最多可回复8条
+func (sm *ServerMessage) MaxArticles() int {
+	switch sm.MsgType {
+	case ServerMsgTypeText, ServerMsgTypeImage, ServerMsgTypeVideo, ServerMsgTypeShortVideo, ServerMsgTypeLocation, ServerMsgTypeLink:
+		return 1
+	default:
+		return MaxReplyArticles
+	}
+}
+
+// 检查 sm 是否可以被动回复
+func (sm *ServerMessage) checkReply() error {
+	if sm.MsgType == ServerMsgTypeEvent && noReplyEvents[sm.Event] {
+		return errors.Wrapf(ErrReplyNotAllowed, "event %s", sm.Event)
+	}
+	return nil
+}
+
+func (sm *ServerMessage) reply(msgType ResponseMsgType, required ...string) (*ResponseMessage, error) {
+	err := sm.checkReply()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(required); i += 2 {
+		if required[i+1] == "" {
+			return nil, errors.Wrapf(ErrEmptyReplyMember, "%s reply: %s", msgType, required[i])
+		}
+	}
+	return &ResponseMessage{MsgType: msgType}, nil
+}
+
+// ReplyText 回复文本消息.
+// ReplyXxx 方法用于构建被动回复消息, 构建时按触发回复的消息类型校验限制, 校验失败时返回错误而不生成消息. 在 Handler 中可直接:
+//
+//	return req.ReplyText("你好")
+func (sm *ServerMessage) ReplyText(content string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeText, "Content", content)
+	if err != nil {
+		return nil, err
+	}
+	msg.Content = &wechat.Cdata{Value: content}
+	return msg, nil
+}
+
+// ReplyImage 回复图片消息, mediaID 为通过素材管理中的接口上传多媒体文件得到的 id
+func (sm *ServerMessage) ReplyImage(mediaID string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeImage, "MediaId", mediaID)
+	if err != nil {
+		return nil, err
+	}
+	msg.Image = &ResImage{MediaId: wechat.Cdata{Value: mediaID}}
+	return msg, nil
+}
+
+// ReplyVoice 回复语音消息
+func (sm *ServerMessage) ReplyVoice(mediaID string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeVoice, "MediaId", mediaID)
+	if err != nil {
+		return nil, err
+	}
+	msg.Voice = &ResVoice{MediaId: wechat.Cdata{Value: mediaID}}
+	return msg, nil
+}
+
+// ReplyVideo 回复视频消息, title 及 description 可为空
+func (sm *ServerMessage) ReplyVideo(mediaID, title, description string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeVideo, "MediaId", mediaID)
+	if err != nil {
+		return nil, err
+	}
+	msg.Video = &ResVideo{
+		MediaId:     wechat.Cdata{Value: mediaID},
+		Title:       wechat.Cdata{Value: title},
+		Description: wechat.Cdata{Value: description},
+	}
+	return msg, nil
+}
+
+// ReplyMusic 回复音乐消息, thumbMediaID 为缩略图的媒体 id, 其余参数可为空
+func (sm *ServerMessage) ReplyMusic(title, description, musicURL, hqMusicURL, thumbMediaID string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeMusic, "ThumbMediaId", thumbMediaID)
+	if err != nil {
+		return nil, err
+	}
+	msg.Music = &ResMusic{
+		Title:        wechat.Cdata{Value: title},
+		Description:  wechat.Cdata{Value: description},
+		MusicURL:     wechat.Cdata{Value: musicURL},
+		HQMusicUrl:   wechat.Cdata{Value: hqMusicURL},
+		ThumbMediaId: wechat.Cdata{Value: thumbMediaID},
+	}
+	return msg, nil
+}
+
+// ReplyNews 回复图文消息, 图文数量不能超过 MaxArticles 的返回值
+func (sm *ServerMessage) ReplyNews(articles ...*CustomerArticle) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeNews)
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return nil, errors.Wrap(ErrEmptyReplyMember, "news reply: Articles")
+	}
+	if max := sm.MaxArticles(); len(articles) > max {
+		return nil, errors.Wrapf(ErrTooManyArticles, "got %d articles, %s message allows %d", len(articles), sm.MsgType, max)
+	}
+	arts := make(ResArticles, len(articles))
+	for i, art := range articles {
+		if art == nil || art.Title == "" {
+			return nil, errors.Wrapf(ErrEmptyReplyMember, "news reply: Articles[%d].Title", i)
+		}
+		arts[i] = &ResArticle{
+			Title:       wechat.Cdata{Value: art.Title},
+			Description: wechat.Cdata{Value: art.Description},
+			Url:         wechat.Cdata{Value: art.Url},
+			PicUrl:      wechat.Cdata{Value: art.PicUrl},
+		}
+	}
+	msg.Articles = &arts
+	return msg, nil
+}
+
+// ReplyTransferCustomerService 将消息转发到客服系统, kfAccount 为空时由空闲的客服接入, 否则转发给指定的客服帐号.
+// 该消息没有对应的客服消息, 不能在异步回复模式下使用.
+func (sm *ServerMessage) ReplyTransferCustomerService(kfAccount string) (*ResponseMessage, error) {
+	msg, err := sm.reply(ResponseMsgTypeTransferCustomerService)
+	if err != nil {
+		return nil, err
+	}
+	if kfAccount != "" {
+		msg.TransInfo = &ResTransInfo{KfAccount: wechat.Cdata{Value: kfAccount}}
+	}
+	return msg, nil
+}
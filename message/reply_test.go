@@ -0,0 +1,132 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"testing"
+
+	"github.com/orivil/wechat/message"
+	"github.com/pkg/errors"
+)
+
+func TestReplyBuilders(t *testing.T) {
+	text := &message.ServerMessage{MsgType: message.ServerMsgTypeText}
+	article := &message.CustomerArticle{Title: "title", Url: "http://a.com"}
+	cases := []struct {
+		name    string
+		build   func() (*message.ResponseMessage, error)
+		msgType message.ResponseMsgType
+		check   func(msg *message.ResponseMessage) bool
+		err     error
+	}{
+		{"text", func() (*message.ResponseMessage, error) { return text.ReplyText("hello") }, message.ResponseMsgTypeText,
+			func(msg *message.ResponseMessage) bool { return msg.Content.Value == "hello" }, nil},
+		{"empty text", func() (*message.ResponseMessage, error) { return text.ReplyText("") }, "", nil, message.ErrEmptyReplyMember},
+		{"image", func() (*message.ResponseMessage, error) { return text.ReplyImage("media") }, message.ResponseMsgTypeImage,
+			func(msg *message.ResponseMessage) bool { return msg.Image.MediaId.Value == "media" }, nil},
+		{"empty image", func() (*message.ResponseMessage, error) { return text.ReplyImage("") }, "", nil, message.ErrEmptyReplyMember},
+		{"voice", func() (*message.ResponseMessage, error) { return text.ReplyVoice("media") }, message.ResponseMsgTypeVoice,
+			func(msg *message.ResponseMessage) bool { return msg.Voice.MediaId.Value == "media" }, nil},
+		{"empty voice", func() (*message.ResponseMessage, error) { return text.ReplyVoice("") }, "", nil, message.ErrEmptyReplyMember},
+		{"video", func() (*message.ResponseMessage, error) { return text.ReplyVideo("media", "title", "") }, message.ResponseMsgTypeVideo,
+			func(msg *message.ResponseMessage) bool {
+				return msg.Video.MediaId.Value == "media" && msg.Video.Title.Value == "title"
+			}, nil},
+		{"empty video", func() (*message.ResponseMessage, error) { return text.ReplyVideo("", "title", "") }, "", nil, message.ErrEmptyReplyMember},
+		{"music", func() (*message.ResponseMessage, error) {
+			return text.ReplyMusic("", "", "http://a.com/a.mp3", "", "thumb")
+		}, message.ResponseMsgTypeMusic,
+			func(msg *message.ResponseMessage) bool {
+				return msg.Music.ThumbMediaId.Value == "thumb" && msg.Music.MusicURL.Value == "http://a.com/a.mp3"
+			}, nil},
+		{"empty music", func() (*message.ResponseMessage, error) { return text.ReplyMusic("title", "", "", "", "") }, "", nil, message.ErrEmptyReplyMember},
+		{"news", func() (*message.ResponseMessage, error) { return text.ReplyNews(article) }, message.ResponseMsgTypeNews,
+			func(msg *message.ResponseMessage) bool {
+				return len(*msg.Articles) == 1 && (*msg.Articles)[0].Title.Value == "title"
+			}, nil},
+		{"no news", func() (*message.ResponseMessage, error) { return text.ReplyNews() }, "", nil, message.ErrEmptyReplyMember},
+		{"nil article", func() (*message.ResponseMessage, error) { return text.ReplyNews(nil) }, "", nil, message.ErrEmptyReplyMember},
+		{"empty title", func() (*message.ResponseMessage, error) { return text.ReplyNews(&message.CustomerArticle{}) }, "", nil, message.ErrEmptyReplyMember},
+		{"too many news", func() (*message.ResponseMessage, error) { return text.ReplyNews(article, article) }, "", nil, message.ErrTooManyArticles},
+		{"transfer", func() (*message.ResponseMessage, error) { return text.ReplyTransferCustomerService("kf@test") }, message.ResponseMsgTypeTransferCustomerService,
+			func(msg *message.ResponseMessage) bool { return msg.TransInfo.KfAccount.Value == "kf@test" }, nil},
+		{"transfer to any", func() (*message.ResponseMessage, error) { return text.ReplyTransferCustomerService("") }, message.ResponseMsgTypeTransferCustomerService,
+			func(msg *message.ResponseMessage) bool { return msg.TransInfo == nil }, nil},
+	}
+	for _, c := range cases {
+		msg, err := c.build()
+		if c.err != nil {
+			if errors.Cause(err) != c.err || msg != nil {
+				t.Errorf("%s: got %+v, %v, want %v", c.name, msg, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if msg.MsgType != c.msgType || !c.check(msg) {
+			t.Errorf("%s: got %+v", c.name, msg)
+		}
+	}
+}
+
+func TestMaxArticles(t *testing.T) {
+	cases := []struct {
+		msgType message.ServerMsgType
+		max     int
+	}{
+		{message.ServerMsgTypeText, 1},
+		{message.ServerMsgTypeImage, 1},
+		{message.ServerMsgTypeVideo, 1},
+		{message.ServerMsgTypeShortVideo, 1},
+		{message.ServerMsgTypeLocation, 1},
+		{message.ServerMsgTypeLink, 1},
+		{message.ServerMsgTypeVoice, message.MaxReplyArticles},
+		{message.ServerMsgTypeEvent, message.MaxReplyArticles},
+	}
+	article := &message.CustomerArticle{Title: "title"}
+	for _, c := range cases {
+		sm := &message.ServerMessage{MsgType: c.msgType}
+		if got := sm.MaxArticles(); got != c.max {
+			t.Errorf("%s: got %d, want %d", c.msgType, got, c.max)
+		}
+		articles := make([]*message.CustomerArticle, c.max+1)
+		for i := range articles {
+			articles[i] = article
+		}
+		if _, err := sm.ReplyNews(articles[:c.max]...); err != nil {
+			t.Errorf("%s: %d articles: %v", c.msgType, c.max, err)
+		}
+		if _, err := sm.ReplyNews(articles...); errors.Cause(err) != message.ErrTooManyArticles {
+			t.Errorf("%s: %d articles: got %v, want ErrTooManyArticles", c.msgType, c.max+1, err)
+		}
+	}
+}
+
+func TestReplyNotAllowed(t *testing.T) {
+	events := []struct {
+		event   message.EventType
+		allowed bool
+	}{
+		{message.EvtUserUnsubscribe, false},
+		{message.EvtTemplateMsgResult, false},
+		{message.EvtGroupMsgResult, false},
+		{message.EvtPublishJobFinish, false},
+		{message.EvtUserSubscribe, true},
+		{message.EvtUserClick, true},
+		{message.EvtUserScan, true},
+	}
+	for _, e := range events {
+		sm := &message.ServerMessage{MsgType: message.ServerMsgTypeEvent, Event: e.event}
+		_, err := sm.ReplyText("hello")
+		if e.allowed && err != nil {
+			t.Errorf("%s: %v", e.event, err)
+		}
+		if !e.allowed && errors.Cause(err) != message.ErrReplyNotAllowed {
+			t.Errorf("%s: got %v, want ErrReplyNotAllowed", e.event, err)
+		}
+	}
+}
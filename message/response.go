@@ -21,6 +21,9 @@ var (
 
 	// 图文消息, 点击后直接跳转到连接地址
 	ResponseMsgTypeNews ResponseMsgType = "news"
+
+	// 将消息转发到客服系统, 没有对应的客服消息类型
+	ResponseMsgTypeTransferCustomerService ResponseMsgType = "transfer_customer_service"
 )
 
 // ResponseMessage 是被动回复消息.
@@ -47,6 +50,9 @@ type ResponseMessage struct {
 
 	// 被动图文消息可发8条，如果图文数超过限制，则将只发限制内的条数
 	Articles *ResArticles `xml:"Articles>item,omitempty"`
+
+	// 转发到指定的客服帐号, 只用于 transfer_customer_service 消息
+	TransInfo *ResTransInfo `xml:",omitempty"`
 }
 
 // 转换为客服消息
@@ -139,3 +145,7 @@ type ResMusic struct {
 	HQMusicUrl   wechat.Cdata `xml:",omitempty"` // 高质量音乐链接，WIFI环境优先使用该链接播放音乐
 	ThumbMediaId wechat.Cdata `xml:",omitempty"` // 缩略图的媒体id，通过素材管理中的接口上传多媒体文件，得到的id
 }
+
+type ResTransInfo struct {
+	KfAccount wechat.Cdata // 客服帐号, 格式为: 帐号前缀@公众号微信号
+}
@@ -0,0 +1,308 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/orivil/wechat"
+)
+
+var errMethodNotAllowed = errors.New("只接受 GET 及 POST 请求")
+
+// Request 是交给 Handler 处理的消息
+type Request struct {
+	*ServerMessage
+
+	// 原始请求
+	HTTPRequest *http.Request
+
+	ctx context.Context
+}
+
+// Context 返回请求的 context, 处理过程中附加的数据(如会话状态)也保存在其中
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	if r.HTTPRequest != nil {
+		return r.HTTPRequest.Context()
+	}
+	return context.Background()
+}
+
+// WithContext 返回使用 ctx 的 Request 副本
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	cp := *r
+	cp.ctx = ctx
+	return &cp
+}
+
+// ReplySuccess 作为 Handler 的返回值时, 服务器响应 "success", 表示不回复消息.
+// Handler 返回 nil 时服务器响应空字符串, 效果相同.
+var ReplySuccess = &ResponseMessage{}
+
+// Handler 处理微信服务器推送的消息, 返回的被动回复消息将由 Server 负责填写收发双方并加密.
+type Handler interface {
+	ServeMessage(req *Request) (*ResponseMessage, error)
+}
+
+// HandlerFunc 将函数转换为 Handler
+type HandlerFunc func(req *Request) (*ResponseMessage, error)
+
+func (f HandlerFunc) ServeMessage(req *Request) (*ResponseMessage, error) {
+	return f(req)
+}
+
+// 菜单事件, 通过 EventKey 区分不同的菜单
+var menuEvents = map[EventType]bool{
+	EvtUserClick:           true,
+	EvtUserView:            true,
+	EvtUserScanCodePush:    true,
+	EvtUserScanCodeWaitMsg: true,
+	EvtUserPicSysPhoto:     true,
+	EvtUserPicPhotoOrAlbum: true,
+	EvtUserPicWeixin:       true,
+	EvtUserLocationSelect:  true,
+	EvtUserViewMiniprogram: true,
+}
+
+// Server 是接收微信服务器推送消息的 http.Handler, 负责校验签名、响应 echostr 验证、解密消息,
+// 并按菜单 EventKey、事件类型、消息类型的顺序查找 Handler 进行处理.
+type Server struct {
+	// 消息校验 token
+	Token string
+
+	// 加解密器, 为 nil 时只支持明文模式
+	Crypt *wechat.WXBizMsgCrypt
+
+	// 未找到对应的 Handler 时使用, 为 nil 时响应 "success"
+	Default Handler
+
+	// 消息去重记录, 为 nil 时不去重. 重复的消息直接响应 "success", 不再交给 Handler 处理
+	Dedup DedupStore
+
+	// 去重记录的有效期, 为 0 时使用 DefaultDedupTTL
+	DedupTTL time.Duration
+
+	// 用户会话, 为 nil 时不启用会话
+	Sessions *Sessions
+
+	// 异步回复模式, 为 nil 时同步调用 Handler 并被动回复
+	Async *AsyncReply
+
+	// 处理签名错误、消息解析错误及 Handler 返回的错误, 为 nil 时使用 DefaultErrorHandler
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	msgHandlers   map[ServerMsgType]Handler
+	eventHandlers map[EventType]Handler
+	menuHandlers  map[string]Handler
+}
+
+// NewServer 创建消息服务器, crypt 为 nil 时只支持明文模式
+func NewServer(token string, crypt *wechat.WXBizMsgCrypt) *Server {
+	return &Server{Token: token, Crypt: crypt}
+}
+
+// Handle 注册 msgType 类型消息的处理器
+func (s *Server) Handle(msgType ServerMsgType, h Handler) {
+	if s.msgHandlers == nil {
+		s.msgHandlers = make(map[ServerMsgType]Handler)
+	}
+	s.msgHandlers[msgType] = h
+}
+
+func (s *Server) HandleFunc(msgType ServerMsgType, f func(req *Request) (*ResponseMessage, error)) {
+	s.Handle(msgType, HandlerFunc(f))
+}
+
+// HandleEvent 注册 event 类型事件的处理器
+func (s *Server) HandleEvent(event EventType, h Handler) {
+	if s.eventHandlers == nil {
+		s.eventHandlers = make(map[EventType]Handler)
+	}
+	s.eventHandlers[event] = h
+}
+
+func (s *Server) HandleEventFunc(event EventType, f func(req *Request) (*ResponseMessage, error)) {
+	s.HandleEvent(event, HandlerFunc(f))
+}
+
+// HandleMenu 注册菜单事件的处理器, eventKey 为菜单的 KEY 值(CLICK 等)或跳转地址(VIEW)
+func (s *Server) HandleMenu(eventKey string, h Handler) {
+	if s.menuHandlers == nil {
+		s.menuHandlers = make(map[string]Handler)
+	}
+	s.menuHandlers[eventKey] = h
+}
+
+func (s *Server) HandleMenuFunc(eventKey string, f func(req *Request) (*ResponseMessage, error)) {
+	s.HandleMenu(eventKey, HandlerFunc(f))
+}
+
+// Handler 返回处理消息 msg 的处理器, 未找到时返回 Default
+func (s *Server) Handler(msg *ServerMessage) Handler {
+	if msg.MsgType == ServerMsgTypeEvent {
+		if menuEvents[msg.Event] {
+			if h, ok := s.menuHandlers[msg.EventKey]; ok {
+				return h
+			}
+		}
+		if h, ok := s.eventHandlers[msg.Event]; ok {
+			return h
+		}
+	}
+	if h, ok := s.msgHandlers[msg.MsgType]; ok {
+		return h
+	}
+	return s.Default
+}
+
+// ServeMessage 将消息分发给对应的处理器, 使 Server 本身也可以作为 Handler 使用
+func (s *Server) ServeMessage(req *Request) (*ResponseMessage, error) {
+	h := s.Handler(req.ServerMessage)
+	if h == nil {
+		return nil, nil
+	}
+	if s.Sessions != nil {
+		return s.Sessions.serve(h, req)
+	}
+	return h.ServeMessage(req)
+}
+
+// RequestError 是签名校验或消息解析失败时传给 ErrorHandler 的错误
+type RequestError struct {
+	// 响应的 HTTP 状态码
+	StatusCode int
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultErrorHandler 对 *RequestError 响应其状态码, 对其他错误(Handler 返回的错误)响应 500
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	if re, ok := err.(*RequestError); ok {
+		status = re.StatusCode
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (s *Server) error(w http.ResponseWriter, r *http.Request, err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(w, r, err)
+	} else {
+		DefaultErrorHandler(w, r, err)
+	}
+}
+
+func (s *Server) dedupTTL() time.Duration {
+	if s.DedupTTL > 0 {
+		return s.DedupTTL
+	}
+	return DefaultDedupTTL
+}
+
+// 是否是加密消息
+func (s *Server) encrypted(r *http.Request) bool {
+	return s.Crypt != nil && r.URL.Query().Get("encrypt_type") == "aes"
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	echoStr, err := CheckSignature(r, s.Token)
+	if err != nil {
+		s.error(w, r, &RequestError{StatusCode: http.StatusForbidden, Err: err})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		// 微信后台配置服务器地址时的验证请求
+		_, _ = io.WriteString(w, echoStr)
+		return
+	case http.MethodPost:
+	default:
+		s.error(w, r, &RequestError{StatusCode: http.StatusMethodNotAllowed, Err: errMethodNotAllowed})
+		return
+	}
+	var crypt *wechat.WXBizMsgCrypt
+	if s.encrypted(r) {
+		crypt = s.Crypt
+	}
+	msg, err := ReadServerMessage(r, crypt)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == wechat.ErrValidateSignature || err == wechat.ErrValidateAppID {
+			status = http.StatusForbidden
+		}
+		s.error(w, r, &RequestError{StatusCode: status, Err: err})
+		return
+	}
+	var dedupKey string
+	if s.Dedup != nil {
+		dedupKey = DedupKey(msg)
+		seen, err := s.Dedup.Seen(dedupKey, s.dedupTTL())
+		if err != nil {
+			s.error(w, r, err)
+			return
+		}
+		if seen {
+			_, _ = io.WriteString(w, "success")
+			return
+		}
+	}
+	req := &Request{ServerMessage: msg, HTTPRequest: r}
+	if s.Async != nil {
+		if s.Handler(msg) != nil {
+			err := s.Async.submit(s, req)
+			if err != nil {
+				// 消息未能加入队列, 响应错误使微信重试
+				if s.Dedup != nil {
+					_ = s.Dedup.Forget(dedupKey)
+				}
+				s.error(w, r, &RequestError{StatusCode: http.StatusServiceUnavailable, Err: err})
+				return
+			}
+		}
+		_, _ = io.WriteString(w, "success")
+		return
+	}
+	reply, err := s.ServeMessage(req)
+	if err != nil {
+		if s.Dedup != nil {
+			// 处理失败的消息允许重试
+			_ = s.Dedup.Forget(dedupKey)
+		}
+		s.error(w, r, err)
+		return
+	}
+	s.reply(w, msg, reply, crypt)
+}
+
+func (s *Server) reply(w http.ResponseWriter, msg *ServerMessage, reply *ResponseMessage, crypt *wechat.WXBizMsgCrypt) {
+	switch reply {
+	case nil:
+		return
+	case ReplySuccess:
+		_, _ = io.WriteString(w, "success")
+		return
+	}
+	err := Response(msg, reply, w, crypt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
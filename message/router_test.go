@@ -0,0 +1,139 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/wechattest"
+)
+
+const testToken = "token"
+
+// 返回回复 name 的 Handler
+func replyWith(name string) message.HandlerFunc {
+	return func(req *message.Request) (*message.ResponseMessage, error) {
+		return req.ReplyText(name)
+	}
+}
+
+func replyContent(t *testing.T, reply *message.ResponseMessage) string {
+	t.Helper()
+	if reply == nil || reply.Content == nil {
+		t.Fatalf("expected text reply, got %+v", reply)
+	}
+	return reply.Content.Value
+}
+
+func TestServerDispatchOrder(t *testing.T) {
+	s := message.NewServer(testToken, nil)
+	s.Default = replyWith("default")
+	s.HandleFunc(message.ServerMsgTypeText, replyWith("text"))
+	s.HandleFunc(message.ServerMsgTypeEvent, replyWith("event"))
+	s.HandleEventFunc(message.EvtUserClick, replyWith("click"))
+	s.HandleMenuFunc("MENU_KEY", replyWith("menu"))
+	sim := &wechattest.Simulator{Token: testToken}
+
+	cases := []struct {
+		msg  *wechattest.InboundMessage
+		want string
+	}{
+		// 菜单 EventKey 优先于事件类型
+		{wechattest.EventMessage("openid", message.EvtUserClick, "MENU_KEY"), "menu"},
+		// 未注册的 EventKey 使用事件类型的处理器
+		{wechattest.EventMessage("openid", message.EvtUserClick, "OTHER_KEY"), "click"},
+		// 未注册的事件使用 event 消息类型的处理器
+		{wechattest.EventMessage("openid", message.EvtUserSubscribe, ""), "event"},
+		// 非菜单事件不按 EventKey 查找
+		{wechattest.EventMessage("openid", message.EvtUserScan, "MENU_KEY"), "event"},
+		{wechattest.TextMessage("openid", "hello"), "text"},
+		{wechattest.NewInboundMessage("openid", message.ServerMsgTypeImage), "default"},
+	}
+	for _, c := range cases {
+		reply, err := sim.Send(s, c.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replyContent(t, reply); got != c.want {
+			t.Errorf("%s %s %q: got handler %q, want %q", c.msg.MsgType, c.msg.Event, c.msg.EventKey, got, c.want)
+		}
+	}
+}
+
+func TestServerNoHandler(t *testing.T) {
+	s := message.NewServer(testToken, nil)
+	sim := &wechattest.Simulator{Token: testToken}
+	reply, err := sim.Send(s, wechattest.TextMessage("openid", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != nil {
+		t.Errorf("expected no reply, got %+v", reply)
+	}
+}
+
+func TestServerVerify(t *testing.T) {
+	s := message.NewServer(testToken, nil)
+	sim := &wechattest.Simulator{Token: testToken}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, sim.NewVerifyRequest("echo"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "echo" {
+		t.Errorf("got %d %q, want 200 \"echo\"", rec.Code, rec.Body.String())
+	}
+
+	bad := &wechattest.Simulator{Token: "wrong"}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, bad.NewVerifyRequest("echo"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("bad signature: got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestServerEncrypted(t *testing.T) {
+	crypt, err := wechat.NewWXBizMsgCrypt(testToken, "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", "wx123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := message.NewServer(testToken, crypt)
+	s.HandleFunc(message.ServerMsgTypeText, func(req *message.Request) (*message.ResponseMessage, error) {
+		text, err := req.MarshalTextMessage()
+		if err != nil {
+			return nil, err
+		}
+		return req.ReplyText("echo: " + text.Content)
+	})
+	for _, mode := range []wechattest.EncryptMode{wechattest.ModePlaintext, wechattest.ModeCompatible, wechattest.ModeSecure} {
+		sim := &wechattest.Simulator{Token: testToken, Mode: mode, Crypt: crypt}
+		reply, err := sim.Send(s, wechattest.TextMessage("openid", "hi"))
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if got := replyContent(t, reply); got != "echo: hi" {
+			t.Errorf("mode %d: got %q", mode, got)
+		}
+	}
+}
+
+func TestServerHandlerError(t *testing.T) {
+	s := message.NewServer(testToken, nil)
+	s.HandleFunc(message.ServerMsgTypeText, func(req *message.Request) (*message.ResponseMessage, error) {
+		return nil, errors.New("failed")
+	})
+	sim := &wechattest.Simulator{Token: testToken}
+	req, err := sim.NewRequest(wechattest.TextMessage("openid", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
@@ -32,14 +32,24 @@ const (
 	EvtUserLocation EventType = "LOCATION"
 
 	// 自定义菜单事件
-	EvtUserClick EventType = "CLICK" // 点击菜单拉取消息时的事件推送
-	EvtUserView  EventType = "VIEW"  // 点击菜单跳转链接时的事件推送
+	EvtUserClick           EventType = "CLICK"              // 点击菜单拉取消息时的事件推送
+	EvtUserView            EventType = "VIEW"               // 点击菜单跳转链接时的事件推送
+	EvtUserScanCodePush    EventType = "scancode_push"      // 扫码推事件的事件推送
+	EvtUserScanCodeWaitMsg EventType = "scancode_waitmsg"   // 扫码推事件且弹出“消息接收中”提示框的事件推送
+	EvtUserPicSysPhoto     EventType = "pic_sysphoto"       // 弹出系统拍照发图的事件推送
+	EvtUserPicPhotoOrAlbum EventType = "pic_photo_or_album" // 弹出拍照或者相册发图的事件推送
+	EvtUserPicWeixin       EventType = "pic_weixin"         // 弹出微信相册发图器的事件推送
+	EvtUserLocationSelect  EventType = "location_select"    // 弹出地理位置选择器的事件推送
+	EvtUserViewMiniprogram EventType = "view_miniprogram"   // 点击菜单跳转小程序的事件推送
 
 	// 模板消息发送之后微信服务器会推送一个事件消息
 	EvtTemplateMsgResult EventType = "TEMPLATESENDJOBFINISH"
 
 	// 模板消息发送之后微信服务器会推送一个事件消息
 	EvtGroupMsgResult EventType = "MASSSENDJOBFINISH"
+
+	// 发布草稿之后微信服务器会推送发布结果, 见 MarshalPublishEventInfo
+	EvtPublishJobFinish EventType = "PUBLISHJOBFINISH"
 )
 
 // 微信服务器发出来的消息
@@ -64,9 +74,9 @@ const (
 	ServerMsgTypeLink ServerMsgType = "link"
 )
 
-// TODO: 1.完善自定义菜单事件推送, see: https://mp.weixin.qq.com/wiki?t=resource/res_main&id=mp1421141016
-// TODO: 2.完善对用户消息的解析, see: https://mp.weixin.qq.com/wiki?t=resource/res_main&id=mp1421140453
-// 微信服务器推送给本地服务器的消息
+// 微信服务器推送给本地服务器的消息, 通过 Marshal* 方法解析各类型消息的其他字段.
+// 普通消息见 https://mp.weixin.qq.com/wiki?t=resource/res_main&id=mp1421140453,
+// 自定义菜单事件见 https://mp.weixin.qq.com/wiki?t=resource/res_main&id=mp1421141016
 type ServerMessage struct {
 
 	// 微信原始ID
@@ -88,8 +98,12 @@ type ServerMessage struct {
 	// 如果用户扫描公众号二维码且用户未关注公众号时返回 qrscene_为前缀，后面为二维码的参数值
 	// 如果用户扫描公众号二维码且用户已关注公众号时返回二维码scene_id, 是一个32位无符号整数
 	// 如果是公众号菜单 CLICK 事件, 则返回自定义菜单所设置的 KEY 值
+	// 如果是公众号菜单 VIEW 或 view_miniprogram 事件, 则返回跳转的 URL 或小程序页面路径
 	EventKey string
 
+	// 消息ID, 只有普通消息有该字段
+	MsgId int64
+
 	// 微信服务器发送过来的原始消息数据, 通过原始消息数据进一步解析其他数据
 	Data []byte `xml:"-"`
 }
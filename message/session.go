@@ -0,0 +1,371 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat/platform"
+)
+
+// 会话用于在多次消息推送之间保存用户状态, 如 "输入手机号 -> 确认" 等多步操作. 会话按 appid + openid 区分,
+// 由 Server.Sessions 在调用 Handler 前读取并放入请求的 context, Handler 成功返回后保存:
+//
+//	session := message.SessionFrom(req.Context())
+//	session.Set("phone", phone)
+//	session.SetState("confirm")
+//
+// 同一用户的多条消息被并发处理时, 后保存的会话将覆盖先保存的会话.
+
+var ErrNoSession = errors.New("未设置 Server.Sessions, 请求中没有会话")
+
+// DefaultSessionTTL 是会话的默认有效期
+const DefaultSessionTTL = 30 * time.Minute
+
+// SessionStore 是会话存储器, value 为会话序列化后的数据
+type SessionStore interface {
+	// Load 读取会话, 会话不存在或已过期时返回空字符串
+	Load(key string) (value string, err error)
+
+	// Save 保存会话, 有效期为 ttl
+	Save(key, value string, ttl time.Duration) error
+
+	// Delete 删除会话
+	Delete(key string) error
+}
+
+// MemorySessionStore 是保存在内存中的 SessionStore, 只适用于单个进程
+type MemorySessionStore struct {
+	sessions  map[string]*memorySession
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+type memorySession struct {
+	value    string
+	expireAt time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*memorySession), lastSweep: time.Now()}
+}
+
+func (m *MemorySessionStore) Load(key string) (value string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[key]; ok && time.Now().Before(s.expireAt) {
+		return s.value, nil
+	}
+	return "", nil
+}
+
+func (m *MemorySessionStore) Save(key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions == nil {
+		m.sessions = make(map[string]*memorySession)
+	}
+	now := time.Now()
+	// 定期清理过期的会话
+	if now.Sub(m.lastSweep) > ttl {
+		for k, s := range m.sessions {
+			if now.After(s.expireAt) {
+				delete(m.sessions, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.sessions[key] = &memorySession{value: value, expireAt: now.Add(ttl)}
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, key)
+	return nil
+}
+
+// TTLDataStorage 是支持过期的存储器, 如使用 Redis 的 "SET key value PX ttl" 实现
+type TTLDataStorage interface {
+	platform.DataStorage
+
+	// StoreTTL 保存 value, 有效期为 ttl, 过期后由存储器删除
+	StoreTTL(key, value string, ttl time.Duration) error
+}
+
+// StorageSessionStore 使用 platform.DataStorage 保存会话.
+//
+// 存储器实现了 TTLDataStorage 时通过 StoreTTL 保存会话, 过期的会话由存储器删除. 否则过期时间只保存在会话数据中,
+// 读取到过期的会话时将其删除, 不再读取的过期会话仍需存储器自行清理.
+type StorageSessionStore struct {
+	storage platform.DataStorage
+	prefix  string
+}
+
+// NewStorageSessionStore 创建 StorageSessionStore, prefix 为 key 的前缀, 用于与存储器中的其他数据区分
+func NewStorageSessionStore(storage platform.DataStorage, prefix string) *StorageSessionStore {
+	return &StorageSessionStore{storage: storage, prefix: prefix}
+}
+
+func (s *StorageSessionStore) Load(key string) (value string, err error) {
+	value, err = s.storage.Read(s.prefix + key)
+	if err != nil || value == "" {
+		return value, err
+	}
+	data := &sessionData{}
+	// 无法解析的数据由 Sessions.Load 返回错误
+	if json.Unmarshal([]byte(value), data) == nil && time.Now().Unix() >= data.ExpireAt {
+		return "", s.storage.Del(s.prefix + key)
+	}
+	return value, nil
+}
+
+func (s *StorageSessionStore) Save(key, value string, ttl time.Duration) error {
+	if ts, ok := s.storage.(TTLDataStorage); ok {
+		return ts.StoreTTL(s.prefix+key, value, ttl)
+	}
+	return s.storage.Store(s.prefix+key, value)
+}
+
+func (s *StorageSessionStore) Delete(key string) error {
+	return s.storage.Del(s.prefix + key)
+}
+
+// State 是会话所处的状态, 空字符串为初始状态
+type State string
+
+// Session 是用户会话
+type Session struct {
+	// 公众号 appid 及用户 openid
+	Appid  string `json:"-"`
+	Openid string `json:"-"`
+
+	state   State
+	values  map[string]string
+	changed bool
+}
+
+// 会话的序列化格式
+type sessionData struct {
+	State    State             `json:"state,omitempty"`
+	Values   map[string]string `json:"values,omitempty"`
+	ExpireAt int64             `json:"expire_at"`
+}
+
+// State 返回会话当前的状态
+func (s *Session) State() State {
+	return s.state
+}
+
+// SetState 设置会话状态
+func (s *Session) SetState(state State) {
+	if s.state != state {
+		s.state = state
+		s.changed = true
+	}
+}
+
+// Get 读取会话数据, 不存在时返回空字符串
+func (s *Session) Get(name string) string {
+	return s.values[name]
+}
+
+// Set 设置会话数据
+func (s *Session) Set(name, value string) {
+	if s.values == nil {
+		s.values = make(map[string]string)
+	}
+	s.values[name] = value
+	s.changed = true
+}
+
+// Del 删除会话数据
+func (s *Session) Del(name string) {
+	if _, ok := s.values[name]; ok {
+		delete(s.values, name)
+		s.changed = true
+	}
+}
+
+// Clear 清空会话, 会话将从存储器中删除
+func (s *Session) Clear() {
+	s.state = ""
+	s.values = nil
+	s.changed = true
+}
+
+func (s *Session) empty() bool {
+	return s.state == "" && len(s.values) == 0
+}
+
+type sessionKey struct{}
+
+// SessionFrom 返回 ctx 中的会话, 未设置 Server.Sessions 时返回 nil
+func SessionFrom(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// Session 同 SessionFrom(r.Context())
+func (r *Request) Session() *Session {
+	return SessionFrom(r.Context())
+}
+
+// Sessions 管理用户会话
+type Sessions struct {
+	// 会话存储器
+	Store SessionStore
+
+	// 会话有效期, 每次保存后重新计算, 为 0 时为 DefaultSessionTTL
+	TTL time.Duration
+
+	// 公众号 appid
+	Appid string
+
+	// 获取消息所属公众号的 appid, 用于第三方平台同时接收多个公众号的消息, 不为 nil 时忽略 Appid.
+	// Appid 及 AppidFunc 都为空时使用公众号原始 ID 区分公众号
+	AppidFunc func(req *Request) (appid string, err error)
+}
+
+func NewSessions(store SessionStore, appid string) *Sessions {
+	return &Sessions{Store: store, Appid: appid}
+}
+
+func (ss *Sessions) ttl() time.Duration {
+	if ss.TTL > 0 {
+		return ss.TTL
+	}
+	return DefaultSessionTTL
+}
+
+func (ss *Sessions) appid(req *Request) (string, error) {
+	if ss.AppidFunc != nil {
+		return ss.AppidFunc(req)
+	}
+	if ss.Appid != "" {
+		return ss.Appid, nil
+	}
+	return req.ToUserName, nil
+}
+
+func sessionStoreKey(appid, openid string) string {
+	return appid + ":" + openid
+}
+
+// Load 读取用户会话, 会话不存在或已过期时返回空会话
+func (ss *Sessions) Load(appid, openid string) (*Session, error) {
+	session := &Session{Appid: appid, Openid: openid}
+	value, err := ss.Store.Load(sessionStoreKey(appid, openid))
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return session, nil
+	}
+	data := &sessionData{}
+	err = json.Unmarshal([]byte(value), data)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= data.ExpireAt {
+		return session, nil
+	}
+	session.state = data.State
+	session.values = data.Values
+	return session, nil
+}
+
+// Save 保存会话, 并重新计算有效期. 空会话将从存储器中删除
+func (ss *Sessions) Save(session *Session) error {
+	key := sessionStoreKey(session.Appid, session.Openid)
+	if session.empty() {
+		if session.changed {
+			session.changed = false
+			return ss.Store.Delete(key)
+		}
+		return nil
+	}
+	ttl := ss.ttl()
+	data, err := json.Marshal(&sessionData{
+		State:    session.state,
+		Values:   session.values,
+		ExpireAt: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	session.changed = false
+	return ss.Store.Save(key, string(data), ttl)
+}
+
+// 读取会话并放入请求的 context, h 处理成功后保存会话
+func (ss *Sessions) serve(h Handler, req *Request) (*ResponseMessage, error) {
+	appid, err := ss.appid(req)
+	if err != nil {
+		return nil, err
+	}
+	session, err := ss.Load(appid, req.FromUserName)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := h.ServeMessage(req.WithContext(context.WithValue(req.Context(), sessionKey{}, session)))
+	if err != nil {
+		return nil, err
+	}
+	err = ss.Save(session)
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// StateMachine 按会话状态将消息分发给不同的 Handler, 需要设置 Server.Sessions. Handler 通过 SetState 转换状态:
+//
+//	m := message.NewStateMachine()
+//	m.HandleFunc("", askPhone)           // 初始状态, 询问手机号并 SetState("phone")
+//	m.HandleFunc("phone", confirmPhone)  // 确认手机号后 Clear 会话
+//	server.Handle(message.ServerMsgTypeText, m)
+type StateMachine struct {
+	// 当前状态没有对应的 Handler 时使用, 为 nil 时不回复
+	Default Handler
+
+	handlers map[State]Handler
+}
+
+func NewStateMachine() *StateMachine {
+	return &StateMachine{handlers: make(map[State]Handler)}
+}
+
+// Handle 注册 state 状态下的消息处理器
+func (m *StateMachine) Handle(state State, h Handler) {
+	if m.handlers == nil {
+		m.handlers = make(map[State]Handler)
+	}
+	m.handlers[state] = h
+}
+
+func (m *StateMachine) HandleFunc(state State, f func(req *Request) (*ResponseMessage, error)) {
+	m.Handle(state, HandlerFunc(f))
+}
+
+func (m *StateMachine) ServeMessage(req *Request) (*ResponseMessage, error) {
+	session := req.Session()
+	if session == nil {
+		return nil, ErrNoSession
+	}
+	h, ok := m.handlers[session.State()]
+	if !ok {
+		h = m.Default
+	}
+	if h == nil {
+		return nil, nil
+	}
+	return h.ServeMessage(req)
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func TestSessionTTL(t *testing.T) {
+	store := message.NewMemorySessionStore()
+	ss := message.NewSessions(store, "wx123")
+	ss.TTL = time.Second
+
+	session, err := ss.Load("wx123", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.SetState("phone")
+	session.Set("phone", "10086")
+	err = ss.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = ss.Load("wx123", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.State() != "phone" || session.Get("phone") != "10086" {
+		t.Fatalf("got state %q phone %q", session.State(), session.Get("phone"))
+	}
+
+	// 会话数据中记录的过期时间精确到秒
+	time.Sleep(2 * time.Second)
+	session, err = ss.Load("wx123", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.State() != "" || session.Get("phone") != "" {
+		t.Errorf("expired session: got state %q phone %q", session.State(), session.Get("phone"))
+	}
+}
+
+func TestSessionClear(t *testing.T) {
+	store := message.NewMemorySessionStore()
+	ss := message.NewSessions(store, "wx123")
+	session, _ := ss.Load("wx123", "openid")
+	session.Set("name", "value")
+	err := ss.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Clear()
+	err = ss.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := store.Load("wx123:openid"); value != "" {
+		t.Errorf("cleared session should be deleted, got %s", value)
+	}
+}
+
+func TestStorageSessionStore(t *testing.T) {
+	ss := message.NewSessions(message.NewStorageSessionStore(newMemoryStorage(), "session:"), "wx123")
+	session, _ := ss.Load("wx123", "openid")
+	session.SetState("confirm")
+	err := ss.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = ss.Load("wx123", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.State() != "confirm" {
+		t.Errorf("got state %q, want %q", session.State(), "confirm")
+	}
+	other, _ := ss.Load("wx123", "other")
+	if other.State() != "" {
+		t.Errorf("sessions of different users should be separated, got state %q", other.State())
+	}
+}
+
+// 实现 StoreTTL 的存储器, 记录保存时的有效期
+type ttlStorage struct {
+	*memoryStorage
+	ttls map[string]time.Duration
+}
+
+func (s *ttlStorage) StoreTTL(key, value string, ttl time.Duration) error {
+	s.ttls[key] = ttl
+	return s.Store(key, value)
+}
+
+func TestStorageSessionStoreExpire(t *testing.T) {
+	// 读取到过期的会话时将其删除
+	storage := newMemoryStorage()
+	storage.Store("session:wx123:openid", `{"state":"confirm","expire_at":1}`)
+	ss := message.NewSessions(message.NewStorageSessionStore(storage, "session:"), "wx123")
+	session, err := ss.Load("wx123", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.State() != "" {
+		t.Errorf("expired session: got state %q", session.State())
+	}
+	if _, ok := storage.data["session:wx123:openid"]; ok {
+		t.Error("expired session should be deleted")
+	}
+
+	// 支持过期的存储器使用会话有效期保存
+	ts := &ttlStorage{memoryStorage: newMemoryStorage(), ttls: make(map[string]time.Duration)}
+	ss = message.NewSessions(message.NewStorageSessionStore(ts, "session:"), "wx123")
+	ss.TTL = time.Hour
+	session.SetState("confirm")
+	err = ss.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := ts.ttls["session:wx123:openid"]; ttl != time.Hour {
+		t.Errorf("got ttl %v, want %v", ttl, time.Hour)
+	}
+	session, _ = ss.Load("wx123", "openid")
+	if session.State() != "confirm" {
+		t.Errorf("got state %q, want %q", session.State(), "confirm")
+	}
+}
+
+func TestStateMachine(t *testing.T) {
+	// 零值可以直接使用
+	m := &message.StateMachine{}
+	m.HandleFunc("", func(req *message.Request) (*message.ResponseMessage, error) {
+		req.Session().SetState("phone")
+		return req.ReplyText("input phone")
+	})
+	m.HandleFunc("phone", func(req *message.Request) (*message.ResponseMessage, error) {
+		text, err := req.MarshalTextMessage()
+		if err != nil {
+			return nil, err
+		}
+		req.Session().Clear()
+		return req.ReplyText("phone: " + text.Content)
+	})
+	s := message.NewServer(testToken, nil)
+	s.Sessions = message.NewSessions(&message.MemorySessionStore{}, "wx123")
+	s.Handle(message.ServerMsgTypeText, m)
+	sim := &wechattest.Simulator{Token: testToken}
+
+	for _, want := range []string{"input phone", "phone: 10086", "input phone"} {
+		reply, err := sim.Send(s, wechattest.TextMessage("openid", "10086"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replyContent(t, reply); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestStateMachineWithoutSessions(t *testing.T) {
+	m := message.NewStateMachine()
+	_, err := m.ServeMessage(&message.Request{ServerMessage: &message.ServerMessage{}})
+	if err != message.ErrNoSession {
+		t.Errorf("got %v, want ErrNoSession", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics 接收接口调用的统计数据, 可对接 Prometheus 等监控系统.
+type Metrics interface {
+	// 获得响应的请求计数, 对应 Counter: wechat_requests_total{appid, endpoint, errcode}
+	IncRequest(appid, endpoint string, errcode int)
+
+	// 未获得响应的请求计数(网络错误、超时、限流等), 对应 Counter: wechat_request_errors_total{appid, endpoint}.
+	// 与 errcode 为 -1(系统繁忙)的响应分开统计
+	IncError(appid, endpoint string)
+
+	// 请求耗时, 对应 Histogram: wechat_request_duration_seconds{appid, endpoint}
+	ObserveLatency(appid, endpoint string, latency time.Duration)
+}
+
+// MetricsInterceptor 返回将每次接口调用的统计数据写入 m 的拦截器
+func MetricsInterceptor(m Metrics) Interceptor {
+	return func(call *Call, next Invoker) error {
+		err := next(call)
+		if err != nil {
+			m.IncError(call.Appid, call.Endpoint)
+		} else {
+			m.IncRequest(call.Appid, call.Endpoint, call.ErrCode)
+		}
+		m.ObserveLatency(call.Appid, call.Endpoint, call.Latency)
+		return err
+	}
+}
+
+// 默认耗时分布区间(秒)
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type counterKey struct {
+	appid, endpoint string
+	errcode         int
+}
+
+type histogramKey struct {
+	appid, endpoint string
+}
+
+type histogram struct {
+	// 创建时的分布区间, 之后修改 MemoryMetrics.Buckets 不影响已有的统计
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// MemoryMetrics 是在内存中统计的 Metrics 实现, 可通过 WritePrometheus 以 Prometheus 文本格式输出,
+// 如挂载到 /metrics 接口上供 Prometheus 抓取.
+type MemoryMetrics struct {
+	// 耗时分布区间(秒), 需按升序排列, 为空时使用 DefaultLatencyBuckets
+	Buckets []float64
+
+	mu         sync.Mutex
+	counters   map[counterKey]uint64
+	errors     map[histogramKey]uint64
+	histograms map[histogramKey]*histogram
+}
+
+func (m *MemoryMetrics) buckets() []float64 {
+	if len(m.Buckets) > 0 {
+		return m.Buckets
+	}
+	return DefaultLatencyBuckets
+}
+
+func (m *MemoryMetrics) IncRequest(appid, endpoint string, errcode int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[counterKey]uint64)
+	}
+	m.counters[counterKey{appid, endpoint, errcode}]++
+}
+
+func (m *MemoryMetrics) IncError(appid, endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.errors == nil {
+		m.errors = make(map[histogramKey]uint64)
+	}
+	m.errors[histogramKey{appid, endpoint}]++
+}
+
+func (m *MemoryMetrics) ObserveLatency(appid, endpoint string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.histograms == nil {
+		m.histograms = make(map[histogramKey]*histogram)
+	}
+	key := histogramKey{appid, endpoint}
+	h := m.histograms[key]
+	if h == nil {
+		buckets := append([]float64(nil), m.buckets()...)
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		m.histograms[key] = h
+	}
+	seconds := latency.Seconds()
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出统计数据
+func (m *MemoryMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	counterKeys := make([]counterKey, 0, len(m.counters))
+	for key := range m.counters {
+		counterKeys = append(counterKeys, key)
+	}
+	sort.Slice(counterKeys, func(i, j int) bool {
+		a, b := counterKeys[i], counterKeys[j]
+		if a.appid != b.appid {
+			return a.appid < b.appid
+		}
+		if a.endpoint != b.endpoint {
+			return a.endpoint < b.endpoint
+		}
+		return a.errcode < b.errcode
+	})
+	printf("# HELP wechat_requests_total Total number of wechat api calls.\n")
+	printf("# TYPE wechat_requests_total counter\n")
+	for _, key := range counterKeys {
+		printf("wechat_requests_total{appid=%q,endpoint=%q,errcode=\"%d\"} %d\n",
+			key.appid, key.endpoint, key.errcode, m.counters[key])
+	}
+
+	errorKeys := sortedKeys(m.errors)
+	printf("# HELP wechat_request_errors_total Total number of wechat api calls without response.\n")
+	printf("# TYPE wechat_request_errors_total counter\n")
+	for _, key := range errorKeys {
+		printf("wechat_request_errors_total{appid=%q,endpoint=%q} %d\n", key.appid, key.endpoint, m.errors[key])
+	}
+
+	histogramKeys := make([]histogramKey, 0, len(m.histograms))
+	for key := range m.histograms {
+		histogramKeys = append(histogramKeys, key)
+	}
+	sortHistogramKeys(histogramKeys)
+	printf("# HELP wechat_request_duration_seconds Latency of wechat api calls.\n")
+	printf("# TYPE wechat_request_duration_seconds histogram\n")
+	for _, key := range histogramKeys {
+		h := m.histograms[key]
+		for i, bound := range h.buckets {
+			printf("wechat_request_duration_seconds_bucket{appid=%q,endpoint=%q,le=%q} %d\n",
+				key.appid, key.endpoint, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		printf("wechat_request_duration_seconds_bucket{appid=%q,endpoint=%q,le=\"+Inf\"} %d\n",
+			key.appid, key.endpoint, h.count)
+		printf("wechat_request_duration_seconds_sum{appid=%q,endpoint=%q} %g\n", key.appid, key.endpoint, h.sum)
+		printf("wechat_request_duration_seconds_count{appid=%q,endpoint=%q} %d\n", key.appid, key.endpoint, h.count)
+	}
+	return err
+}
+
+func sortedKeys(m map[histogramKey]uint64) []histogramKey {
+	keys := make([]histogramKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sortHistogramKeys(keys)
+	return keys
+}
+
+func sortHistogramKeys(keys []histogramKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.appid != b.appid {
+			return a.appid < b.appid
+		}
+		return a.endpoint < b.endpoint
+	})
+}
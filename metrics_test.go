@@ -0,0 +1,82 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryMetricsPrometheus(t *testing.T) {
+	m := &MemoryMetrics{Buckets: []float64{0.1, 1}}
+	m.IncRequest("wx1", "/cgi-bin/menu/get", 0)
+	m.IncRequest("wx1", "/cgi-bin/menu/get", 0)
+	m.IncRequest("wx1", "/cgi-bin/menu/get", ErrCodeSystemBusy)
+	m.IncError("wx1", "/cgi-bin/menu/get")
+	m.ObserveLatency("wx1", "/cgi-bin/menu/get", 50*time.Millisecond)
+	m.ObserveLatency("wx1", "/cgi-bin/menu/get", 500*time.Millisecond)
+	m.ObserveLatency("wx1", "/cgi-bin/menu/get", 2*time.Second)
+
+	// 修改分布区间不影响已创建的统计
+	m.Buckets = []float64{0.5}
+	m.ObserveLatency("wx1", "/cgi-bin/menu/get", 0)
+	m.ObserveLatency("wx2", "/cgi-bin/menu/get", time.Second)
+
+	buf := &bytes.Buffer{}
+	err := m.WritePrometheus(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP wechat_requests_total Total number of wechat api calls.
+# TYPE wechat_requests_total counter
+wechat_requests_total{appid="wx1",endpoint="/cgi-bin/menu/get",errcode="-1"} 1
+wechat_requests_total{appid="wx1",endpoint="/cgi-bin/menu/get",errcode="0"} 2
+# HELP wechat_request_errors_total Total number of wechat api calls without response.
+# TYPE wechat_request_errors_total counter
+wechat_request_errors_total{appid="wx1",endpoint="/cgi-bin/menu/get"} 1
+# HELP wechat_request_duration_seconds Latency of wechat api calls.
+# TYPE wechat_request_duration_seconds histogram
+wechat_request_duration_seconds_bucket{appid="wx1",endpoint="/cgi-bin/menu/get",le="0.1"} 2
+wechat_request_duration_seconds_bucket{appid="wx1",endpoint="/cgi-bin/menu/get",le="1"} 3
+wechat_request_duration_seconds_bucket{appid="wx1",endpoint="/cgi-bin/menu/get",le="+Inf"} 4
+wechat_request_duration_seconds_sum{appid="wx1",endpoint="/cgi-bin/menu/get"} 2.55
+wechat_request_duration_seconds_count{appid="wx1",endpoint="/cgi-bin/menu/get"} 4
+wechat_request_duration_seconds_bucket{appid="wx2",endpoint="/cgi-bin/menu/get",le="0.5"} 0
+wechat_request_duration_seconds_bucket{appid="wx2",endpoint="/cgi-bin/menu/get",le="+Inf"} 1
+wechat_request_duration_seconds_sum{appid="wx2",endpoint="/cgi-bin/menu/get"} 1
+wechat_request_duration_seconds_count{appid="wx2",endpoint="/cgi-bin/menu/get"} 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	m := &MemoryMetrics{}
+	intercept := MetricsInterceptor(m)
+	call := &Call{Appid: "wx1", Endpoint: "/api", ErrCode: ErrCodeSystemBusy, Latency: time.Millisecond}
+	err := intercept(call, func(*Call) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	netErr := errors.New("connection reset")
+	call = &Call{Appid: "wx1", Endpoint: "/api", Latency: time.Millisecond}
+	err = intercept(call, func(*Call) error { return netErr })
+	if err != netErr {
+		t.Fatalf("got %v, want %v", err, netErr)
+	}
+	// 网络错误与系统繁忙分开统计
+	if n := m.counters[counterKey{"wx1", "/api", ErrCodeSystemBusy}]; n != 1 {
+		t.Errorf("got %d system busy responses, want 1", n)
+	}
+	if n := m.errors[histogramKey{"wx1", "/api"}]; n != 1 {
+		t.Errorf("got %d transport errors, want 1", n)
+	}
+	if n := m.histograms[histogramKey{"wx1", "/api"}].count; n != 2 {
+		t.Errorf("got %d latency observations, want 2", n)
+	}
+}
@@ -12,6 +12,11 @@ import (
 // 公众号获得 access token, access token 包含了用户的 openid
 // 有 IP 白名单限制
 func GetAccessToken(appid, secret, code string) (token *AccessToken, err error) {
+	return GetAccessTokenWith(wechat.DefaultClient, appid, secret, code)
+}
+
+// GetAccessTokenWith 同 GetAccessToken, 通过客户端 c 发送请求
+func GetAccessTokenWith(c *wechat.Client, appid, secret, code string) (token *AccessToken, err error) {
 	u, _ := query.Values(&config{
 		AppID:     appid,
 		Secret:    secret,
@@ -19,7 +24,7 @@ func GetAccessToken(appid, secret, code string) (token *AccessToken, err error)
 		GrantType: "authorization_code",
 	})
 	token = &AccessToken{}
-	err = wechat.GetJson("https://api.weixin.qq.com/sns/oauth2/access_token?"+u.Encode(), token)
+	err = c.GetJson("https://api.weixin.qq.com/sns/oauth2/access_token?"+u.Encode(), token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -48,6 +53,11 @@ type AccessToken struct {
 
 // 第三方平台获得 access token, 有 IP 白名单限制
 func GetComponentAccessToken(appid, code, componentAppid, componentAccessToken string) (token *AccessToken, err error) {
+	return GetComponentAccessTokenWith(wechat.DefaultClient, appid, code, componentAppid, componentAccessToken)
+}
+
+// GetComponentAccessTokenWith 同 GetComponentAccessToken, 通过客户端 c 发送请求
+func GetComponentAccessTokenWith(c *wechat.Client, appid, code, componentAppid, componentAccessToken string) (token *AccessToken, err error) {
 	u, _ := query.Values(&config{
 		AppID:                appid,
 		Code:                 code,
@@ -56,7 +66,7 @@ func GetComponentAccessToken(appid, code, componentAppid, componentAccessToken s
 		ComponentAccessToken: componentAccessToken,
 	})
 	token = &AccessToken{}
-	err = wechat.GetJson("https://api.weixin.qq.com/sns/oauth2/component/access_token?"+u.Encode(), token)
+	err = c.GetJson("https://api.weixin.qq.com/sns/oauth2/component/access_token?"+u.Encode(), token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -69,13 +79,18 @@ func GetComponentAccessToken(appid, code, componentAppid, componentAccessToken s
 // 由于access_token拥有较短的有效期，当access_token超时后，可以使用refresh_token进行刷新，
 // refresh_token有效期为30天，当refresh_token失效之后，需要用户重新授权。
 func RefreshAccessToken(appid, refreshToken string) (token *AccessToken, err error) {
+	return RefreshAccessTokenWith(wechat.DefaultClient, appid, refreshToken)
+}
+
+// RefreshAccessTokenWith 同 RefreshAccessToken, 通过客户端 c 发送请求
+func RefreshAccessTokenWith(c *wechat.Client, appid, refreshToken string) (token *AccessToken, err error) {
 	u, _ := query.Values(&refreshConfig{
 		Appid:        appid,
 		GrantType:    "refresh_token",
 		RefreshToken: refreshToken,
 	})
 	token = &AccessToken{}
-	err = wechat.GetJson("https://api.weixin.qq.com/sns/oauth2/refresh_token?"+u.Encode(), token)
+	err = c.GetJson("https://api.weixin.qq.com/sns/oauth2/refresh_token?"+u.Encode(), token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -88,6 +103,11 @@ func RefreshAccessToken(appid, refreshToken string) (token *AccessToken, err err
 // 由于access_token拥有较短的有效期，当access_token超时后，可以使用refresh_token进行刷新，
 // refresh_token拥有较长的有效期（30天），当refresh_token失效的后，需要用户重新授权。
 func RefreshComponentAccessToken(appid, refreshToken, componentAppid, componentAccessToken string) (token *AccessToken, err error) {
+	return RefreshComponentAccessTokenWith(wechat.DefaultClient, appid, refreshToken, componentAppid, componentAccessToken)
+}
+
+// RefreshComponentAccessTokenWith 同 RefreshComponentAccessToken, 通过客户端 c 发送请求
+func RefreshComponentAccessTokenWith(c *wechat.Client, appid, refreshToken, componentAppid, componentAccessToken string) (token *AccessToken, err error) {
 	u, _ := query.Values(&refreshConfig{
 		Appid:                appid,
 		GrantType:            "refresh_token",
@@ -96,7 +116,7 @@ func RefreshComponentAccessToken(appid, refreshToken, componentAppid, componentA
 		ComponentAccessToken: componentAccessToken,
 	})
 	token = &AccessToken{}
-	err = wechat.GetJson("https://api.weixin.qq.com/sns/oauth2/component/refresh_token?"+u.Encode(), token)
+	err = c.GetJson("https://api.weixin.qq.com/sns/oauth2/component/refresh_token?"+u.Encode(), token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -62,9 +62,14 @@ type User struct {
 
 // accessToken 必须是在 scope 为 "snsapi_userinfo" 下获得的(即用户点击确认授权后)才有效
 func GetUserInfo(openid, accessToken string) (user *User, err error) {
+	return GetUserInfoWith(wechat.DefaultClient, openid, accessToken)
+}
+
+// GetUserInfoWith 同 GetUserInfo, 通过客户端 c 发送请求
+func GetUserInfoWith(c *wechat.Client, openid, accessToken string) (user *User, err error) {
 	uri := "https://api.weixin.qq.com/sns/userinfo?lang=zh_CN&access_token=" + accessToken + "&openid=" + openid
 	user = &User{}
-	err = wechat.GetJson(uri, user)
+	err = c.GetJson(uri, user)
 	if err != nil {
 		return nil, err
 	} else {
@@ -74,6 +79,11 @@ func GetUserInfo(openid, accessToken string) (user *User, err error) {
 
 // accessToken 为用户所关注公众号的 access token, 非用户授权获得的 token, 只能在用户关注公众号之后才能使用
 func GetSubscribersInfo(openids []string, accessToken string) (users []*User, err error) {
+	return GetSubscribersInfoWith(wechat.DefaultClient, openids, accessToken)
+}
+
+// GetSubscribersInfoWith 同 GetSubscribersInfo, 通过客户端 c 发送请求
+func GetSubscribersInfoWith(c *wechat.Client, openids []string, accessToken string) (users []*User, err error) {
 	if ln := len(openids); ln > 100 {
 		return nil, ErrGetUsersMoreThan100
 	} else if ln > 0 {
@@ -83,7 +93,7 @@ func GetSubscribersInfo(openids []string, accessToken string) (users []*User, er
 			list[key] = &openid{Openid: id}
 		}
 		res := &userInfoList{}
-		err = wechat.PostSchema(wechat.KindJson, uri, &getUsers{UserList: list}, res)
+		err = c.Idempotent().PostSchema(wechat.KindJson, uri, &getUsers{UserList: list}, res)
 		if err != nil {
 			return nil, err
 		} else {
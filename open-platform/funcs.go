@@ -60,13 +60,18 @@ type ComponentAccessToken struct {
 // 第三方平台component_access_token是第三方平台的下文中接口的调用凭据，也叫做令牌（component_access_token）。
 // 每个令牌是存在有效期（2小时）的，且令牌的调用不是无限制的，请第三方平台做好令牌的管理，在令牌快过期时（比如1小时50分）再进行刷新。
 func GetComponentAccessToken(componentAppid, appSecret, verifyTicket string) (token *ComponentAccessToken, err error) {
+	return GetComponentAccessTokenWith(wechat.DefaultClient, componentAppid, appSecret, verifyTicket)
+}
+
+// GetComponentAccessTokenWith 同 GetComponentAccessToken, 通过客户端 c 发送请求
+func GetComponentAccessTokenWith(c *wechat.Client, componentAppid, appSecret, verifyTicket string) (token *ComponentAccessToken, err error) {
 	data := map[string]string{
 		"component_appid":         componentAppid,
 		"component_appsecret":     appSecret,
 		"component_verify_ticket": verifyTicket,
 	}
 	token = &ComponentAccessToken{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_component_token", data, token)
+	err = c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_component_token", data, token)
 	if err != nil {
 		return nil, err
 	} else {
@@ -82,9 +87,14 @@ type PreAuthCode struct {
 // 获取预授权码pre_auth_code
 // 该API用于获取预授权码。预授权码用于公众号或小程序授权时的第三方平台方安全验证。
 func GetPureAuthCode(componentAppid, componentAccessToken string) (code *PreAuthCode, err error) {
+	return GetPureAuthCodeWith(wechat.DefaultClient, componentAppid, componentAccessToken)
+}
+
+// GetPureAuthCodeWith 同 GetPureAuthCode, 通过客户端 c 发送请求
+func GetPureAuthCodeWith(c *wechat.Client, componentAppid, componentAccessToken string) (code *PreAuthCode, err error) {
 	data := map[string]string{"component_appid": componentAppid}
 	code = &PreAuthCode{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_create_preauthcode?component_access_token="+componentAccessToken, data, code)
+	err = c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_create_preauthcode?component_access_token="+componentAccessToken, data, code)
 	if err != nil {
 		return nil, err
 	} else {
@@ -139,12 +149,17 @@ type info struct {
 // 根据 authorizationCode 换取授权信息.
 // authorizationCode 是在授权成功时返回的数据，详见第三方平台授权流程说明
 func GetAuthorizationInfo(componentAppid, authorizationCode, componentAccessToken string) (ai *AuthorizationInfo, err error) {
+	return GetAuthorizationInfoWith(wechat.DefaultClient, componentAppid, authorizationCode, componentAccessToken)
+}
+
+// GetAuthorizationInfoWith 同 GetAuthorizationInfo, 通过客户端 c 发送请求
+func GetAuthorizationInfoWith(c *wechat.Client, componentAppid, authorizationCode, componentAccessToken string) (ai *AuthorizationInfo, err error) {
 	data := map[string]string{
 		"component_appid":    componentAppid,
 		"authorization_code": authorizationCode,
 	}
 	res := &info{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_query_auth?component_access_token="+componentAccessToken, data, res)
+	err = c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_query_auth?component_access_token="+componentAccessToken, data, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -164,13 +179,18 @@ type AuthorizerToken struct {
 // 该API用于在授权方令牌（authorizer_access_token）失效时，可用刷新令牌（authorizer_refresh_token）获取新的令
 // 牌。 请注意，此处token是2小时刷新一次，开发者需要自行进行token的缓存，避免token的获取次数达到每日的限定额度。
 func RefreshAuthorizerToken(componentAppid, authorizerAppid, authorizerRefreshToken, componentAccessToken string) (at *AuthorizerToken, err error) {
+	return RefreshAuthorizerTokenWith(wechat.DefaultClient, componentAppid, authorizerAppid, authorizerRefreshToken, componentAccessToken)
+}
+
+// RefreshAuthorizerTokenWith 同 RefreshAuthorizerToken, 通过客户端 c 发送请求
+func RefreshAuthorizerTokenWith(c *wechat.Client, componentAppid, authorizerAppid, authorizerRefreshToken, componentAccessToken string) (at *AuthorizerToken, err error) {
 	data := map[string]string{
 		"component_appid":          componentAppid,
 		"authorizer_appid":         authorizerAppid,
 		"authorizer_refresh_token": authorizerRefreshToken,
 	}
 	at = &AuthorizerToken{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_authorizer_token?component_access_token="+componentAccessToken, data, at)
+	err = c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_authorizer_token?component_access_token="+componentAccessToken, data, at)
 	if err != nil {
 		return nil, err
 	} else {
@@ -236,12 +256,17 @@ type Authorizer struct {
 // 需要特别记录授权方的帐号类型，在消息及事件推送时，对于不具备客服接口的公众号，需要在5秒内立即响应；
 // 而若有客服接口，则可以选择暂时不响应，而选择后续通过客服接口来发送消息触达粉丝。
 func GetAuthorizerInfo(componentAppid, authorizerAppid, componentAccessToken string) (a *Authorizer, err error) {
+	return GetAuthorizerInfoWith(wechat.DefaultClient, componentAppid, authorizerAppid, componentAccessToken)
+}
+
+// GetAuthorizerInfoWith 同 GetAuthorizerInfo, 通过客户端 c 发送请求
+func GetAuthorizerInfoWith(c *wechat.Client, componentAppid, authorizerAppid, componentAccessToken string) (a *Authorizer, err error) {
 	data := map[string]string{
 		"component_appid":  componentAppid,
 		"authorizer_appid": authorizerAppid,
 	}
 	res := &Authorizer{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_get_authorizer_info?component_access_token="+componentAccessToken, data, res)
+	err = c.Idempotent().PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_get_authorizer_info?component_access_token="+componentAccessToken, data, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -271,26 +296,36 @@ type Option struct {
 // customer_service（多客服开关选项）	     0	              关闭多客服
 //	                                     1	              开启多客服
 func SetAuthorizerOption(componentAppid, accessToken string, option Option) error {
+	return SetAuthorizerOptionWith(wechat.DefaultClient, componentAppid, accessToken, option)
+}
+
+// SetAuthorizerOptionWith 同 SetAuthorizerOption, 通过客户端 c 发送请求
+func SetAuthorizerOptionWith(c *wechat.Client, componentAppid, accessToken string, option Option) error {
 	data := map[string]string{
 		"component_appid":  componentAppid,
 		"authorizer_appid": option.AuthorizerAppid,
 		"option_name":      option.OptionName,
 		"option_value":     option.OptionValue,
 	}
-	return wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/ api_set_authorizer_option?component_access_token="+accessToken, data, nil)
+	return c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_set_authorizer_option?component_access_token="+accessToken, data, nil)
 }
 
 // 获取授权方的选项设置信息
 // 该API用于获取授权方的公众号或小程序的选项设置信息，如：地理位置上报，语音识别开关，
 // 多客服开关。注意，获取各项选项设置信息，需要有授权方的授权，详见权限集说明。
 func GetAuthorizerOption(componentAppid, authorizerAppid, optionName, componentAccessToken string) (option *Option, err error) {
+	return GetAuthorizerOptionWith(wechat.DefaultClient, componentAppid, authorizerAppid, optionName, componentAccessToken)
+}
+
+// GetAuthorizerOptionWith 同 GetAuthorizerOption, 通过客户端 c 发送请求
+func GetAuthorizerOptionWith(c *wechat.Client, componentAppid, authorizerAppid, optionName, componentAccessToken string) (option *Option, err error) {
 	data := map[string]string{
 		"component_appid":  componentAppid,
 		"authorizer_appid": authorizerAppid,
 		"option_name":      optionName,
 	}
 	option = &Option{}
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/ api_get_authorizer_option?component_access_token="+componentAccessToken, data, option)
+	err = c.Idempotent().PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/component/api_get_authorizer_option?component_access_token="+componentAccessToken, data, option)
 	if err != nil {
 		return nil, err
 	} else {
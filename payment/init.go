@@ -54,6 +54,11 @@ type Config struct {
 //
 // Tips: 返回的 UserConfig 可以自行配置 Debug
 func InitPayment(request *PayRequest, cfg *Config, ticket, payUrl string) (uc *UserConfig, pay *ChoosePay, err error) {
+	return InitPaymentWith(wechat.DefaultClient, request, cfg, ticket, payUrl)
+}
+
+// InitPaymentWith 同 InitPayment, 通过客户端 c 发送请求
+func InitPaymentWith(c *wechat.Client, request *PayRequest, cfg *Config, ticket, payUrl string) (uc *UserConfig, pay *ChoosePay, err error) {
 	// 生成随机字符串
 	nonceStr := string(utils.RandomBytes(32))
 	request.BaseInfo = BaseInfo{
@@ -73,7 +78,7 @@ func InitPayment(request *PayRequest, cfg *Config, ticket, payUrl string) (uc *U
 	request.Sign = sign
 	payRes := &payResponse{}
 	// 发起统一下单请求并获得响应
-	err = wechat.PostSchema(wechat.KindXml, "https://api.mch.weixin.qq.com/pay/unifiedorder", request, payRes)
+	err = c.PostSchema(wechat.KindXml, "https://api.mch.weixin.qq.com/pay/unifiedorder", request, payRes)
 	if err != nil {
 		return nil, nil, err
 	}
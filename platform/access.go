@@ -5,6 +5,7 @@
 package platform
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/orivil/wechat"
@@ -30,6 +31,8 @@ func (na *ErrAppNotAuthorized) Error() string {
 }
 
 type ComponentAccess struct {
+	// 发送请求的客户端, 为 nil 时使用 wechat.DefaultClient
+	Client       *wechat.Client
 	Appid        string
 	VerifyTicket *DataContainer
 	PreAuthCode  *ExpireDataContainer
@@ -40,18 +43,58 @@ type ComponentAccess struct {
 // 可通过 ComponentAuthNotify 监听授权方最新的授权权限动态. 由于授权方所授权的权限并不一定就是第三
 // 方平台所设置的权限, 授权方可选择部分权限, 因此有必要根据授权事件更新授权方信息.
 func (ca *ComponentAccess) GetAuthorizer(appid string) (info *open_platform.Authorizer, err error) {
-	accessToken, err := ca.AccessToken.Get(ca.Appid)
+	return ca.GetAuthorizerContext(context.Background(), appid)
+}
+
+// GetAuthorizerContext 同 GetAuthorizer, 请求受 ctx 控制
+func (ca *ComponentAccess) GetAuthorizerContext(ctx context.Context, appid string) (info *open_platform.Authorizer, err error) {
+	err = ca.Call(ctx, func(c *wechat.Client, token string) error {
+		info, err = open_platform.GetAuthorizerInfoWith(c, ca.Appid, appid, token)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return open_platform.GetAuthorizerInfo(ca.Appid, appid, accessToken)
+	return info, nil
+}
+
+// Call 使用第三方平台 component access token 调用 fn, 如果 fn 返回令牌失效错误(40001, 42001, 40014),
+// 则获得新的令牌并重试一次.
+func (ca *ComponentAccess) Call(ctx context.Context, fn func(c *wechat.Client, token string) error) error {
+	return callWithToken(ctx, ca.AccessToken, ca.Appid, func(token string) error {
+		return fn(ca.client(ctx), token)
+	})
 }
 
 func (ca *ComponentAccess) GetAccessToken() (token string, err error) {
-	return ca.AccessToken.Get(ca.Appid)
+	return ca.GetAccessTokenContext(context.Background())
+}
+
+// GetAccessTokenContext 同 GetAccessToken, 请求受 ctx 控制
+func (ca *ComponentAccess) GetAccessTokenContext(ctx context.Context) (token string, err error) {
+	return ca.AccessToken.GetContext(ctx, ca.Appid)
+}
+
+// 获得绑定了 ctx 及 appid 的客户端
+func (ca *ComponentAccess) client(ctx context.Context) *wechat.Client {
+	return ca.Client.WithContext(ctx).WithAppid(ca.Appid)
+}
+
+// 清零第三方平台的接口调用次数, 每月共 10 次机会
+func (ca *ComponentAccess) ClearQuota() error {
+	return ca.ClearQuotaContext(context.Background())
+}
+
+// ClearQuotaContext 同 ClearQuota, 请求受 ctx 控制
+func (ca *ComponentAccess) ClearQuotaContext(ctx context.Context) error {
+	return ca.Call(ctx, func(c *wechat.Client, token string) error {
+		return wechat.ClearComponentQuotaWith(c, ca.Appid, token)
+	})
 }
 
 type AppAccess struct {
+	// 发送请求的客户端, 为 nil 时使用 wechat.DefaultClient
+	Client          *wechat.Client
 	Appid           string
 	ComponentAccess *ComponentAccess
 
@@ -66,12 +109,46 @@ type AppAccess struct {
 	UserAccessToken *ExpireDataContainer
 }
 
+// 获得绑定了 ctx 及 appid 的客户端
+func (a *AppAccess) client(ctx context.Context) *wechat.Client {
+	return a.Client.WithContext(ctx).WithAppid(a.Appid)
+}
+
+// 清零公众号的接口调用次数, 每月共 10 次机会
+func (a *AppAccess) ClearQuota() error {
+	return a.ClearQuotaContext(context.Background())
+}
+
+// ClearQuotaContext 同 ClearQuota, 请求受 ctx 控制
+func (a *AppAccess) ClearQuotaContext(ctx context.Context) error {
+	return a.Call(ctx, func(c *wechat.Client, token string) error {
+		return wechat.ClearQuotaWith(c, a.Appid, token)
+	})
+}
+
+// Call 使用公众号 access token 调用 fn, 如果 fn 返回令牌失效错误(40001, 42001, 40014),
+// 则获得新的令牌并重试一次. 如:
+//
+//	err := appAccess.Call(ctx, func(c *wechat.Client, token string) error {
+//		return material.DelMaterialWith(c, mediaID, token)
+//	})
+func (a *AppAccess) Call(ctx context.Context, fn func(c *wechat.Client, token string) error) error {
+	return callWithToken(ctx, a.AppAccessToken, a.Appid, func(token string) error {
+		return fn(a.client(ctx), token)
+	})
+}
+
 // 获取授权方的帐号的详细信息.
 // 可通过 ComponentAuthNotify 监听授权方最新的授权权限动态. 由于授权方所授权的权限并不一定就是第三
 // 方平台所设置的权限, 授权方可选择部分权限, 因此有必要根据授权事件更新授权方信息.
 func (a *AppAccess) GetAuthorizer() (info *open_platform.Authorizer, err error) {
+	return a.GetAuthorizerContext(context.Background())
+}
+
+// GetAuthorizerContext 同 GetAuthorizer, 请求受 ctx 控制
+func (a *AppAccess) GetAuthorizerContext(ctx context.Context) (info *open_platform.Authorizer, err error) {
 	if a.ComponentAccess != nil {
-		return a.ComponentAccess.GetAuthorizer(a.Appid)
+		return a.ComponentAccess.GetAuthorizerContext(ctx, a.Appid)
 	} else {
 		return nil, ErrComponentAccessIsNotSet
 	}
@@ -80,25 +157,38 @@ func (a *AppAccess) GetAuthorizer() (info *open_platform.Authorizer, err error)
 // 获得用户信息，需要用户授权(scope 必须是 snsapi_userinfo).
 // 使用之前需要先保存用户授权令牌
 func (a *AppAccess) GetUser(openid string) (info *oauth2.User, err error) {
-	token, err := a.UserAccessToken.Get(openid)
+	return a.GetUserContext(context.Background(), openid)
+}
+
+// GetUserContext 同 GetUser, 请求受 ctx 控制
+func (a *AppAccess) GetUserContext(ctx context.Context, openid string) (info *oauth2.User, err error) {
+	err = callWithToken(ctx, a.UserAccessToken, openid, func(token string) error {
+		if token == "" {
+			return ErrUserNotAuthorized
+		}
+		info, err = oauth2.GetUserInfoWith(a.client(ctx), openid, token)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	if token == "" {
-		return nil, ErrUserNotAuthorized
-	}
-	return oauth2.GetUserInfo(openid, token)
+	return info, nil
 }
 
 // 获得用户信息，需要用户关注.
 // 可通过监听用户关注事件, 然后再调用该方法获得用户信息.
 func (a *AppAccess) GetSubscribedUsers(openids []string) (users []*oauth2.User, err error) {
+	return a.GetSubscribedUsersContext(context.Background(), openids)
+}
+
+// GetSubscribedUsersContext 同 GetSubscribedUsers, 请求受 ctx 控制
+func (a *AppAccess) GetSubscribedUsersContext(ctx context.Context, openids []string) (users []*oauth2.User, err error) {
 	err = splitStrs(openids, 100, func(subs []string) error {
-		token, err := a.AppAccessToken.Get(a.Appid)
-		if err != nil {
+		var us []*oauth2.User
+		err := a.Call(ctx, func(c *wechat.Client, token string) (err error) {
+			us, err = oauth2.GetSubscribersInfoWith(c, subs, token)
 			return err
-		}
-		us, err := oauth2.GetSubscribersInfo(subs, token)
+		})
 		if err != nil {
 			return err
 		} else {
@@ -116,16 +206,150 @@ func (a *AppAccess) GetSubscribedUsers(openids []string) (users []*oauth2.User,
 // 生成公众号菜单.
 // 开放平台可通过监听授权方最新的授权权限动态, 为相应的公众号生成菜单
 func (a *AppAccess) GenerateMenus(menus *wechat.Menus) (err error) {
-	token, err := a.AppAccessToken.Get(a.Appid)
+	return a.GenerateMenusContext(context.Background(), menus)
+}
+
+// GenerateMenusContext 同 GenerateMenus, 请求受 ctx 控制
+func (a *AppAccess) GenerateMenusContext(ctx context.Context, menus *wechat.Menus) (err error) {
+	return a.Call(ctx, func(c *wechat.Client, token string) error {
+		return wechat.GenerateMenusWith(c, token, menus)
+	})
+}
+
+// 查询通过接口设置的默认菜单及个性化菜单
+func (a *AppAccess) GetMenus() (config *wechat.MenuConfig, err error) {
+	return a.GetMenusContext(context.Background())
+}
+
+// GetMenusContext 同 GetMenus, 请求受 ctx 控制
+func (a *AppAccess) GetMenusContext(ctx context.Context) (config *wechat.MenuConfig, err error) {
+	err = a.Call(ctx, func(c *wechat.Client, token string) error {
+		config, err = wechat.GetMenusWith(c, token)
+		return err
+	})
 	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 查询公众号当前使用的菜单, 包括在公众平台官网设置的菜单
+func (a *AppAccess) GetCurrentSelfMenuInfo() (info *wechat.SelfMenuInfo, err error) {
+	return a.GetCurrentSelfMenuInfoContext(context.Background())
+}
+
+// GetCurrentSelfMenuInfoContext 同 GetCurrentSelfMenuInfo, 请求受 ctx 控制
+func (a *AppAccess) GetCurrentSelfMenuInfoContext(ctx context.Context) (info *wechat.SelfMenuInfo, err error) {
+	err = a.Call(ctx, func(c *wechat.Client, token string) error {
+		info, err = wechat.GetCurrentSelfMenuInfoWith(c, token)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return wechat.GenerateMenus(token, menus)
+	return info, nil
+}
+
+// 删除默认菜单及全部个性化菜单
+func (a *AppAccess) DeleteMenus() error {
+	return a.DeleteMenusContext(context.Background())
+}
+
+// DeleteMenusContext 同 DeleteMenus, 请求受 ctx 控制
+func (a *AppAccess) DeleteMenusContext(ctx context.Context) error {
+	return a.Call(ctx, func(c *wechat.Client, token string) error {
+		return wechat.DeleteMenusWith(c, token)
+	})
+}
+
+// 创建个性化菜单
+func (a *AppAccess) AddConditionalMenus(menus *wechat.Menus) (menuID string, err error) {
+	return a.AddConditionalMenusContext(context.Background(), menus)
+}
+
+// AddConditionalMenusContext 同 AddConditionalMenus, 请求受 ctx 控制
+func (a *AppAccess) AddConditionalMenusContext(ctx context.Context, menus *wechat.Menus) (menuID string, err error) {
+	err = a.Call(ctx, func(c *wechat.Client, token string) error {
+		menuID, err = wechat.AddConditionalMenusWith(c, token, menus)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return menuID, nil
+}
+
+// 删除个性化菜单
+func (a *AppAccess) DeleteConditionalMenus(menuID string) error {
+	return a.DeleteConditionalMenusContext(context.Background(), menuID)
+}
+
+// DeleteConditionalMenusContext 同 DeleteConditionalMenus, 请求受 ctx 控制
+func (a *AppAccess) DeleteConditionalMenusContext(ctx context.Context, menuID string) error {
+	return a.Call(ctx, func(c *wechat.Client, token string) error {
+		return wechat.DeleteConditionalMenusWith(c, token, menuID)
+	})
+}
+
+// 测试个性化菜单匹配结果, userID 可以是粉丝的 OpenID, 也可以是粉丝的微信号
+func (a *AppAccess) TryMatchMenus(userID string) (menus *wechat.Menus, err error) {
+	return a.TryMatchMenusContext(context.Background(), userID)
+}
+
+// TryMatchMenusContext 同 TryMatchMenus, 请求受 ctx 控制
+func (a *AppAccess) TryMatchMenusContext(ctx context.Context, userID string) (menus *wechat.Menus, err error) {
+	err = a.Call(ctx, func(c *wechat.Client, token string) error {
+		menus, err = wechat.TryMatchMenusWith(c, token, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return menus, nil
+}
+
+// 同步默认菜单, 详见 SyncMenusContext
+func (a *AppAccess) SyncMenus(desired *wechat.Menus, dryRun bool) (diff string, err error) {
+	return a.SyncMenusContext(context.Background(), desired, dryRun)
+}
+
+// SyncMenusContext 校验目标菜单 desired, 查询当前通过接口设置的默认菜单并与之比较, 返回 wechat.DiffMenus 格式的差异.
+// 只有存在差异且 dryRun 为 false 时才会调用 GenerateMenus 更新菜单, 菜单相同时返回空字符串.
+// 只比较默认菜单, 个性化菜单不受影响
+func (a *AppAccess) SyncMenusContext(ctx context.Context, desired *wechat.Menus, dryRun bool) (diff string, err error) {
+	err = desired.Validate()
+	if err != nil {
+		return "", err
+	}
+	config, err := a.GetMenusContext(ctx)
+	var current *wechat.Menus
+	if err != nil {
+		// 未创建菜单时当前菜单为空
+		if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeMenuNotExist {
+			return "", err
+		}
+	} else {
+		current = config.Menu
+	}
+	diff = wechat.DiffMenus(current, desired)
+	if diff == "" || dryRun {
+		return diff, nil
+	}
+	err = a.GenerateMenusContext(ctx, desired)
+	if err != nil {
+		return diff, err
+	}
+	return diff, nil
 }
 
 // 获得 js 接口签名. 一个 refererUrl 只需要一次签名
 func (a *AppAccess) GetJsApiSignature(nonce, refererUrl string, timestamp int64) (signature string, err error) {
-	ticket, err := a.AppTicket.Get(a.Appid)
+	return a.GetJsApiSignatureContext(context.Background(), nonce, refererUrl, timestamp)
+}
+
+// GetJsApiSignatureContext 同 GetJsApiSignature, 请求受 ctx 控制
+func (a *AppAccess) GetJsApiSignatureContext(ctx context.Context, nonce, refererUrl string, timestamp int64) (signature string, err error) {
+	ticket, err := a.AppTicket.GetContext(ctx, a.Appid)
 	if err != nil {
 		return "", err
 	}
@@ -133,13 +357,14 @@ func (a *AppAccess) GetJsApiSignature(nonce, refererUrl string, timestamp int64)
 }
 
 func NewComponentAccess(storage *Storage, appid string, secretProvider AppSecretProvider) (access *ComponentAccess) {
+	access = &ComponentAccess{Appid: appid}
 	// 在第三方平台创建审核通过后，微信服务器会向其“授权事件接收URL”每隔10分钟定时推送component_verify_ticket
 	verifyTicket := NewDataContainer(storage.VerifyTicket)
 
 	// 第三方平台component_access_token是第三方平台的下文中接口的调用凭据，也叫做令牌（component_access_token）。
 	// 每个令牌是存在有效期（2小时）的，且令牌的调用不是无限制的，请第三方平台做好令牌的管理，在令牌快过期时（比如1小
 	// 时50分）再进行刷新。
-	accessToken := NewExpireDataContainer("componentAccessToken", storage.ComponentAccessToken, 20*time.Minute, func(componentAppid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+	accessToken := NewExpireDataContainerContext("componentAccessToken", storage.ComponentAccessToken, 20*time.Minute, func(ctx context.Context, componentAppid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
 		ticket, err := verifyTicket.Get(componentAppid)
 		if err != nil {
 			return "", "", 0, err
@@ -148,7 +373,7 @@ func NewComponentAccess(storage *Storage, appid string, secretProvider AppSecret
 		if err != nil {
 			return "", "", 0, err
 		}
-		token, err := open_platform.GetComponentAccessToken(componentAppid, secret, ticket)
+		token, err := open_platform.GetComponentAccessTokenWith(access.client(ctx), componentAppid, secret, ticket)
 		if err != nil {
 			return "", "", 0, err
 		} else {
@@ -157,28 +382,29 @@ func NewComponentAccess(storage *Storage, appid string, secretProvider AppSecret
 	})
 
 	// 预授权码。预授权码用于公众号或小程序授权时的第三方平台方安全验证。
-	preAuthCode := NewExpireDataContainer("preAuthCode", storage.PureAuthCode, 20*time.Minute, func(componentAppid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
-		token, err := accessToken.Get(componentAppid)
+	preAuthCode := NewExpireDataContainerContext("preAuthCode", storage.PureAuthCode, 20*time.Minute, func(ctx context.Context, componentAppid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		var code *open_platform.PreAuthCode
+		err = callWithToken(ctx, accessToken, componentAppid, func(token string) (err error) {
+			code, err = open_platform.GetPureAuthCodeWith(access.client(ctx), componentAppid, token)
+			return err
+		})
 		if err != nil {
 			return "", "", 0, err
 		} else {
-			code, err := open_platform.GetPureAuthCode(componentAppid, token)
-			if err != nil {
-				return "", "", 0, err
-			} else {
-				return code.PreAuthCode, "", code.ExpiresIn, nil
-			}
+			return code.PreAuthCode, "", code.ExpiresIn, nil
 		}
 	})
-	return &ComponentAccess{
-		Appid:        appid,
-		VerifyTicket: verifyTicket,
-		AccessToken:  accessToken,
-		PreAuthCode:  preAuthCode,
-	}
+	access.VerifyTicket = verifyTicket
+	access.AccessToken = accessToken
+	access.PreAuthCode = preAuthCode
+	return access
 }
 
 func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvider, componentAccess *ComponentAccess) *AppAccess {
+	a := &AppAccess{
+		Appid:           appid,
+		ComponentAccess: componentAccess,
+	}
 
 	isAuthorizedPlatform := componentAccess != nil
 	// 公众号授权方操作令牌
@@ -187,20 +413,24 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 	if isAuthorizedPlatform {
 		componentAppid = componentAccess.Appid
 		// 开放平台
-		appAccessToken = NewExpireDataContainer("openPlatformAppAccessToken", storage.AppAccessToken, 20*time.Minute, func(appid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
-			cAccessToken, err := componentAccess.AccessToken.Get(componentAppid)
-			if err != nil {
-				return "", "", 0, err
-			}
+		appAccessToken = NewExpireDataContainerContext("openPlatformAppAccessToken", storage.AppAccessToken, 20*time.Minute, func(ctx context.Context, appid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
 			if refreshToken == "" {
-				at, err := open_platform.GetAuthorizerInfo(componentAppid, appid, cAccessToken)
+				var info *open_platform.Authorizer
+				err = callWithToken(ctx, componentAccess.AccessToken, componentAppid, func(cAccessToken string) (err error) {
+					info, err = open_platform.GetAuthorizerInfoWith(a.client(ctx), componentAppid, appid, cAccessToken)
+					return err
+				})
 				if err != nil {
 					return "", "", 0, errors2.Wrap(err, componentAppid+":"+appid+" get refresh token")
 				} else {
-					refreshToken = at.AuthorizationInfo.AuthorizerRefreshToken
+					refreshToken = info.AuthorizationInfo.AuthorizerRefreshToken
 				}
 			}
-			at, err := open_platform.RefreshAuthorizerToken(componentAppid, appid, refreshToken, cAccessToken)
+			var at *open_platform.AuthorizerToken
+			err = callWithToken(ctx, componentAccess.AccessToken, componentAppid, func(cAccessToken string) (err error) {
+				at, err = open_platform.RefreshAuthorizerTokenWith(a.client(ctx), componentAppid, appid, refreshToken, cAccessToken)
+				return err
+			})
 			if err != nil {
 				return "", "", 0, err
 			} else {
@@ -209,12 +439,12 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 		})
 	} else {
 		// 公众平台
-		appAccessToken = NewExpireDataContainer("publicPlatformAppAccessToken", storage.AppAccessToken, 20*time.Minute, func(key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		appAccessToken = NewExpireDataContainerContext("publicPlatformAppAccessToken", storage.AppAccessToken, 20*time.Minute, func(ctx context.Context, key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
 			secret, err := secretProvider(appid)
 			if err != nil {
 				return "", "", 0, err
 			}
-			token, err := access.GetAccessToken(appid, secret)
+			token, err := access.GetAccessTokenWith(a.client(ctx), appid, secret)
 			if err != nil {
 				return "", "", 0, err
 			} else {
@@ -224,17 +454,16 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 	}
 
 	// app ticket
-	appTicket := NewExpireDataContainer("appTicket", storage.AppTicket, 20*time.Minute, func(appid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
-		token, err := appAccessToken.Get(appid)
+	appTicket := NewExpireDataContainerContext("appTicket", storage.AppTicket, 20*time.Minute, func(ctx context.Context, appid, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		var ticket *access.Ticket
+		err = callWithToken(ctx, appAccessToken, appid, func(token string) (err error) {
+			ticket, err = access.GetTicketWith(a.client(ctx), token)
+			return err
+		})
 		if err != nil {
 			return "", "", 0, err
 		} else {
-			ticket, err := access.GetTicket(token)
-			if err != nil {
-				return "", "", 0, err
-			} else {
-				return ticket.Value, "", ticket.ExpiresIn, nil
-			}
+			return ticket.Value, "", ticket.ExpiresIn, nil
 		}
 	})
 
@@ -242,15 +471,15 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 	var userAccessToken *ExpireDataContainer
 	if isAuthorizedPlatform {
 		// 开放平台
-		userAccessToken = NewExpireDataContainer("openPlatformUserAccessToken", storage.UserAccessToken, 20*time.Minute, func(key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		userAccessToken = NewExpireDataContainerContext("openPlatformUserAccessToken", storage.UserAccessToken, 20*time.Minute, func(ctx context.Context, key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
 			if refreshToken == "" {
 				return "", "", 0, ErrUserNotAuthorized
 			}
-			cAccessToken, err := componentAccess.AccessToken.Get(componentAppid)
-			if err != nil {
-				return "", "", 0, err
-			}
-			token, err := oauth2.RefreshComponentAccessToken(appid, refreshToken, componentAppid, cAccessToken)
+			var token *oauth2.AccessToken
+			err = callWithToken(ctx, componentAccess.AccessToken, componentAppid, func(cAccessToken string) (err error) {
+				token, err = oauth2.RefreshComponentAccessTokenWith(a.client(ctx), appid, refreshToken, componentAppid, cAccessToken)
+				return err
+			})
 			if err != nil {
 				return "", "", 0, err
 			} else {
@@ -259,11 +488,11 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 		})
 	} else {
 		// 公众平台
-		userAccessToken = NewExpireDataContainer("publicPlatformUserAccessToken", storage.UserAccessToken, 20*time.Minute, func(key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		userAccessToken = NewExpireDataContainerContext("publicPlatformUserAccessToken", storage.UserAccessToken, 20*time.Minute, func(ctx context.Context, key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
 			if refreshToken == "" {
 				return "", "", 0, ErrUserNotAuthorized
 			}
-			token, err := oauth2.RefreshAccessToken(appid, refreshToken)
+			token, err := oauth2.RefreshAccessTokenWith(a.client(ctx), appid, refreshToken)
 			if err != nil {
 				return "", "", 0, err
 			} else {
@@ -271,13 +500,10 @@ func NewAppAccess(storage *Storage, appid string, secretProvider AppSecretProvid
 			}
 		})
 	}
-	return &AppAccess{
-		Appid:           appid,
-		ComponentAccess: componentAccess,
-		AppAccessToken:  appAccessToken,
-		AppTicket:       appTicket,
-		UserAccessToken: userAccessToken,
-	}
+	a.AppAccessToken = appAccessToken
+	a.AppTicket = appTicket
+	a.UserAccessToken = userAccessToken
+	return a
 }
 
 // 提供 app 配置
@@ -286,6 +512,8 @@ type AppAesKeyProvider func(appid string) (token, aesKey string, err error)
 type ComponentAppidProvider func(appid string) (componentAppid string, err error)
 
 type AccessContainer struct {
+	// 发送请求的客户端, 为 nil 时使用 wechat.DefaultClient
+	Client                 *wechat.Client
 	storage                *Storage
 	AppSecretProvider      AppSecretProvider
 	AppAesKeyProvider      AppAesKeyProvider
@@ -304,6 +532,7 @@ func (ac *AccessContainer) GetComponentAccess(appid string) (a *ComponentAccess,
 		ac.mu.Lock()
 		defer ac.mu.Unlock()
 		a = NewComponentAccess(ac.storage, appid, ac.AppSecretProvider)
+		a.Client = ac.Client
 		ac.componentAccess[appid] = a
 	}
 	return a, nil
@@ -329,6 +558,7 @@ func (ac *AccessContainer) GetAppAccess(componentAppid, appid string) (a *AppAcc
 		ac.mu.Lock()
 		defer ac.mu.Unlock()
 		a = NewAppAccess(ac.storage, appid, ac.AppSecretProvider, componentAccess)
+		a.Client = ac.Client
 		ac.appAccess[appid] = a
 	}
 	return a, nil
@@ -347,21 +577,76 @@ func (ac *AccessContainer) GetComponentAppid(appid string) (componentAppid strin
 }
 
 func (ac *AccessContainer) GetAppAccessToken(appid string) (token string, err error) {
+	return ac.GetAppAccessTokenContext(context.Background(), appid)
+}
+
+// GetAppAccessTokenContext 同 GetAppAccessToken, 请求受 ctx 控制
+func (ac *AccessContainer) GetAppAccessTokenContext(ctx context.Context, appid string) (token string, err error) {
 	appAccess, err := ac.GetAppAccess("", appid)
 	if err != nil {
 		return "", err
 	} else {
-		return appAccess.AppAccessToken.Get(appid)
+		return appAccess.AppAccessToken.GetContext(ctx, appid)
 	}
 }
 
 func (ac *AccessContainer) GetAppTicket(appid string) (ticket string, err error) {
+	return ac.GetAppTicketContext(context.Background(), appid)
+}
+
+// GetAppTicketContext 同 GetAppTicket, 请求受 ctx 控制
+func (ac *AccessContainer) GetAppTicketContext(ctx context.Context, appid string) (ticket string, err error) {
 	appAccess, err := ac.GetAppAccess("", appid)
 	if err != nil {
 		return "", err
 	} else {
-		return appAccess.AppTicket.Get(appid)
+		return appAccess.AppTicket.GetContext(ctx, appid)
+	}
+}
+
+// Call 获得 appid 对应的 AppAccess 并调用其 Call 方法, 令牌失效时将自动刷新并重试一次
+func (ac *AccessContainer) Call(ctx context.Context, appid string, fn func(c *wechat.Client, token string) error) error {
+	appAccess, err := ac.GetAppAccess("", appid)
+	if err != nil {
+		return err
 	}
+	return appAccess.Call(ctx, fn)
+}
+
+// MenuSyncResult 是一个公众号的菜单同步结果
+type MenuSyncResult struct {
+	Appid string
+
+	// 当前菜单与目标菜单的差异, 为空表示菜单相同
+	Diff string
+
+	// 是否已更新菜单
+	Applied bool
+
+	Err error
+}
+
+// SyncMenus 依次将 appids 对应公众号的默认菜单同步为 desired, 某个公众号出错时继续处理其他公众号, 错误记录在结果中.
+// dryRun 为 true 时只比较菜单, 不做修改, 详见 AppAccess.SyncMenus
+func (ac *AccessContainer) SyncMenus(appids []string, desired *wechat.Menus, dryRun bool) []*MenuSyncResult {
+	return ac.SyncMenusContext(context.Background(), appids, desired, dryRun)
+}
+
+// SyncMenusContext 同 SyncMenus, 请求受 ctx 控制
+func (ac *AccessContainer) SyncMenusContext(ctx context.Context, appids []string, desired *wechat.Menus, dryRun bool) []*MenuSyncResult {
+	results := make([]*MenuSyncResult, len(appids))
+	for i, appid := range appids {
+		res := &MenuSyncResult{Appid: appid}
+		results[i] = res
+		appAccess, err := ac.GetAppAccess("", appid)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		res.Diff, res.Err = appAccess.SyncMenusContext(ctx, desired, dryRun)
+		res.Applied = res.Err == nil && res.Diff != "" && !dryRun
+	}
+	return results
 }
 
 // decrypter 有可能为空, 表示消息传输格式为明文传输.
@@ -439,3 +724,21 @@ func splitStrs(strs []string, limit int, walk func(subs []string) error) error {
 	}
 	return nil
 }
+
+// 从 container 中获得令牌并调用 fn, 如果 fn 返回令牌失效错误, 则获得新的令牌并重试一次.
+// 并发调用同时失效时, 只有第一个调用者刷新令牌, 其他调用者使用刷新后的令牌
+func callWithToken(ctx context.Context, container *ExpireDataContainer, key string, fn func(token string) error) error {
+	token, err := container.GetContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = fn(token)
+	if wechat.IsTokenInvalid(err) {
+		token, err = container.renew(ctx, key, token)
+		if err != nil {
+			return err
+		}
+		err = fn(token)
+	}
+	return err
+}
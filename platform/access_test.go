@@ -0,0 +1,291 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package platform_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/platform"
+	"github.com/orivil/wechat/users"
+	"github.com/orivil/wechat/wechattest"
+)
+
+type memoryStorage struct {
+	data   map[string]string
+	expire map[string]*platform.ExpireData
+	mu     sync.Mutex
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: make(map[string]string), expire: make(map[string]*platform.ExpireData)}
+}
+
+func (m *memoryStorage) Store(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *memoryStorage) Read(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memoryStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	delete(m.expire, key)
+	return nil
+}
+
+type memoryExpireStorage struct {
+	*memoryStorage
+}
+
+func (m memoryExpireStorage) Store(key string, data *platform.ExpireData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire[key] = data
+	return nil
+}
+
+func (m memoryExpireStorage) Read(key string) (*platform.ExpireData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expire[key], nil
+}
+
+// 返回通过 client 请求的 AccessContainer, secrets 为 appid 对应的 secret
+func newTestAccess(client *wechat.Client, secrets map[string]string) *platform.AccessContainer {
+	ms := newMemoryStorage()
+	access := platform.NewAccessContainer(
+		platform.NewStorage(ms, memoryExpireStorage{ms}),
+		func(appid string) (string, error) { return secrets[appid], nil },
+		func(string) (string, string, error) { return "", "", nil },
+		func(string) (string, error) { return "", nil },
+	)
+	access.Client = client
+	return access
+}
+
+func TestCallRefreshToken(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	access := newTestAccess(srv.Client(), map[string]string{"wx123": "secret"})
+	getTags := func(c *wechat.Client, token string) error {
+		_, err := users.GetTagsWith(c, token)
+		return err
+	}
+	err := access.Call(context.Background(), "wx123", getTags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 令牌失效时刷新一次并重试
+	for _, code := range []int{wechat.ErrCodeInvalidAppSecret, wechat.ErrCodeAccessTokenExpired, wechat.ErrCodeInvalidAccessToken} {
+		srv.ResetRequests()
+		srv.InjectError("/cgi-bin/tags/get", code, 1)
+		err = access.Call(context.Background(), "wx123", getTags)
+		if err != nil {
+			t.Fatalf("%d: %v", code, err)
+		}
+		if n := len(srv.RequestsTo("/cgi-bin/token")); n != 1 {
+			t.Errorf("%d: got %d token requests, want 1", code, n)
+		}
+		if n := len(srv.RequestsTo("/cgi-bin/tags/get")); n != 2 {
+			t.Errorf("%d: got %d requests, want 2", code, n)
+		}
+	}
+
+	// 刷新后仍然失效时返回错误, 不再重试
+	srv.ResetRequests()
+	srv.InjectError("/cgi-bin/tags/get", wechat.ErrCodeInvalidAccessToken, 0)
+	err = access.Call(context.Background(), "wx123", getTags)
+	if !wechat.IsTokenInvalid(err) {
+		t.Errorf("got %v, want invalid access token", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/token")); n != 1 {
+		t.Errorf("got %d token requests, want 1", n)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/tags/get")); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestCallRefreshTokenOnce(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	access := newTestAccess(srv.Client(), map[string]string{"wx123": "secret"})
+	appAccess, err := access.GetAppAccess("", "wx123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = appAccess.ClearQuota()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发调用同时失效时只刷新一次令牌
+	srv.ExpireTokens("wx123")
+	srv.ResetRequests()
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = appAccess.ClearQuota()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/token")); n != 1 {
+		t.Errorf("got %d token requests, want 1", n)
+	}
+}
+
+func TestComponentCallRefreshToken(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddComponent("wxcomponent", "secret").VerifyTicket = "ticket"
+	access := newTestAccess(srv.Client(), map[string]string{"wxcomponent": "secret"})
+	component, err := access.GetComponentAccess("wxcomponent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = platform.SetData(component.VerifyTicket, "wxcomponent", "ticket")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.InjectError("/cgi-bin/component/clear_quota", wechat.ErrCodeAccessTokenExpired, 1)
+	err = component.ClearQuota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/component/api_component_token")); n != 2 {
+		t.Errorf("got %d token requests, want 2", n)
+	}
+
+	srv.ResetRequests()
+	srv.InjectError("/cgi-bin/component/clear_quota", wechat.ErrCodeAccessTokenExpired, 0)
+	err = component.ClearQuota()
+	if !wechat.IsTokenInvalid(err) {
+		t.Errorf("got %v, want access token expired", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/component/clear_quota")); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestAppAccessClearQuota(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	client := srv.Client()
+	client.Limiter = wechat.NewLimiter(0, 0)
+	access := newTestAccess(client, map[string]string{"wx123": "secret"})
+	appAccess, err := access.GetAppAccess("", "wx123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.InjectError("/cgi-bin/menu/get", wechat.ErrCodeAPIDailyQuota, 1)
+	_, err = appAccess.GetMenus()
+	if !wechat.IsQuotaExceeded(err) {
+		t.Fatalf("got %v, want quota exceeded", err)
+	}
+	// 配额重置之前不再发送请求
+	srv.ResetRequests()
+	_, err = appAccess.GetMenus()
+	if _, ok := err.(*wechat.QuotaError); !ok {
+		t.Fatalf("got %v, want *QuotaError", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/get")); n != 0 {
+		t.Fatalf("got %d requests, want 0", n)
+	}
+
+	err = appAccess.ClearQuota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.App("wx123").QuotaCleared; n != 1 {
+		t.Errorf("quota cleared %d times, want 1", n)
+	}
+	_, err = appAccess.GetMenus()
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeMenuNotExist {
+		t.Errorf("after ClearQuota: got %v, want menu not exist", err)
+	}
+}
+
+func TestSyncMenus(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx1", "secret1")
+	srv.AddApp("wx2", "secret2")
+	access := newTestAccess(srv.Client(), map[string]string{"wx1": "secret1", "wx2": "secret2"})
+	desired := &wechat.Menus{Buttons: []*wechat.MenuButton{
+		{Type: wechat.MenuButtonClick, Name: "歌曲", Key: "V1001"},
+	}}
+
+	// dry run 只比较不修改
+	results := access.SyncMenus([]string{"wx1", "wx2", "unknown"}, desired, true)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, res := range results[:2] {
+		if res.Err != nil || res.Diff == "" || res.Applied {
+			t.Errorf("%s: got %+v, want diff without applying", res.Appid, res)
+		}
+	}
+	if results[2].Err == nil {
+		t.Error("unknown app should fail")
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/create")); n != 0 {
+		t.Fatalf("dry run created %d menus", n)
+	}
+
+	results = access.SyncMenus([]string{"wx1"}, desired, false)
+	if res := results[0]; res.Err != nil || !res.Applied {
+		t.Fatalf("got %+v, want applied", res)
+	}
+	if srv.App("wx1").Menu == nil {
+		t.Fatal("menu not created")
+	}
+
+	// 菜单相同时不再更新
+	results = access.SyncMenus([]string{"wx1"}, desired, false)
+	if res := results[0]; res.Err != nil || res.Diff != "" || res.Applied {
+		t.Errorf("got %+v, want no change", res)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/menu/create")); n != 1 {
+		t.Errorf("got %d create requests, want 1", n)
+	}
+
+	// 无效的菜单在请求之前返回错误
+	appAccess, _ := access.GetAppAccess("", "wx2")
+	_, err := appAccess.SyncMenus(&wechat.Menus{}, false)
+	if _, ok := err.(wechat.MenuErrors); !ok {
+		t.Errorf("got %v, want MenuErrors", err)
+	}
+
+	srv.InjectError("/cgi-bin/menu/get", wechat.ErrCodeSystemBusy, 1)
+	_, err = appAccess.SyncMenus(desired, false)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeSystemBusy {
+		t.Errorf("got %v, want system busy", err)
+	}
+}
@@ -5,6 +5,7 @@
 package platform
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"sync"
 	"time"
@@ -41,6 +42,9 @@ func (dc *DataContainer) Get(key string) (value string, err error) {
 // 数据刷新器, refreshToken = (newRefreshToken != "" ? newRefreshToken : value)
 type ExpireDataRefresher func(key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error)
 
+// 支持 context 的数据刷新器, ctx 来自 GetContext 及 RefreshContext 方法
+type ExpireDataRefresherContext func(ctx context.Context, key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error)
+
 // 过期数据模型
 type ExpireData struct {
 	// 数据
@@ -68,7 +72,7 @@ func GetRefreshToken(ed *ExpireData) string {
 // 有时间限制的数据容器
 type ExpireDataContainer struct {
 	name      string
-	refresher ExpireDataRefresher
+	refresher ExpireDataRefresherContext
 	storage   ExpireDataStorage
 	//data      map[string]*ExpireData
 	refreshBeforeExpire time.Duration
@@ -101,15 +105,36 @@ func (e *ExpireDataContainer) Del(key string) error {
 
 // 强制刷新获得新的数据
 func (e *ExpireDataContainer) Refresh(key string) (value string, err error) {
-	return e.get(key, true)
+	return e.get(context.Background(), key, true)
+}
+
+// RefreshContext 同 Refresh, ctx 将传递给数据刷新器
+func (e *ExpireDataContainer) RefreshContext(ctx context.Context, key string) (value string, err error) {
+	return e.get(ctx, key, true)
 }
 
 // Get 用于获得数据, 如果缓存中没有数据则从 storage 中获取数据, 如果未设置数据或者数据过期则调用函数刷新获得新的数据
 func (e *ExpireDataContainer) Get(key string) (value string, err error) {
-	return e.get(key, false)
+	return e.get(context.Background(), key, false)
+}
+
+// GetContext 同 Get, ctx 将传递给数据刷新器
+func (e *ExpireDataContainer) GetContext(ctx context.Context, key string) (value string, err error) {
+	return e.get(ctx, key, false)
+}
+
+func (e *ExpireDataContainer) get(ctx context.Context, key string, refresh bool) (value string, err error) {
+	return e.load(ctx, key, func(data *ExpireData) bool { return refresh })
+}
+
+// 数据 stale 失效后获得新的数据. 如果 storage 中的数据已被其他调用者刷新, 则直接返回新数据,
+// 只有数据仍为 stale 时才调用刷新器, 避免并发调用同时失效时重复刷新
+func (e *ExpireDataContainer) renew(ctx context.Context, key, stale string) (value string, err error) {
+	return e.load(ctx, key, func(data *ExpireData) bool { return data.Value == stale })
 }
 
-func (e *ExpireDataContainer) get(key string, refresh bool) (value string, err error) {
+// 读取数据, 数据不存在, 已过期或 refresh 返回 true 时刷新数据
+func (e *ExpireDataContainer) load(ctx context.Context, key string, refresh func(data *ExpireData) bool) (value string, err error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	var data *ExpireData
@@ -118,8 +143,11 @@ func (e *ExpireDataContainer) get(key string, refresh bool) (value string, err e
 		return "", err
 	}
 	now := ticker.Now()
-	if refresh || data == nil || now.After(data.ExpireAt) {
-		value, refreshToken, expiresIn, err := e.refresher(key, GetRefreshToken(data))
+	if data == nil || now.After(data.ExpireAt) || refresh(data) {
+		if err = ctx.Err(); err != nil {
+			return "", err
+		}
+		value, refreshToken, expiresIn, err := e.refresher(ctx, key, GetRefreshToken(data))
 		if err != nil {
 			return "", errors.Wrapf(err, "expire data container [%s]", e.name)
 		}
@@ -140,6 +168,13 @@ func (e *ExpireDataContainer) get(key string, refresh bool) (value string, err e
 
 // 新建过期数据, storage 用于保存数据以及读取数据
 func NewExpireDataContainer(name string, storage ExpireDataStorage, refreshBeforeExpire time.Duration, refresher ExpireDataRefresher) *ExpireDataContainer {
+	return NewExpireDataContainerContext(name, storage, refreshBeforeExpire, func(ctx context.Context, key, refreshToken string) (value, newRefreshToken string, expiresIn int64, err error) {
+		return refresher(key, refreshToken)
+	})
+}
+
+// 新建过期数据, 数据刷新器可获得 GetContext 及 RefreshContext 方法传入的 ctx
+func NewExpireDataContainerContext(name string, storage ExpireDataStorage, refreshBeforeExpire time.Duration, refresher ExpireDataRefresherContext) *ExpireDataContainer {
 	return &ExpireDataContainer{
 		name:                name,
 		refresher:           refresher,
@@ -0,0 +1,235 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// publish 为发布能力接口, 用于发布 draft 包创建的草稿. 发布是异步的, 发布结果通过 PUBLISHJOBFINISH 事件推送,
+// 也可以通过 Get 或 Wait 查询
+package publish
+
+import (
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/internal/paging"
+	"github.com/orivil/wechat/material"
+)
+
+// 批量获取已发布文章时每次最多获取的数量
+const MaxBatchCount = 20
+
+// Wait 的 interval 小于等于 0 时使用的查询间隔
+const DefaultWaitInterval = 5 * time.Second
+
+// 发布状态
+type Status int
+
+const (
+	StatusSuccess        Status = 0 // 发布成功
+	StatusPublishing     Status = 1 // 发布中
+	StatusOriginalFailed Status = 2 // 原创失败
+	StatusFailed         Status = 3 // 常规失败
+	StatusAuditRejected  Status = 4 // 平台审核不通过
+	StatusUserDeleted    Status = 5 // 发布成功后用户删除了所有文章
+	StatusSystemBanned   Status = 6 // 发布成功后系统封禁了所有文章
+)
+
+// 发布结果, 同时用于 Get 接口及 PUBLISHJOBFINISH 事件推送
+type Result struct {
+	PublishID string `json:"publish_id" xml:"publish_id"`
+
+	Status Status `json:"publish_status" xml:"publish_status"`
+
+	// 发布成功时返回, 用于获取、删除已发布的文章
+	ArticleID string `json:"article_id" xml:"article_id"`
+
+	// 发布成功时返回的文章信息
+	ArticleDetail *ArticleDetail `json:"article_detail" xml:"article_detail"`
+
+	// 原创失败及审核不通过时, 失败的文章位置, 第一篇为 1
+	FailIdx []int `json:"fail_idx" xml:"fail_idx"`
+}
+
+type ArticleDetail struct {
+	Count int `json:"count" xml:"count"`
+
+	Items []*ArticleItem `json:"item" xml:"item"`
+}
+
+type ArticleItem struct {
+	// 文章位置, 第一篇为 1
+	Idx int `json:"idx" xml:"idx"`
+
+	ArticleURL string `json:"article_url" xml:"article_url"`
+}
+
+// 发布成功的文章链接, 按文章位置排列
+func (r *Result) ArticleURLs() []string {
+	if r.ArticleDetail == nil {
+		return nil
+	}
+	urls := make([]string, len(r.ArticleDetail.Items))
+	for i, item := range r.ArticleDetail.Items {
+		urls[i] = item.ArticleURL
+	}
+	return urls
+}
+
+// 提交发布任务, 返回发布任务 id. 提交成功不代表发布成功, 发布结果通过事件推送或 Get 查询
+func Submit(mediaID, token string) (publishID string, err error) {
+	return SubmitWith(wechat.DefaultClient, mediaID, token)
+}
+
+// SubmitWith 同 Submit, 通过客户端 c 发送请求
+func SubmitWith(c *wechat.Client, mediaID, token string) (publishID string, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/freepublish/submit?access_token=" + token
+	res := &struct {
+		PublishID string `json:"publish_id"`
+	}{}
+	err = c.PostSchema(wechat.KindJson, ul, map[string]string{"media_id": mediaID}, res)
+	if err != nil {
+		return "", err
+	} else {
+		return res.PublishID, nil
+	}
+}
+
+// 查询发布状态
+func Get(publishID, token string) (result *Result, err error) {
+	return GetWith(wechat.DefaultClient, publishID, token)
+}
+
+// GetWith 同 Get, 通过客户端 c 发送请求
+func GetWith(c *wechat.Client, publishID, token string) (result *Result, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/freepublish/get?access_token=" + token
+	result = &Result{}
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]string{"publish_id": publishID}, result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// 每隔 interval 查询一次发布状态, 直到发布结束(状态不为 StatusPublishing), 返回最后一次查询的结果.
+// interval 小于等于 0 时使用 DefaultWaitInterval
+func Wait(publishID, token string, interval time.Duration) (result *Result, err error) {
+	return WaitWith(wechat.DefaultClient, publishID, token, interval)
+}
+
+// WaitWith 同 Wait, 通过客户端 c 发送请求, 可通过 c.WithContext 设置超时时间:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+//	defer cancel()
+//	result, err := publish.WaitWith(client.WithContext(ctx), publishID, token, 10*time.Second)
+func WaitWith(c *wechat.Client, publishID, token string, interval time.Duration) (result *Result, err error) {
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+	ctx := c.Context()
+	for {
+		result, err = GetWith(c, publishID, token)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != StatusPublishing {
+			return result, nil
+		}
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// 删除已发布的文章, index 为要删除的文章位置, 第一篇为 1, 为 0 时删除全部文章. 此操作不可逆
+func Delete(articleID string, index int, token string) error {
+	return DeleteWith(wechat.DefaultClient, articleID, index, token)
+}
+
+// DeleteWith 同 Delete, 通过客户端 c 发送请求
+func DeleteWith(c *wechat.Client, articleID string, index int, token string) error {
+	ul := "https://api.weixin.qq.com/cgi-bin/freepublish/delete?access_token=" + token
+	return c.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+		"article_id": articleID,
+		"index":      index,
+	}, nil)
+}
+
+// 获取已发布的文章
+func GetArticle(articleID, token string) (articles []*material.Article, err error) {
+	return GetArticleWith(wechat.DefaultClient, articleID, token)
+}
+
+// GetArticleWith 同 GetArticle, 通过客户端 c 发送请求
+func GetArticleWith(c *wechat.Client, articleID, token string) (articles []*material.Article, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/freepublish/getarticle?access_token=" + token
+	res := &material.NewsArticles{}
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]string{"article_id": articleID}, res)
+	if err != nil {
+		return nil, err
+	} else {
+		return res.NewsItem, nil
+	}
+}
+
+type List struct {
+	TotalCount int     `json:"total_count"`
+	ItemCount  int     `json:"item_count"`
+	Item       []*Item `json:"item"`
+}
+
+type Item struct {
+	ArticleID  string                `json:"article_id"`
+	Content    material.NewsArticles `json:"content"`
+	UpdateTime int64                 `json:"update_time"`
+}
+
+// 获取已发布的文章列表, count 取值 1~20. noContent 为 true 时不返回文章内容(content 字段)
+func BatchGet(offset, count int, noContent bool, token string) (list *List, err error) {
+	return BatchGetWith(wechat.DefaultClient, offset, count, noContent, token)
+}
+
+// BatchGetWith 同 BatchGet, 通过客户端 c 发送请求
+func BatchGetWith(c *wechat.Client, offset, count int, noContent bool, token string) (list *List, err error) {
+	ul := "https://api.weixin.qq.com/cgi-bin/freepublish/batchget?access_token=" + token
+	noContentValue := 0
+	if noContent {
+		noContentValue = 1
+	}
+	list = &List{}
+	err = c.Idempotent().PostSchema(wechat.KindJson, ul, map[string]interface{}{
+		"offset":     offset,
+		"count":      count,
+		"no_content": noContentValue,
+	}, list)
+	if err != nil {
+		return nil, err
+	} else {
+		return list, nil
+	}
+}
+
+// 遍历所有已发布的文章, 每次获取 MaxBatchCount 个
+func Walk(noContent bool, token string, fn func(item *Item) error) error {
+	return WalkWith(wechat.DefaultClient, noContent, token, fn)
+}
+
+// WalkWith 同 Walk, 通过客户端 c 发送请求
+func WalkWith(c *wechat.Client, noContent bool, token string, fn func(item *Item) error) error {
+	return paging.Walk(func(offset int) (int, int, error) {
+		list, err := BatchGetWith(c, offset, MaxBatchCount, noContent, token)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, item := range list.Item {
+			err = fn(item)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(list.Item), list.TotalCount, nil
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package publish_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	"github.com/orivil/wechat/draft"
+	"github.com/orivil/wechat/material"
+	"github.com/orivil/wechat/publish"
+	"github.com/orivil/wechat/wechattest"
+)
+
+// 新建草稿并提交发布, 返回 publish_id
+func submit(t *testing.T, c *wechat.Client, token string) string {
+	t.Helper()
+	mediaID, err := draft.AddWith(c, []*material.Article{{Title: "title"}}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishID, err := publish.SubmitWith(c, mediaID, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publishID
+}
+
+func TestWait(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	c := srv.Client()
+	token, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟服务器第一次查询返回发布中, 之后返回发布成功
+	publishID := submit(t, c, token.Value)
+	result, err := publish.WaitWith(c, publishID, token.Value, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != publish.StatusSuccess || result.ArticleID == "" {
+		t.Errorf("got result %+v", result)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/freepublish/get")); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+
+	// interval 小于等于 0 时使用 DefaultWaitInterval, 不会连续请求
+	srv.ResetRequests()
+	publishID = submit(t, c, token.Value)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = publish.WaitWith(c.WithContext(ctx), publishID, token.Value, 0)
+	if err != context.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/freepublish/get")); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	srv.AddApp("wx123", "secret")
+	c := srv.Client()
+	token, err := access.GetAccessTokenWith(c, "wx123", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 21; i++ {
+		_, err = publish.WaitWith(c, submit(t, c, token.Value), token.Value, time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 未发布完成的任务不在列表中
+	submit(t, c, token.Value)
+
+	var ids []string
+	err = publish.WalkWith(c, true, token.Value, func(item *publish.Item) error {
+		ids = append(ids, item.ArticleID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 21 {
+		t.Errorf("got %d articles, want 21", len(ids))
+	}
+	if n := len(srv.RequestsTo("/cgi-bin/freepublish/batchget")); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
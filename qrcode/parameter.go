@@ -59,7 +59,12 @@ type Result struct {
 }
 
 func Generate(token string, schema *PostSchema) (res *Result, err error) {
-	err = wechat.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/qrcode/create?access_token="+token, schema, &res)
+	return GenerateWith(wechat.DefaultClient, token, schema)
+}
+
+// GenerateWith 同 Generate, 通过客户端 c 发送请求
+func GenerateWith(c *wechat.Client, token string, schema *PostSchema) (res *Result, err error) {
+	err = c.PostSchema(wechat.KindJson, "https://api.weixin.qq.com/cgi-bin/qrcode/create?access_token="+token, schema, &res)
 	return
 }
 
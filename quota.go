@@ -0,0 +1,43 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+// 公众号调用或第三方平台帮公众号调用对公众号的所有 api 调用（包括第三方帮其调用）次数进行清零.
+// 每个帐号每月共 10 次清零操作机会, 清零生效一次即用掉一次机会.
+// 清零成功后将同时清除客户端限流器中该 appid 的配额耗尽记录.
+func ClearQuota(appid, accessToken string) error {
+	return ClearQuotaWith(DefaultClient, appid, accessToken)
+}
+
+// ClearQuotaWith 同 ClearQuota, 通过客户端 c 发送请求
+func ClearQuotaWith(c *Client, appid, accessToken string) error {
+	u := "https://api.weixin.qq.com/cgi-bin/clear_quota?access_token=" + accessToken
+	err := c.PostSchema(KindJson, u, map[string]string{"appid": appid}, nil)
+	if err != nil {
+		return err
+	}
+	if l := getClient(c).Limiter; l != nil {
+		l.Reset(appid)
+	}
+	return nil
+}
+
+// 第三方平台对其所有 api 调用次数清零(只与第三方平台相关, 与公众号无关, 接口如 api_component_token)
+func ClearComponentQuota(componentAppid, componentAccessToken string) error {
+	return ClearComponentQuotaWith(DefaultClient, componentAppid, componentAccessToken)
+}
+
+// ClearComponentQuotaWith 同 ClearComponentQuota, 通过客户端 c 发送请求
+func ClearComponentQuotaWith(c *Client, componentAppid, componentAccessToken string) error {
+	u := "https://api.weixin.qq.com/cgi-bin/component/clear_quota?component_access_token=" + componentAccessToken
+	err := c.PostSchema(KindJson, u, map[string]string{"component_appid": componentAppid}, nil)
+	if err != nil {
+		return err
+	}
+	if l := getClient(c).Limiter; l != nil {
+		l.Reset(componentAppid)
+	}
+	return nil
+}
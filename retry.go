@@ -0,0 +1,151 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy 是请求失败时的重试策略, 重试间隔按指数增长并加入随机抖动.
+type RetryPolicy struct {
+	// 最大尝试次数(包含第一次请求), 小于等于 1 时不重试
+	MaxAttempts int
+
+	// 第一次重试前的等待时间, 之后每次翻倍
+	BaseDelay time.Duration
+
+	// 最长等待时间, 为 0 时不限制
+	MaxDelay time.Duration
+
+	// 判断失败的请求是否可以重试, 为 nil 时使用 ShouldRetry
+	Retryable func(a *Attempt) bool
+}
+
+// 默认重试策略, 最多请求 3 次
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// 不重试的策略, 如 client.Retry = wechat.NoRetry
+var NoRetry = &RetryPolicy{MaxAttempts: 1}
+
+// Attempt 是一次请求尝试的结果
+type Attempt struct {
+	Request *http.Request
+
+	// 接口路径, 如 "/cgi-bin/token"
+	Endpoint string
+
+	// 第几次尝试, 从 1 开始
+	Number int
+
+	// 请求是否可以安全地重复发送. GET 请求, 或通过 Client.Idempotent 发送的请求为 true
+	Idempotent bool
+
+	// 响应状态码, 未获得响应时为 0
+	StatusCode int
+
+	// 响应头, 未获得响应时为 nil
+	Header http.Header
+
+	// 响应数据, 通过 Client.Download 下载的二进制数据不包含在内
+	Data []byte
+
+	// 网络错误
+	Err error
+
+	// 是否已将响应数据写入 Client.Download 的 io.Writer
+	written bool
+}
+
+// 获取令牌的接口. 每次调用都会生成新的令牌并使之前的令牌失效, 或者消耗一次性的授权码,
+// 重试可能使第一次请求已获得的令牌失效, 所以默认不重试
+var tokenEndpoints = map[string]bool{
+	"/cgi-bin/token":                          true,
+	"/cgi-bin/component/api_component_token":  true,
+	"/cgi-bin/component/api_authorizer_token": true,
+	"/sns/oauth2/access_token":                true,
+	"/sns/oauth2/component/access_token":      true,
+}
+
+// ShouldRetry 是默认的重试判断条件: 请求可以安全地重复发送且不是获取令牌的接口, 且发生网络错误,
+// 服务器返回 5xx 状态码或者返回 IsRetryable 的错误码(如 -1 系统繁忙).
+func ShouldRetry(a *Attempt) bool {
+	if !a.Idempotent || tokenEndpoints[a.Endpoint] {
+		return false
+	}
+	if a.Err != nil {
+		return !errors.Is(a.Err, context.Canceled) && !errors.Is(a.Err, context.DeadlineExceeded)
+	}
+	if a.StatusCode >= 500 {
+		return true
+	}
+	return IsRetryable(ParseError(a.Data))
+}
+
+// 计算第 n 次重试前的等待时间
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// 抖动: 在 [d/2, d) 之间随机取值
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// 判断是否需要重试, 需要时等待重试间隔并重置请求数据
+func (p *RetryPolicy) wait(a *Attempt) bool {
+	if p == nil || a.Number >= p.MaxAttempts || a.written {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = ShouldRetry
+	}
+	if !retryable(a) {
+		return false
+	}
+	req := a.Request
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return false
+		}
+		cp := *req
+		cp.Body = body
+		a.Request = &cp
+	}
+	t := time.NewTimer(p.backoff(a.Number))
+	defer t.Stop()
+	select {
+	case <-req.Context().Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Idempotent 返回客户端副本, 通过该副本发送的 POST 请求被视为可以安全地重复发送(如只读接口,
+// 或带有 clientmsgid 的群发接口), 失败时可按重试策略重新发送.
+func (c *Client) Idempotent() *Client {
+	cp := *getClient(c)
+	cp.idempotent = true
+	return &cp
+}
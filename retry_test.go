@@ -0,0 +1,64 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+	cases := []struct {
+		n   int
+		max time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond},
+		{10, 500 * time.Millisecond},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(c.n)
+			if d < c.max/2 || d > c.max {
+				t.Fatalf("backoff(%d) = %s, want in [%s, %s]", c.n, d, c.max/2, c.max)
+			}
+		}
+	}
+	if d := (&RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("zero BaseDelay: got %s, want 0", d)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	busy := []byte(`{"errcode":-1,"errmsg":"system error"}`)
+	cases := []struct {
+		name string
+		a    *Attempt
+		want bool
+	}{
+		{"network error", &Attempt{Idempotent: true, Err: errors.New("reset")}, true},
+		{"not idempotent", &Attempt{Err: errors.New("reset")}, false},
+		{"canceled", &Attempt{Idempotent: true, Err: context.Canceled}, false},
+		{"deadline", &Attempt{Idempotent: true, Err: context.DeadlineExceeded}, false},
+		{"5xx", &Attempt{Idempotent: true, StatusCode: http.StatusBadGateway}, true},
+		{"4xx", &Attempt{Idempotent: true, StatusCode: http.StatusNotFound}, false},
+		{"system busy", &Attempt{Idempotent: true, StatusCode: http.StatusOK, Data: busy}, true},
+		{"other errcode", &Attempt{Idempotent: true, StatusCode: http.StatusOK, Data: []byte(`{"errcode":40001}`)}, false},
+		{"ok", &Attempt{Idempotent: true, StatusCode: http.StatusOK, Data: []byte(`{"errcode":0}`)}, false},
+		{"access token", &Attempt{Idempotent: true, Endpoint: "/cgi-bin/token", Err: errors.New("reset")}, false},
+		{"component token", &Attempt{Idempotent: true, Endpoint: "/cgi-bin/component/api_component_token", StatusCode: 500}, false},
+	}
+	for _, c := range cases {
+		if got := ShouldRetry(c.a); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
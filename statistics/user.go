@@ -31,13 +31,18 @@ type cumulateList struct {
 
 // 获取用户增减数据
 func GetUserSummary(token string, beginDate, endDate time.Time) ([]*Summary, error) {
+	return GetUserSummaryWith(wechat.DefaultClient, token, beginDate, endDate)
+}
+
+// GetUserSummaryWith 同 GetUserSummary, 通过客户端 c 发送请求
+func GetUserSummaryWith(c *wechat.Client, token string, beginDate, endDate time.Time) ([]*Summary, error) {
 	URL := "https://api.weixin.qq.com/datacube/getusersummary?access_token=" + token
 	data := map[string]string{
 		"begin_date": beginDate.Format("2006-01-02"),
 		"end_date":   endDate.Format("2006-01-02"),
 	}
 	res := &summaryList{}
-	err := wechat.PostSchema(wechat.KindJson, URL, data, res)
+	err := c.Idempotent().PostSchema(wechat.KindJson, URL, data, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -47,13 +52,18 @@ func GetUserSummary(token string, beginDate, endDate time.Time) ([]*Summary, err
 
 // 获取累计用户数据
 func GetUserCumulate(token string, beginDate, endDate time.Time) ([]*Cumulate, error) {
+	return GetUserCumulateWith(wechat.DefaultClient, token, beginDate, endDate)
+}
+
+// GetUserCumulateWith 同 GetUserCumulate, 通过客户端 c 发送请求
+func GetUserCumulateWith(c *wechat.Client, token string, beginDate, endDate time.Time) ([]*Cumulate, error) {
 	URL := "https://api.weixin.qq.com/datacube/getusercumulate?access_token=" + token
 	data := map[string]string{
 		"begin_date": beginDate.Format("2006-01-02"),
 		"end_date":   endDate.Format("2006-01-02"),
 	}
 	res := &cumulateList{}
-	err := wechat.PostSchema(wechat.KindJson, URL, data, res)
+	err := c.Idempotent().PostSchema(wechat.KindJson, URL, data, res)
 	if err != nil {
 		return nil, err
 	} else {
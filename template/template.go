@@ -59,6 +59,11 @@ IT科技	网络游戏	6
 
 // 设置行业可在微信公众平台后台完成，每月可修改行业1次
 func SetIndustry(token string, industry []int) error {
+	return SetIndustryWith(wechat.DefaultClient, token, industry)
+}
+
+// SetIndustryWith 同 SetIndustry, 通过客户端 c 发送请求
+func SetIndustryWith(c *wechat.Client, token string, industry []int) error {
 	ul := "https://api.weixin.qq.com/cgi-bin/template/api_set_industry?access_token=" + token
 	var param map[string]int
 	ln := len(industry)
@@ -72,7 +77,7 @@ func SetIndustry(token string, industry []int) error {
 	} else {
 		return errors.New("未设置行业")
 	}
-	return wechat.PostSchema(wechat.KindJson, ul, param, nil)
+	return c.PostSchema(wechat.KindJson, ul, param, nil)
 }
 
 type Industry struct {
@@ -87,17 +92,27 @@ type Name struct {
 
 // 获取设置的行业信息
 func GetIndustry(token string) (industry *Industry, err error) {
+	return GetIndustryWith(wechat.DefaultClient, token)
+}
+
+// GetIndustryWith 同 GetIndustry, 通过客户端 c 发送请求
+func GetIndustryWith(c *wechat.Client, token string) (industry *Industry, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/template/get_industry?access_token=" + token
-	err = wechat.GetJson(ul, &industry)
+	err = c.GetJson(ul, &industry)
 	return
 }
 
 func GetTemplateID(token, shortID string) (long string, err error) {
+	return GetTemplateIDWith(wechat.DefaultClient, token, shortID)
+}
+
+// GetTemplateIDWith 同 GetTemplateID, 通过客户端 c 发送请求
+func GetTemplateIDWith(c *wechat.Client, token, shortID string) (long string, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/template/api_add_template?access_token=" + token
 	var res struct {
 		Long string `json:"template_id"`
 	}
-	err = wechat.PostSchema(wechat.KindJson, ul, map[string]interface{}{
+	err = c.PostSchema(wechat.KindJson, ul, map[string]interface{}{
 		"template_id_short": shortID,
 	}, &res)
 	if err != nil {
@@ -108,11 +123,16 @@ func GetTemplateID(token, shortID string) (long string, err error) {
 }
 
 func GetTemplates(token string) (templates []*Template, err error) {
+	return GetTemplatesWith(wechat.DefaultClient, token)
+}
+
+// GetTemplatesWith 同 GetTemplates, 通过客户端 c 发送请求
+func GetTemplatesWith(c *wechat.Client, token string) (templates []*Template, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/template/get_all_private_template?access_token=" + token
 	var res = struct {
 		Templates []*Template `json:"template_list"`
 	}{}
-	err = wechat.GetJson(ul, &res)
+	err = c.GetJson(ul, &res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -121,8 +141,13 @@ func GetTemplates(token string) (templates []*Template, err error) {
 }
 
 func DelTemplate(token, id string) error {
+	return DelTemplateWith(wechat.DefaultClient, token, id)
+}
+
+// DelTemplateWith 同 DelTemplate, 通过客户端 c 发送请求
+func DelTemplateWith(c *wechat.Client, token, id string) error {
 	ul := "https://api.weixin.qq.com/cgi-bin/template/del_private_template?access_token=" + token
-	return wechat.PostSchema(wechat.KindJson, ul, map[string]string{"template_id": id}, nil)
+	return c.PostSchema(wechat.KindJson, ul, map[string]string{"template_id": id}, nil)
 }
 
 type Message struct {
@@ -134,6 +159,11 @@ type Message struct {
 }
 
 func (msg *Message) Send(token, openID string) (msgID int64, err error) {
+	return msg.SendWith(wechat.DefaultClient, token, openID)
+}
+
+// SendWith 同 Send, 通过客户端 c 发送请求
+func (msg *Message) SendWith(c *wechat.Client, token, openID string) (msgID int64, err error) {
 	ul := "https://api.weixin.qq.com/cgi-bin/message/template/send?access_token=" + token
 	var res struct {
 		MsgID int64 `json:"msgid"`
@@ -141,7 +171,7 @@ func (msg *Message) Send(token, openID string) (msgID int64, err error) {
 	if openID != "" {
 		msg.ToUser = openID
 	}
-	err = wechat.PostSchema(wechat.KindJson, ul, msg, &res)
+	err = c.PostSchema(wechat.KindJson, ul, msg, &res)
 	if err != nil {
 		return 0, err
 	} else {
@@ -11,9 +11,14 @@ import (
 
 // 获得已订阅用户列表
 func GetSubscribers(token string, walk func(openids []string) error) error {
+	return GetSubscribersWith(wechat.DefaultClient, token, walk)
+}
+
+// GetSubscribersWith 同 GetSubscribers, 通过客户端 c 发送请求
+func GetSubscribersWith(c *wechat.Client, token string, walk func(openids []string) error) error {
 	var nextOpenid string
 	for {
-		users, err := GetNextSubscribers(token, nextOpenid)
+		users, err := GetNextSubscribersWith(c, token, nextOpenid)
 		if err != nil {
 			return err
 		} else {
@@ -32,12 +37,17 @@ func GetSubscribers(token string, walk func(openids []string) error) error {
 }
 
 func GetNextSubscribers(token, nextOpenID string) (users *Users, err error) {
+	return GetNextSubscribersWith(wechat.DefaultClient, token, nextOpenID)
+}
+
+// GetNextSubscribersWith 同 GetNextSubscribers, 通过客户端 c 发送请求
+func GetNextSubscribersWith(c *wechat.Client, token, nextOpenID string) (users *Users, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/user/get?access_token=" + token
 	if nextOpenID != "" {
 		uri += "&next_openid=" + nextOpenID
 	}
 	users = &Users{}
-	err = wechat.GetJson(uri, users)
+	err = c.GetJson(uri, users)
 	if err != nil {
 		return nil, err
 	} else {
@@ -12,18 +12,25 @@ import (
 
 // 创建标签
 func CreateTag(name, token string) (tag *Tag, err error) {
+	return CreateTagWith(wechat.DefaultClient, name, token)
+}
+
+// CreateTagWith 同 CreateTag, 通过客户端 c 发送请求
+func CreateTagWith(c *wechat.Client, name, token string) (tag *Tag, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/create?access_token=" + token
 	data := map[string]map[string]string{
 		"tag": {
 			"name": name,
 		},
 	}
-	tag = &Tag{}
-	err = wechat.PostSchema(wechat.KindJson, uri, data, tag)
+	res := &struct {
+		Tag *Tag `json:"tag"`
+	}{}
+	err = c.PostSchema(wechat.KindJson, uri, data, res)
 	if err != nil {
 		return nil, err
 	} else {
-		return tag, nil
+		return res.Tag, nil
 	}
 }
 
@@ -35,11 +42,16 @@ type Tag struct {
 
 // 获取标签
 func GetTags(token string) (tags []*Tag, err error) {
+	return GetTagsWith(wechat.DefaultClient, token)
+}
+
+// GetTagsWith 同 GetTags, 通过客户端 c 发送请求
+func GetTagsWith(c *wechat.Client, token string) (tags []*Tag, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/get?access_token=" + token
 	res := &struct {
 		Tags []*Tag `json:"tags"`
 	}{}
-	err = wechat.GetJson(uri, res)
+	err = c.GetJson(uri, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -49,26 +61,41 @@ func GetTags(token string) (tags []*Tag, err error) {
 
 // 修改标签
 func UpdateTag(tag *Tag, token string) error {
+	return UpdateTagWith(wechat.DefaultClient, tag, token)
+}
+
+// UpdateTagWith 同 UpdateTag, 通过客户端 c 发送请求
+func UpdateTagWith(c *wechat.Client, tag *Tag, token string) error {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/update?access_token=" + token
-	return wechat.PostSchema(wechat.KindJson, uri, map[string]*Tag{"tag": tag}, nil)
+	return c.PostSchema(wechat.KindJson, uri, map[string]*Tag{"tag": tag}, nil)
 }
 
 // 删除标签
 func DeleteTag(tag *Tag, token string) error {
+	return DeleteTagWith(wechat.DefaultClient, tag, token)
+}
+
+// DeleteTagWith 同 DeleteTag, 通过客户端 c 发送请求
+func DeleteTagWith(c *wechat.Client, tag *Tag, token string) error {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/delete?access_token=" + token
-	return wechat.PostSchema(wechat.KindJson, uri, map[string]*Tag{"tag": tag}, nil)
+	return c.PostSchema(wechat.KindJson, uri, map[string]*Tag{"tag": tag}, nil)
 }
 
 // 获取标签下粉丝列表
 // nextOpenid 为第一个拉取的OPENID，不填默认从头开始拉取
 func GetTagUsers(tagID int, nextOpenid, token string) (res *Users, err error) {
+	return GetTagUsersWith(wechat.DefaultClient, tagID, nextOpenid, token)
+}
+
+// GetTagUsersWith 同 GetTagUsers, 通过客户端 c 发送请求
+func GetTagUsersWith(c *wechat.Client, tagID int, nextOpenid, token string) (res *Users, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/user/tag/get?access_token=" + token
 	data := map[string]interface{}{"tagid": tagID}
 	if nextOpenid != "" {
 		data["next_openid"] = nextOpenid
 	}
 	res = &Users{}
-	err = wechat.PostSchema(wechat.KindJson, uri, data, res)
+	err = c.Idempotent().PostSchema(wechat.KindJson, uri, data, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -78,31 +105,46 @@ func GetTagUsers(tagID int, nextOpenid, token string) (res *Users, err error) {
 
 // 批量设置用户标签
 func TagUsers(tagID int, openids []string, token string) error {
+	return TagUsersWith(wechat.DefaultClient, tagID, openids, token)
+}
+
+// TagUsersWith 同 TagUsers, 通过客户端 c 发送请求
+func TagUsersWith(c *wechat.Client, tagID int, openids []string, token string) error {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/members/batchtagging?access_token=" + token
 	params := map[string]interface{}{
 		"tagid":       tagID,
 		"openid_list": openids,
 	}
-	return wechat.PostSchema(wechat.KindJson, uri, params, nil)
+	return c.PostSchema(wechat.KindJson, uri, params, nil)
 }
 
 // 取消用户标签
 func UntagUsers(tagID int, openids []string, token string) error {
+	return UntagUsersWith(wechat.DefaultClient, tagID, openids, token)
+}
+
+// UntagUsersWith 同 UntagUsers, 通过客户端 c 发送请求
+func UntagUsersWith(c *wechat.Client, tagID int, openids []string, token string) error {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/members/batchuntagging?access_token=" + token
 	params := map[string]interface{}{
 		"tagid":       tagID,
 		"openid_list": openids,
 	}
-	return wechat.PostSchema(wechat.KindJson, uri, params, nil)
+	return c.PostSchema(wechat.KindJson, uri, params, nil)
 }
 
 // 获取用户所有标签
 func GetUserTags(openid, token string) (tagIDs []int, err error) {
+	return GetUserTagsWith(wechat.DefaultClient, openid, token)
+}
+
+// GetUserTagsWith 同 GetUserTags, 通过客户端 c 发送请求
+func GetUserTagsWith(c *wechat.Client, openid, token string) (tagIDs []int, err error) {
 	uri := "https://api.weixin.qq.com/cgi-bin/tags/getidlist?access_token=" + token
 	res := &struct {
 		TagIDList []int `json:"tagid_list"`
 	}{}
-	err = wechat.PostSchema(wechat.KindJson, uri, map[string]string{"openid": openid}, res)
+	err = c.Idempotent().PostSchema(wechat.KindJson, uri, map[string]string{"openid": openid}, res)
 	if err != nil {
 		return nil, err
 	} else {
@@ -0,0 +1,277 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechattest
+
+import (
+	"encoding/json"
+)
+
+// App 保存公众号的模拟数据.
+// 字段可在测试中直接读写, 但只能在没有未完成的请求时进行.
+type App struct {
+	Appid  string
+	Secret string
+
+	// 昵称, 用于第三方平台获取授权方信息
+	NickName string
+
+	// 最后一次获取的 jsapi ticket
+	Ticket string
+
+	// 最后一次创建的菜单, 为 nil 时表示未创建
+	Menu json.RawMessage
+
+	// 默认菜单的菜单ID, 每次创建时更新
+	MenuID int
+
+	// 个性化菜单
+	ConditionalMenus []*ConditionalMenu
+
+	// 用户列表, 按关注顺序排列
+	Users []*User
+
+	Tags []*Tag
+
+	// 永久素材
+	Materials []*Material
+
+	// 临时素材, 上传3天后过期
+	Media []*Media
+
+	// 草稿箱
+	Drafts []*Draft
+
+	// 发布任务, 提交后状态为发布中, 第一次查询之后变为发布成功
+	Publishes []*Publish
+
+	// 模板消息所属行业
+	Industry []int
+
+	// 私有模板
+	Templates []*Template
+
+	// 已发送的模板消息、客服消息及群发消息(包括预览)
+	TemplateMessages []json.RawMessage
+	CustomMessages   []json.RawMessage
+	MassMessages     []*MassMessage
+
+	// 群发速度级别
+	MassSpeed int
+
+	// 已创建的二维码请求数据
+	QRCodes []json.RawMessage
+
+	// 用户分析数据, 按 ref_date 过滤后返回
+	UserSummary  []*UserSummary
+	UserCumulate []*UserCumulate
+
+	// 授权方选项, 用于第三方平台接口
+	Options map[string]string
+
+	// 统一下单请求数据
+	Orders []map[string]string
+
+	// 调用次数清零次数
+	QuotaCleared int
+}
+
+// ConditionalMenu 是个性化菜单, Menu 为创建时的请求数据
+type ConditionalMenu struct {
+	MenuID string
+	Menu   json.RawMessage
+}
+
+func newApp(appid string) *App {
+	return &App{
+		Appid:    appid,
+		NickName: appid,
+		Options:  make(map[string]string),
+	}
+}
+
+func (app *App) user(openid string) *User {
+	for _, u := range app.Users {
+		if u.Openid == openid {
+			return u
+		}
+	}
+	return nil
+}
+
+func (app *App) tag(id int) *Tag {
+	for _, t := range app.Tags {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (app *App) material(mediaID string) (int, *Material) {
+	for i, m := range app.Materials {
+		if m.MediaID == mediaID {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+func (app *App) media(mediaID string) *Media {
+	for _, m := range app.Media {
+		if m.MediaID == mediaID {
+			return m
+		}
+	}
+	return nil
+}
+
+func (app *App) draft(mediaID string) (int, *Draft) {
+	for i, d := range app.Drafts {
+		if d.MediaID == mediaID {
+			return i, d
+		}
+	}
+	return -1, nil
+}
+
+func (app *App) publish(find func(p *Publish) bool) (int, *Publish) {
+	for i, p := range app.Publishes {
+		if find(p) {
+			return i, p
+		}
+	}
+	return -1, nil
+}
+
+// User 是公众号的用户
+type User struct {
+	Openid        string `json:"openid"`
+	Subscribe     int    `json:"subscribe"`
+	SubscribeTime int64  `json:"subscribe_time"`
+	Nickname      string `json:"nickname"`
+	UnionID       string `json:"unionid,omitempty"`
+	Remark        string `json:"remark"`
+	TagIDList     []int  `json:"tagid_list"`
+}
+
+func (u *User) hasTag(id int) bool {
+	for _, tid := range u.TagIDList {
+		if tid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag 是用户标签
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Material 是永久素材
+type Material struct {
+	MediaID string
+	Type    string
+	Name    string
+	URL     string
+
+	// 文件数据
+	Data []byte
+
+	// 视频素材的描述信息
+	Description json.RawMessage
+
+	// 图文素材的文章列表
+	Articles json.RawMessage
+
+	UpdateTime int64
+}
+
+// Media 是临时素材, 可修改 CreatedAt 模拟过期
+type Media struct {
+	MediaID string
+	Type    string
+	Name    string
+
+	// 文件数据
+	Data []byte
+
+	CreatedAt int64
+}
+
+// Draft 是草稿
+type Draft struct {
+	MediaID string
+
+	// 文章列表
+	Articles []json.RawMessage
+
+	UpdateTime int64
+}
+
+// Publish 是发布任务
+type Publish struct {
+	PublishID string
+
+	// 草稿的 media_id, 发布后草稿被删除
+	MediaID string
+
+	// 发布状态, 与 publish.Status 相同
+	Status int
+
+	// 发布成功后的文章 id
+	ArticleID string
+
+	// 文章列表, 删除的文章为 nil
+	Articles []json.RawMessage
+
+	UpdateTime int64
+}
+
+// Template 是私有模板
+type Template struct {
+	ID      string `json:"template_id"`
+	ShortID string `json:"-"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// MassMessage 是群发消息
+type MassMessage struct {
+	// 群发接口路径, 用于区分按标签群发、按 openid 群发及预览
+	Path string
+	Body json.RawMessage
+
+	MsgID     int
+	MsgDataID int
+}
+
+type UserSummary struct {
+	RefDate    string `json:"ref_date"`
+	UserSource int    `json:"user_source"`
+	NewUser    int    `json:"new_user"`
+	CancelUser int    `json:"cancel_user"`
+}
+
+type UserCumulate struct {
+	RefDate      string `json:"ref_date"`
+	CumulateUser int    `json:"cumulate_user"`
+}
+
+// Component 保存第三方平台的模拟数据
+type Component struct {
+	Appid  string
+	Secret string
+
+	// 不为空时校验 component_verify_ticket
+	VerifyTicket string
+
+	// 授权方授权给第三方平台的权限集 ID
+	FuncInfo []int
+
+	// authorizer_refresh_token -> authorizer_appid
+	refreshTokens map[string]string
+}
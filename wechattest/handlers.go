@@ -0,0 +1,1539 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechattest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orivil/wechat"
+)
+
+// 第三方平台错误码, 未收录在 wechat 的错误码目录中
+const (
+	errCodeComponentUnauthorized = 61003 // 授权方未授权给该第三方平台
+	errCodeInvalidVerifyTicket   = 61006 // component_verify_ticket 无效
+
+	errCodeConditionalMenuNotExist = 65301 // 不存在此menuid对应的个性化菜单
+	errCodeNoDefaultMenu           = 65303 // 没有默认菜单，不能创建个性化菜单
+	errCodeEmptyMatchRule          = 65304 // MatchRule信息为空
+)
+
+// 接口的令牌校验方式
+const (
+	authNone = iota
+	authApp
+	authComponent
+)
+
+type route struct {
+	auth   int
+	xml    bool
+	handle func(s *Server, c *call) (res interface{}, errcode int)
+}
+
+// 处理请求时已持有 Server.mu
+type call struct {
+	*Request
+	app       *App
+	component *Component
+}
+
+func (c *call) decode(v interface{}) int {
+	if len(bytes.TrimSpace(c.Body)) == 0 {
+		return wechat.ErrCodeEmptyPostData
+	}
+	if err := json.Unmarshal(c.Body, v); err != nil {
+		return wechat.ErrCodeInvalidJSON
+	}
+	return 0
+}
+
+// 解析 multipart 请求, 返回 media 文件名及数据
+func (c *call) file() (filename string, data []byte, form map[string][]string, errcode int) {
+	r := &http.Request{
+		Method: http.MethodPost,
+		Header: c.Header,
+		Body:   ioutil.NopCloser(bytes.NewReader(c.Body)),
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return "", nil, nil, wechat.ErrCodeEmptyMediaData
+	}
+	f, header, err := r.FormFile("media")
+	if err != nil {
+		return "", nil, nil, wechat.ErrCodeEmptyMediaData
+	}
+	defer f.Close()
+	data, _ = ioutil.ReadAll(f)
+	return header.Filename, data, r.MultipartForm.Value, 0
+}
+
+var routes = map[string]route{
+	"/cgi-bin/token":                             {authNone, false, handleToken},
+	"/cgi-bin/ticket/getticket":                  {authApp, false, handleTicket},
+	"/cgi-bin/clear_quota":                       {authApp, false, handleClearQuota},
+	"/cgi-bin/shorturl":                          {authApp, false, handleShortURL},
+	"/cgi-bin/menu/create":                       {authApp, false, handleMenuCreate},
+	"/cgi-bin/menu/get":                          {authApp, false, handleMenuGet},
+	"/cgi-bin/menu/delete":                       {authApp, false, handleMenuDelete},
+	"/cgi-bin/menu/addconditional":               {authApp, false, handleMenuAddConditional},
+	"/cgi-bin/menu/delconditional":               {authApp, false, handleMenuDelConditional},
+	"/cgi-bin/menu/trymatch":                     {authApp, false, handleMenuTryMatch},
+	"/cgi-bin/get_current_selfmenu_info":         {authApp, false, handleSelfMenuInfo},
+	"/cgi-bin/material/add_material":             {authApp, false, handleAddMaterial},
+	"/cgi-bin/material/add_news":                 {authApp, false, handleAddNews},
+	"/cgi-bin/material/update_news":              {authApp, false, handleUpdateNews},
+	"/cgi-bin/material/get_material":             {authApp, false, handleGetMaterial},
+	"/cgi-bin/material/del_material":             {authApp, false, handleDelMaterial},
+	"/cgi-bin/material/get_materialcount":        {authApp, false, handleMaterialCount},
+	"/cgi-bin/material/batchget_material":        {authApp, false, handleBatchGetMaterial},
+	"/cgi-bin/media/uploadimg":                   {authApp, false, handleUploadImage},
+	"/cgi-bin/media/uploadvideo":                 {authApp, false, handleUploadVideo},
+	"/cgi-bin/media/upload":                      {authApp, false, handleMediaUpload},
+	"/cgi-bin/media/get":                         {authApp, false, handleMediaGet},
+	"/cgi-bin/media/get/jssdk":                   {authApp, false, handleMediaGet},
+	"/cgi-bin/draft/add":                         {authApp, false, handleDraftAdd},
+	"/cgi-bin/draft/get":                         {authApp, false, handleDraftGet},
+	"/cgi-bin/draft/update":                      {authApp, false, handleDraftUpdate},
+	"/cgi-bin/draft/delete":                      {authApp, false, handleDraftDelete},
+	"/cgi-bin/draft/batchget":                    {authApp, false, handleDraftBatchGet},
+	"/cgi-bin/draft/count":                       {authApp, false, handleDraftCount},
+	"/cgi-bin/freepublish/submit":                {authApp, false, handlePublishSubmit},
+	"/cgi-bin/freepublish/get":                   {authApp, false, handlePublishGet},
+	"/cgi-bin/freepublish/delete":                {authApp, false, handlePublishDelete},
+	"/cgi-bin/freepublish/getarticle":            {authApp, false, handlePublishGetArticle},
+	"/cgi-bin/freepublish/batchget":              {authApp, false, handlePublishBatchGet},
+	"/cgi-bin/tags/create":                       {authApp, false, handleTagCreate},
+	"/cgi-bin/tags/get":                          {authApp, false, handleTagGet},
+	"/cgi-bin/tags/update":                       {authApp, false, handleTagUpdate},
+	"/cgi-bin/tags/delete":                       {authApp, false, handleTagDelete},
+	"/cgi-bin/tags/members/batchtagging":         {authApp, false, handleTagging(true)},
+	"/cgi-bin/tags/members/batchuntagging":       {authApp, false, handleTagging(false)},
+	"/cgi-bin/tags/getidlist":                    {authApp, false, handleUserTags},
+	"/cgi-bin/user/tag/get":                      {authApp, false, handleTagUsers},
+	"/cgi-bin/user/get":                          {authApp, false, handleUserGet},
+	"/cgi-bin/user/info/batchget":                {authApp, false, handleUserInfo},
+	"/cgi-bin/template/api_set_industry":         {authApp, false, handleSetIndustry},
+	"/cgi-bin/template/get_industry":             {authApp, false, handleGetIndustry},
+	"/cgi-bin/template/api_add_template":         {authApp, false, handleAddTemplate},
+	"/cgi-bin/template/get_all_private_template": {authApp, false, handleGetTemplates},
+	"/cgi-bin/template/del_private_template":     {authApp, false, handleDelTemplate},
+	"/cgi-bin/message/template/send":             {authApp, false, handleTemplateSend},
+	"/cgi-bin/message/custom/send":               {authApp, false, handleCustomSend},
+	"/cgi-bin/message/mass/sendall":              {authApp, false, handleMassSend},
+	"/cgi-bin/message/mass/send":                 {authApp, false, handleMassSend},
+	"/cgi-bin/message/mass/preview":              {authApp, false, handleMassSend},
+	"/cgi-bin/message/mass/speed/get":            {authApp, false, handleGetMassSpeed},
+	"/cgi-bin/message/mass/speed/set":            {authApp, false, handleSetMassSpeed},
+	"/cgi-bin/qrcode/create":                     {authApp, false, handleQRCode},
+	"/datacube/getusersummary":                   {authApp, false, handleUserSummary},
+	"/datacube/getusercumulate":                  {authApp, false, handleUserCumulate},
+
+	"/cgi-bin/component/api_component_token":       {authNone, false, handleComponentToken},
+	"/cgi-bin/component/api_create_preauthcode":    {authComponent, false, handlePreAuthCode},
+	"/cgi-bin/component/api_query_auth":            {authComponent, false, handleQueryAuth},
+	"/cgi-bin/component/api_authorizer_token":      {authComponent, false, handleAuthorizerToken},
+	"/cgi-bin/component/api_get_authorizer_info":   {authComponent, false, handleAuthorizerInfo},
+	"/cgi-bin/component/api_get_authorizer_option": {authComponent, false, handleGetAuthorizerOption},
+	"/cgi-bin/component/api_set_authorizer_option": {authComponent, false, handleSetAuthorizerOption},
+	"/cgi-bin/component/clear_quota":               {authComponent, false, handleClearComponentQuota},
+
+	"/pay/unifiedorder": {authNone, true, handleUnifiedOrder},
+}
+
+func handleToken(s *Server, c *call) (interface{}, int) {
+	app := s.apps[c.Query.Get("appid")]
+	if app == nil {
+		return nil, wechat.ErrCodeInvalidAppid
+	}
+	if app.Secret != c.Query.Get("secret") {
+		return nil, wechat.ErrCodeInvalidAppSecret
+	}
+	return map[string]interface{}{
+		"access_token": s.issueToken(s.tokens, "ACCESS_TOKEN", app.Appid),
+		"expires_in":   TokenExpiresIn,
+	}, 0
+}
+
+func handleTicket(s *Server, c *call) (interface{}, int) {
+	c.app.Ticket = s.newID("TICKET")
+	return map[string]interface{}{
+		"errcode":    0,
+		"errmsg":     "ok",
+		"ticket":     c.app.Ticket,
+		"expires_in": TokenExpiresIn,
+	}, 0
+}
+
+func handleClearQuota(s *Server, c *call) (interface{}, int) {
+	c.app.QuotaCleared++
+	return nil, 0
+}
+
+func handleShortURL(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		LongURL string `json:"long_url"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	return map[string]interface{}{"errcode": 0, "errmsg": "ok", "short_url": "https://w.url.cn/" + s.newID("s")}, 0
+}
+
+func handleMenuCreate(s *Server, c *call) (interface{}, int) {
+	var menu struct {
+		Button []json.RawMessage `json:"button"`
+	}
+	if errcode := c.decode(&menu); errcode != 0 {
+		return nil, errcode
+	}
+	if len(menu.Button) == 0 || len(menu.Button) > 3 {
+		return nil, wechat.ErrCodeInvalidButtonCount
+	}
+	c.app.Menu = append(json.RawMessage(nil), c.Body...)
+	c.app.MenuID = s.nextSeq()
+	return nil, 0
+}
+
+// 菜单数据, 用于在返回数据中加入 menuid
+type menuData map[string]json.RawMessage
+
+func decodeMenu(data json.RawMessage, menuID interface{}) menuData {
+	m := menuData{}
+	_ = json.Unmarshal(data, &m)
+	if menuID != nil {
+		m["menuid"], _ = json.Marshal(menuID)
+	} else {
+		delete(m, "menuid")
+	}
+	delete(m, "matchrule")
+	return m
+}
+
+func handleMenuGet(s *Server, c *call) (interface{}, int) {
+	if c.app.Menu == nil {
+		return nil, wechat.ErrCodeMenuNotExist
+	}
+	res := map[string]interface{}{"menu": decodeMenu(c.app.Menu, c.app.MenuID)}
+	if len(c.app.ConditionalMenus) > 0 {
+		menus := make([]menuData, len(c.app.ConditionalMenus))
+		for i, cm := range c.app.ConditionalMenus {
+			id, _ := strconv.Atoi(cm.MenuID)
+			menus[i] = decodeMenu(cm.Menu, id)
+			var rule struct {
+				MatchRule json.RawMessage `json:"matchrule"`
+			}
+			_ = json.Unmarshal(cm.Menu, &rule)
+			menus[i]["matchrule"] = rule.MatchRule
+		}
+		res["conditionalmenu"] = menus
+	}
+	return res, 0
+}
+
+func handleMenuDelete(s *Server, c *call) (interface{}, int) {
+	c.app.Menu = nil
+	c.app.MenuID = 0
+	c.app.ConditionalMenus = nil
+	return nil, 0
+}
+
+func handleMenuAddConditional(s *Server, c *call) (interface{}, int) {
+	var menu struct {
+		Button    []json.RawMessage `json:"button"`
+		MatchRule map[string]string `json:"matchrule"`
+	}
+	if errcode := c.decode(&menu); errcode != 0 {
+		return nil, errcode
+	}
+	if len(menu.Button) == 0 || len(menu.Button) > 3 {
+		return nil, wechat.ErrCodeInvalidButtonCount
+	}
+	if c.app.Menu == nil {
+		return nil, errCodeNoDefaultMenu
+	}
+	empty := true
+	for _, v := range menu.MatchRule {
+		if v != "" {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, errCodeEmptyMatchRule
+	}
+	id := strconv.Itoa(s.nextSeq())
+	c.app.ConditionalMenus = append(c.app.ConditionalMenus, &ConditionalMenu{
+		MenuID: id,
+		Menu:   append(json.RawMessage(nil), c.Body...),
+	})
+	return map[string]string{"menuid": id}, 0
+}
+
+func handleMenuDelConditional(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		MenuID string `json:"menuid"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	for i, cm := range c.app.ConditionalMenus {
+		if cm.MenuID == req.MenuID {
+			c.app.ConditionalMenus = append(c.app.ConditionalMenus[:i], c.app.ConditionalMenus[i+1:]...)
+			return nil, 0
+		}
+	}
+	return nil, errCodeConditionalMenuNotExist
+}
+
+// 只按 tag_id 匹配个性化菜单, 设置了其他匹配条件的菜单不会被匹配. 后创建的个性化菜单优先匹配
+func handleMenuTryMatch(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	user := c.app.user(req.UserID)
+	if user == nil {
+		return nil, wechat.ErrCodeInvalidOpenid
+	}
+	if c.app.Menu == nil {
+		return nil, wechat.ErrCodeMenuNotExist
+	}
+	for i := len(c.app.ConditionalMenus) - 1; i >= 0; i-- {
+		cm := c.app.ConditionalMenus[i]
+		var menu struct {
+			MatchRule map[string]string `json:"matchrule"`
+		}
+		_ = json.Unmarshal(cm.Menu, &menu)
+		tagID := menu.MatchRule["tag_id"]
+		delete(menu.MatchRule, "tag_id")
+		for k, v := range menu.MatchRule {
+			if v == "" {
+				delete(menu.MatchRule, k)
+			}
+		}
+		if tagID == "" || len(menu.MatchRule) > 0 {
+			continue
+		}
+		for _, id := range user.TagIDList {
+			if strconv.Itoa(id) == tagID {
+				return decodeMenu(cm.Menu, nil), 0
+			}
+		}
+	}
+	return decodeMenu(c.app.Menu, nil), 0
+}
+
+// 将接口设置的默认菜单转换为 get_current_selfmenu_info 的返回格式
+func handleSelfMenuInfo(s *Server, c *call) (interface{}, int) {
+	if c.app.Menu == nil {
+		return map[string]interface{}{"is_menu_open": 0}, 0
+	}
+	var menu struct {
+		Button []map[string]interface{} `json:"button"`
+	}
+	_ = json.Unmarshal(c.app.Menu, &menu)
+	for _, button := range menu.Button {
+		if sub, ok := button["sub_button"].([]interface{}); ok && len(sub) > 0 {
+			button["sub_button"] = map[string]interface{}{"list": sub}
+		} else {
+			delete(button, "sub_button")
+		}
+	}
+	return map[string]interface{}{
+		"is_menu_open":  1,
+		"selfmenu_info": map[string]interface{}{"button": menu.Button},
+	}, 0
+}
+
+func handleAddMaterial(s *Server, c *call) (interface{}, int) {
+	kind := c.Query.Get("type")
+	switch kind {
+	case "image", "voice", "video", "thumb":
+	default:
+		return nil, wechat.ErrCodeInvalidMediaType
+	}
+	filename, data, form, errcode := c.file()
+	if errcode != 0 {
+		return nil, errcode
+	}
+	m := &Material{
+		MediaID:    s.newID("MEDIA_ID"),
+		Type:       kind,
+		Name:       filename,
+		Data:       data,
+		UpdateTime: time.Now().Unix(),
+	}
+	if kind == "video" {
+		desc := form["description"]
+		if len(desc) == 0 {
+			return nil, wechat.ErrCodeInvalidJSON
+		}
+		m.Description = json.RawMessage(desc[0])
+	}
+	res := map[string]interface{}{"media_id": m.MediaID}
+	if kind == "image" {
+		m.URL = s.URL + "/mmbiz/" + m.MediaID
+		res["url"] = m.URL
+	}
+	c.app.Materials = append(c.app.Materials, m)
+	return res, 0
+}
+
+func handleAddNews(s *Server, c *call) (interface{}, int) {
+	var news struct {
+		Articles json.RawMessage `json:"articles"`
+	}
+	if errcode := c.decode(&news); errcode != 0 {
+		return nil, errcode
+	}
+	m := &Material{
+		MediaID:    s.newID("MEDIA_ID"),
+		Type:       "news",
+		Articles:   news.Articles,
+		UpdateTime: time.Now().Unix(),
+	}
+	c.app.Materials = append(c.app.Materials, m)
+	return map[string]interface{}{"media_id": m.MediaID}, 0
+}
+
+func handleUpdateNews(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		MediaID  string          `json:"media_id"`
+		Index    int             `json:"index"`
+		Articles json.RawMessage `json:"articles"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, m := c.app.material(req.MediaID)
+	if m == nil || m.Type != "news" {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	var articles []json.RawMessage
+	_ = json.Unmarshal(m.Articles, &articles)
+	if req.Index < 0 || req.Index >= len(articles) || len(req.Articles) == 0 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	articles[req.Index] = req.Articles
+	m.Articles, _ = json.Marshal(articles)
+	m.UpdateTime = time.Now().Unix()
+	return nil, 0
+}
+
+type mediaIDRequest struct {
+	MediaID string `json:"media_id"`
+}
+
+func handleGetMaterial(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, m := c.app.material(req.MediaID)
+	if m == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	switch m.Type {
+	case "news":
+		return map[string]interface{}{"news_item": m.Articles}, 0
+	case "video":
+		var desc struct {
+			Title        string `json:"title"`
+			Introduction string `json:"introduction"`
+		}
+		_ = json.Unmarshal(m.Description, &desc)
+		return map[string]interface{}{
+			"title":       desc.Title,
+			"description": desc.Introduction,
+			"down_url":    s.URL + "/mmbiz/" + m.MediaID,
+		}, 0
+	default:
+		return m.Data, 0
+	}
+}
+
+func handleDelMaterial(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	i, m := c.app.material(req.MediaID)
+	if m == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	c.app.Materials = append(c.app.Materials[:i], c.app.Materials[i+1:]...)
+	return nil, 0
+}
+
+func handleMaterialCount(s *Server, c *call) (interface{}, int) {
+	count := map[string]int{"voice_count": 0, "video_count": 0, "image_count": 0, "news_count": 0}
+	for _, m := range c.app.Materials {
+		if m.Type != "thumb" {
+			count[m.Type+"_count"]++
+		}
+	}
+	return count, 0
+}
+
+func handleBatchGetMaterial(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		Type   string `json:"type"`
+		Offset int    `json:"offset"`
+		Count  int    `json:"count"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if req.Count < 1 || req.Count > 20 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	var matched []*Material
+	for _, m := range c.app.Materials {
+		if m.Type == req.Type {
+			matched = append(matched, m)
+		}
+	}
+	items := make([]interface{}, 0, req.Count)
+	for i := req.Offset; i < len(matched) && len(items) < req.Count; i++ {
+		m := matched[i]
+		if m.Type == "news" {
+			items = append(items, map[string]interface{}{
+				"media_id":    m.MediaID,
+				"content":     map[string]interface{}{"news_item": m.Articles},
+				"update_time": m.UpdateTime,
+			})
+		} else {
+			items = append(items, map[string]interface{}{
+				"media_id":    m.MediaID,
+				"name":        m.Name,
+				"update_time": m.UpdateTime,
+				"url":         m.URL,
+			})
+		}
+	}
+	return map[string]interface{}{
+		"total_count": len(matched),
+		"item_count":  len(items),
+		"item":        items,
+	}, 0
+}
+
+func handleUploadImage(s *Server, c *call) (interface{}, int) {
+	_, _, _, errcode := c.file()
+	if errcode != 0 {
+		return nil, errcode
+	}
+	return map[string]interface{}{"url": s.URL + "/mmbiz/" + s.newID("IMAGE")}, 0
+}
+
+func handleUploadVideo(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if _, m := c.app.material(req.MediaID); m == nil || m.Type != "video" {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	return map[string]interface{}{
+		"type":       "video",
+		"media_id":   s.newID("MEDIA_ID"),
+		"created_at": time.Now().Unix(),
+	}, 0
+}
+
+func handleMediaUpload(s *Server, c *call) (interface{}, int) {
+	kind := c.Query.Get("type")
+	switch kind {
+	case "image", "voice", "video", "thumb":
+	default:
+		return nil, wechat.ErrCodeInvalidMediaType
+	}
+	filename, data, _, errcode := c.file()
+	if errcode != 0 {
+		return nil, errcode
+	}
+	if len(data) == 0 {
+		return nil, wechat.ErrCodeEmptyMediaData
+	}
+	m := &Media{
+		MediaID:   s.newID("MEDIA_ID"),
+		Type:      kind,
+		Name:      filename,
+		Data:      data,
+		CreatedAt: time.Now().Unix(),
+	}
+	c.app.Media = append(c.app.Media, m)
+	res := map[string]interface{}{"type": kind, "created_at": m.CreatedAt}
+	if kind == "thumb" {
+		res["thumb_media_id"] = m.MediaID
+	} else {
+		res["media_id"] = m.MediaID
+	}
+	return res, 0
+}
+
+func handleMediaGet(s *Server, c *call) (interface{}, int) {
+	m := c.app.media(c.Query.Get("media_id"))
+	if m == nil || time.Since(time.Unix(m.CreatedAt, 0)) > 3*24*time.Hour {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	if m.Type == "video" {
+		return map[string]interface{}{"video_url": s.URL + "/mmbiz/" + m.MediaID}, 0
+	}
+	return &file{name: m.Name, data: m.Data}, 0
+}
+
+func handleDraftAdd(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		Articles []json.RawMessage `json:"articles"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if len(req.Articles) == 0 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	d := &Draft{
+		MediaID:    s.newID("MEDIA_ID"),
+		Articles:   req.Articles,
+		UpdateTime: time.Now().Unix(),
+	}
+	c.app.Drafts = append(c.app.Drafts, d)
+	return map[string]interface{}{"media_id": d.MediaID}, 0
+}
+
+func handleDraftGet(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, d := c.app.draft(req.MediaID)
+	if d == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	return map[string]interface{}{"news_item": d.Articles}, 0
+}
+
+func handleDraftUpdate(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		MediaID  string          `json:"media_id"`
+		Index    int             `json:"index"`
+		Articles json.RawMessage `json:"articles"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, d := c.app.draft(req.MediaID)
+	if d == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	if req.Index < 0 || req.Index >= len(d.Articles) || len(req.Articles) == 0 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	d.Articles[req.Index] = req.Articles
+	d.UpdateTime = time.Now().Unix()
+	return nil, 0
+}
+
+func handleDraftDelete(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	i, d := c.app.draft(req.MediaID)
+	if d == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	c.app.Drafts = append(c.app.Drafts[:i], c.app.Drafts[i+1:]...)
+	return nil, 0
+}
+
+type batchGetRequest struct {
+	Offset    int `json:"offset"`
+	Count     int `json:"count"`
+	NoContent int `json:"no_content"`
+}
+
+// 按请求分页, 返回列表数据
+func (req *batchGetRequest) page(total int, item func(i int, noContent bool) interface{}) (interface{}, int) {
+	if req.Count < 1 || req.Count > 20 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	items := make([]interface{}, 0, req.Count)
+	for i := req.Offset; i < total && len(items) < req.Count; i++ {
+		items = append(items, item(i, req.NoContent == 1))
+	}
+	return map[string]interface{}{
+		"total_count": total,
+		"item_count":  len(items),
+		"item":        items,
+	}, 0
+}
+
+func handleDraftBatchGet(s *Server, c *call) (interface{}, int) {
+	var req batchGetRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	return req.page(len(c.app.Drafts), func(i int, noContent bool) interface{} {
+		d := c.app.Drafts[i]
+		item := map[string]interface{}{"media_id": d.MediaID, "update_time": d.UpdateTime}
+		if !noContent {
+			item["content"] = map[string]interface{}{"news_item": d.Articles}
+		}
+		return item
+	})
+}
+
+func handleDraftCount(s *Server, c *call) (interface{}, int) {
+	return map[string]interface{}{"total_count": len(c.app.Drafts)}, 0
+}
+
+func handlePublishSubmit(s *Server, c *call) (interface{}, int) {
+	var req mediaIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	i, d := c.app.draft(req.MediaID)
+	if d == nil {
+		return nil, wechat.ErrCodeInvalidMediaID
+	}
+	c.app.Drafts = append(c.app.Drafts[:i], c.app.Drafts[i+1:]...)
+	p := &Publish{
+		PublishID:  strconv.Itoa(s.nextSeq()),
+		MediaID:    d.MediaID,
+		Status:     1,
+		Articles:   d.Articles,
+		UpdateTime: time.Now().Unix(),
+	}
+	c.app.Publishes = append(c.app.Publishes, p)
+	return map[string]interface{}{"publish_id": p.PublishID, "msg_data_id": s.nextSeq()}, 0
+}
+
+// 发布成功的文章信息
+func (s *Server) publishResult(p *Publish) map[string]interface{} {
+	res := map[string]interface{}{
+		"publish_id":     p.PublishID,
+		"publish_status": p.Status,
+		"article_id":     p.ArticleID,
+		"fail_idx":       []int{},
+	}
+	if p.Status == 0 {
+		var items []interface{}
+		for i, a := range p.Articles {
+			if a != nil {
+				items = append(items, map[string]interface{}{
+					"idx":         i + 1,
+					"article_url": s.URL + "/s/" + p.ArticleID + "/" + strconv.Itoa(i+1),
+				})
+			}
+		}
+		res["article_detail"] = map[string]interface{}{"count": len(items), "item": items}
+	}
+	return res
+}
+
+func handlePublishGet(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		PublishID string `json:"publish_id"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, p := c.app.publish(func(p *Publish) bool { return p.PublishID == req.PublishID })
+	if p == nil {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	res := s.publishResult(p)
+	if p.Status == 1 {
+		p.Status = 0
+		p.ArticleID = s.newID("ARTICLE_ID")
+	}
+	return res, 0
+}
+
+type articleIDRequest struct {
+	ArticleID string `json:"article_id"`
+	Index     int    `json:"index"`
+}
+
+func (req *articleIDRequest) find(c *call) (int, *Publish) {
+	return c.app.publish(func(p *Publish) bool { return p.ArticleID != "" && p.ArticleID == req.ArticleID })
+}
+
+func handlePublishDelete(s *Server, c *call) (interface{}, int) {
+	var req articleIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	i, p := req.find(c)
+	if p == nil || req.Index < 0 || req.Index > len(p.Articles) {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	if req.Index > 0 {
+		p.Articles[req.Index-1] = nil
+		for _, a := range p.Articles {
+			if a != nil {
+				return nil, 0
+			}
+		}
+	}
+	// 全部文章已删除
+	c.app.Publishes = append(c.app.Publishes[:i], c.app.Publishes[i+1:]...)
+	return nil, 0
+}
+
+func handlePublishGetArticle(s *Server, c *call) (interface{}, int) {
+	var req articleIDRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	_, p := req.find(c)
+	if p == nil {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	var articles []json.RawMessage
+	for _, a := range p.Articles {
+		if a != nil {
+			articles = append(articles, a)
+		}
+	}
+	return map[string]interface{}{"news_item": articles}, 0
+}
+
+func handlePublishBatchGet(s *Server, c *call) (interface{}, int) {
+	var req batchGetRequest
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	var published []*Publish
+	for _, p := range c.app.Publishes {
+		if p.ArticleID != "" {
+			published = append(published, p)
+		}
+	}
+	return req.page(len(published), func(i int, noContent bool) interface{} {
+		p := published[i]
+		item := map[string]interface{}{"article_id": p.ArticleID, "update_time": p.UpdateTime}
+		if !noContent {
+			item["content"] = map[string]interface{}{"news_item": p.Articles}
+		}
+		return item
+	})
+}
+
+type tagRequest struct {
+	Tag *Tag `json:"tag"`
+}
+
+func (r *tagRequest) decode(c *call) int {
+	if errcode := c.decode(r); errcode != 0 {
+		return errcode
+	}
+	if r.Tag == nil {
+		return wechat.ErrCodeInvalidJSON
+	}
+	return 0
+}
+
+func handleTagCreate(s *Server, c *call) (interface{}, int) {
+	var req tagRequest
+	if errcode := req.decode(c); errcode != 0 {
+		return nil, errcode
+	}
+	// 0, 1, 2 为系统保留标签
+	id := 100
+	for _, t := range c.app.Tags {
+		if t.ID >= id {
+			id = t.ID + 1
+		}
+	}
+	tag := &Tag{ID: id, Name: req.Tag.Name}
+	c.app.Tags = append(c.app.Tags, tag)
+	return map[string]interface{}{"tag": tag}, 0
+}
+
+func handleTagGet(s *Server, c *call) (interface{}, int) {
+	tags := make([]map[string]interface{}, 0, len(c.app.Tags))
+	for _, t := range c.app.Tags {
+		count := 0
+		for _, u := range c.app.Users {
+			if u.hasTag(t.ID) {
+				count++
+			}
+		}
+		tags = append(tags, map[string]interface{}{"id": t.ID, "name": t.Name, "count": count})
+	}
+	return map[string]interface{}{"tags": tags}, 0
+}
+
+func handleTagUpdate(s *Server, c *call) (interface{}, int) {
+	var req tagRequest
+	if errcode := req.decode(c); errcode != 0 {
+		return nil, errcode
+	}
+	tag := c.app.tag(req.Tag.ID)
+	if tag == nil {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	tag.Name = req.Tag.Name
+	return nil, 0
+}
+
+func handleTagDelete(s *Server, c *call) (interface{}, int) {
+	var req tagRequest
+	if errcode := req.decode(c); errcode != 0 {
+		return nil, errcode
+	}
+	for i, t := range c.app.Tags {
+		if t.ID == req.Tag.ID {
+			c.app.Tags = append(c.app.Tags[:i], c.app.Tags[i+1:]...)
+			for _, u := range c.app.Users {
+				u.TagIDList = removeInt(u.TagIDList, t.ID)
+			}
+			return nil, 0
+		}
+	}
+	return nil, wechat.ErrCodeInvalidJSON
+}
+
+func removeInt(ids []int, id int) []int {
+	res := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func handleTagging(tagging bool) func(s *Server, c *call) (interface{}, int) {
+	return func(s *Server, c *call) (interface{}, int) {
+		var req struct {
+			TagID      int      `json:"tagid"`
+			OpenidList []string `json:"openid_list"`
+		}
+		if errcode := c.decode(&req); errcode != 0 {
+			return nil, errcode
+		}
+		if c.app.tag(req.TagID) == nil {
+			return nil, wechat.ErrCodeInvalidJSON
+		}
+		var users []*User
+		for _, openid := range req.OpenidList {
+			u := c.app.user(openid)
+			if u == nil {
+				return nil, wechat.ErrCodeInvalidOpenid
+			}
+			users = append(users, u)
+		}
+		for _, u := range users {
+			u.TagIDList = removeInt(u.TagIDList, req.TagID)
+			if tagging {
+				u.TagIDList = append(u.TagIDList, req.TagID)
+			}
+		}
+		return nil, 0
+	}
+}
+
+func handleUserTags(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		Openid string `json:"openid"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	u := c.app.user(req.Openid)
+	if u == nil {
+		return nil, wechat.ErrCodeInvalidOpenid
+	}
+	return map[string]interface{}{"tagid_list": append([]int{}, u.TagIDList...)}, 0
+}
+
+// 从 nextOpenid 之后开始分页返回符合条件的用户
+func (s *Server) pageUsers(users []*User, nextOpenid string, match func(u *User) bool) interface{} {
+	size := s.UserPageSize
+	if size <= 0 {
+		size = 10000
+	}
+	var all []string
+	for _, u := range users {
+		if match(u) {
+			all = append(all, u.Openid)
+		}
+	}
+	start := 0
+	if nextOpenid != "" {
+		for i, openid := range all {
+			if openid == nextOpenid {
+				start = i + 1
+				break
+			}
+		}
+	}
+	page := []string{}
+	if start < len(all) {
+		end := start + size
+		if end > len(all) {
+			end = len(all)
+		}
+		page = all[start:end]
+	}
+	next := ""
+	if len(page) > 0 && start+len(page) < len(all) {
+		next = page[len(page)-1]
+	}
+	return map[string]interface{}{
+		"total":       len(all),
+		"count":       len(page),
+		"data":        map[string]interface{}{"openid": page},
+		"next_openid": next,
+	}
+}
+
+func handleUserGet(s *Server, c *call) (interface{}, int) {
+	return s.pageUsers(c.app.Users, c.Query.Get("next_openid"), func(u *User) bool {
+		return u.Subscribe == 1
+	}), 0
+}
+
+func handleTagUsers(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		TagID      int    `json:"tagid"`
+		NextOpenid string `json:"next_openid"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if c.app.tag(req.TagID) == nil {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	return s.pageUsers(c.app.Users, req.NextOpenid, func(u *User) bool {
+		return u.hasTag(req.TagID)
+	}), 0
+}
+
+func handleUserInfo(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		UserList []struct {
+			Openid string `json:"openid"`
+		} `json:"user_list"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if len(req.UserList) == 0 || len(req.UserList) > 100 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	infos := make([]interface{}, 0, len(req.UserList))
+	for _, item := range req.UserList {
+		u := c.app.user(item.Openid)
+		if u == nil {
+			return nil, wechat.ErrCodeInvalidOpenid
+		}
+		if u.Subscribe == 1 {
+			infos = append(infos, u)
+		} else {
+			infos = append(infos, map[string]interface{}{"subscribe": 0, "openid": u.Openid})
+		}
+	}
+	return map[string]interface{}{"user_info_list": infos}, 0
+}
+
+func handleSetIndustry(s *Server, c *call) (interface{}, int) {
+	var req map[string]json.RawMessage
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	var industry []int
+	for _, key := range []string{"industry_id1", "industry_id2"} {
+		raw, ok := req[key]
+		if !ok {
+			continue
+		}
+		// 文档中行业编号为字符串, 也兼容数字
+		id, err := strconv.Atoi(strings.Trim(string(raw), `"`))
+		if err != nil {
+			return nil, wechat.ErrCodeInvalidJSON
+		}
+		industry = append(industry, id)
+	}
+	if len(industry) == 0 {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	c.app.Industry = industry
+	return nil, 0
+}
+
+func handleGetIndustry(s *Server, c *call) (interface{}, int) {
+	industry := func(i int) interface{} {
+		if i >= len(c.app.Industry) {
+			return nil
+		}
+		id := strconv.Itoa(c.app.Industry[i])
+		return map[string]string{"first_class": "industry " + id, "second_class": "industry " + id}
+	}
+	return map[string]interface{}{
+		"primary_industry":   industry(0),
+		"secondary_industry": industry(1),
+	}, 0
+}
+
+func handleAddTemplate(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		ShortID string `json:"template_id_short"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if req.ShortID == "" {
+		return nil, wechat.ErrCodeInvalidTemplateID
+	}
+	t := &Template{ID: s.newID("TEMPLATE_ID"), ShortID: req.ShortID, Title: req.ShortID}
+	c.app.Templates = append(c.app.Templates, t)
+	return map[string]interface{}{"errcode": 0, "errmsg": "ok", "template_id": t.ID}, 0
+}
+
+func handleGetTemplates(s *Server, c *call) (interface{}, int) {
+	return map[string]interface{}{"template_list": append([]*Template{}, c.app.Templates...)}, 0
+}
+
+func handleDelTemplate(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		ID string `json:"template_id"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	for i, t := range c.app.Templates {
+		if t.ID == req.ID {
+			c.app.Templates = append(c.app.Templates[:i], c.app.Templates[i+1:]...)
+			return nil, 0
+		}
+	}
+	return nil, wechat.ErrCodeInvalidTemplateID
+}
+
+// 校验消息接收者
+func (c *call) receiver(openid string) int {
+	u := c.app.user(openid)
+	if u == nil {
+		return wechat.ErrCodeInvalidOpenid
+	}
+	if u.Subscribe != 1 {
+		return wechat.ErrCodeRequireSubscribe
+	}
+	return 0
+}
+
+func handleTemplateSend(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		ToUser     string `json:"touser"`
+		TemplateID string `json:"template_id"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if errcode := c.receiver(req.ToUser); errcode != 0 {
+		return nil, errcode
+	}
+	found := false
+	for _, t := range c.app.Templates {
+		found = found || t.ID == req.TemplateID
+	}
+	if !found {
+		return nil, wechat.ErrCodeInvalidTemplateID
+	}
+	c.app.TemplateMessages = append(c.app.TemplateMessages, append(json.RawMessage(nil), c.Body...))
+	return map[string]interface{}{"errcode": 0, "errmsg": "ok", "msgid": s.nextSeq()}, 0
+}
+
+func handleCustomSend(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		ToUser string `json:"touser"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if errcode := c.receiver(req.ToUser); errcode != 0 {
+		return nil, errcode
+	}
+	c.app.CustomMessages = append(c.app.CustomMessages, append(json.RawMessage(nil), c.Body...))
+	return nil, 0
+}
+
+// 群发请求中的 clientmsgid, 兼容字符串及数字
+func clientMsgID(body []byte) string {
+	var req struct {
+		ClientMsgID json.RawMessage `json:"clientmsgid"`
+	}
+	_ = json.Unmarshal(body, &req)
+	return strings.Trim(string(req.ClientMsgID), `"`)
+}
+
+func handleMassSend(s *Server, c *call) (interface{}, int) {
+	var req map[string]json.RawMessage
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	id := clientMsgID(c.Body)
+	if len(id) > 64 {
+		return nil, wechat.ErrCodeGroupMsgClientIDTooLong
+	}
+	if id != "" {
+		for _, m := range c.app.MassMessages {
+			if clientMsgID(m.Body) == id {
+				return nil, wechat.ErrCodeGroupMsgAlreadySent
+			}
+		}
+	}
+	m := &MassMessage{
+		Path:  c.Path,
+		Body:  append(json.RawMessage(nil), c.Body...),
+		MsgID: s.nextSeq(),
+	}
+	m.MsgDataID = m.MsgID
+	c.app.MassMessages = append(c.app.MassMessages, m)
+	return map[string]interface{}{
+		"errcode":     0,
+		"errmsg":      "send job submission success",
+		"msg_id":      m.MsgID,
+		"msg_data_id": m.MsgDataID,
+	}, 0
+}
+
+// 群发速度级别对应的真实速度(万/分钟)
+var realSpeeds = []int{80, 60, 45, 30, 10}
+
+func handleGetMassSpeed(s *Server, c *call) (interface{}, int) {
+	return map[string]interface{}{"speed": c.app.MassSpeed, "realspeed": realSpeeds[c.app.MassSpeed]}, 0
+}
+
+func handleSetMassSpeed(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		Speed int `json:"speed"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if req.Speed < 0 || req.Speed >= len(realSpeeds) {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	c.app.MassSpeed = req.Speed
+	return nil, 0
+}
+
+func handleQRCode(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		ExpireSeconds int64  `json:"expire_seconds"`
+		ActionName    string `json:"action_name"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	res := map[string]interface{}{}
+	switch req.ActionName {
+	case "QR_SCENE", "QR_STR_SCENE":
+		if req.ExpireSeconds <= 0 {
+			req.ExpireSeconds = 30
+		}
+		res["expire_seconds"] = req.ExpireSeconds
+	case "QR_LIMIT_SCENE", "QR_LIMIT_STR_SCENE":
+	default:
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	ticket := s.newID("QRCODE_TICKET")
+	res["ticket"] = ticket
+	res["url"] = "http://weixin.qq.com/q/" + ticket
+	c.app.QRCodes = append(c.app.QRCodes, append(json.RawMessage(nil), c.Body...))
+	return res, 0
+}
+
+// 解析数据分析接口的日期范围
+func (c *call) dateRange() (begin, end string, errcode int) {
+	var req struct {
+		BeginDate string `json:"begin_date"`
+		EndDate   string `json:"end_date"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return "", "", errcode
+	}
+	if req.BeginDate == "" || req.EndDate < req.BeginDate {
+		return "", "", wechat.ErrCodeInvalidJSON
+	}
+	return req.BeginDate, req.EndDate, 0
+}
+
+func handleUserSummary(s *Server, c *call) (interface{}, int) {
+	begin, end, errcode := c.dateRange()
+	if errcode != 0 {
+		return nil, errcode
+	}
+	list := []*UserSummary{}
+	for _, item := range c.app.UserSummary {
+		if item.RefDate >= begin && item.RefDate <= end {
+			list = append(list, item)
+		}
+	}
+	return map[string]interface{}{"list": list}, 0
+}
+
+func handleUserCumulate(s *Server, c *call) (interface{}, int) {
+	begin, end, errcode := c.dateRange()
+	if errcode != 0 {
+		return nil, errcode
+	}
+	list := []*UserCumulate{}
+	for _, item := range c.app.UserCumulate {
+		if item.RefDate >= begin && item.RefDate <= end {
+			list = append(list, item)
+		}
+	}
+	return map[string]interface{}{"list": list}, 0
+}
+
+func handleComponentToken(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		Appid        string `json:"component_appid"`
+		Secret       string `json:"component_appsecret"`
+		VerifyTicket string `json:"component_verify_ticket"`
+	}
+	if errcode := c.decode(&req); errcode != 0 {
+		return nil, errcode
+	}
+	component := s.components[req.Appid]
+	if component == nil {
+		return nil, wechat.ErrCodeInvalidAppid
+	}
+	if component.Secret != req.Secret {
+		return nil, wechat.ErrCodeInvalidAppSecret
+	}
+	if component.VerifyTicket != "" && component.VerifyTicket != req.VerifyTicket {
+		return nil, errCodeInvalidVerifyTicket
+	}
+	return map[string]interface{}{
+		"component_access_token": s.issueToken(s.componentTokens, "COMPONENT_ACCESS_TOKEN", component.Appid),
+		"expires_in":             TokenExpiresIn,
+	}, 0
+}
+
+// 第三方平台接口的公共参数
+type componentRequest struct {
+	ComponentAppid  string `json:"component_appid"`
+	AuthorizerAppid string `json:"authorizer_appid"`
+}
+
+func (r *componentRequest) componentAppid() string {
+	return r.ComponentAppid
+}
+
+func (c *call) decodeComponent(v interface{ componentAppid() string }) int {
+	if errcode := c.decode(v); errcode != 0 {
+		return errcode
+	}
+	if v.componentAppid() != c.component.Appid {
+		return wechat.ErrCodeInvalidAppid
+	}
+	return 0
+}
+
+// 返回已授权给第三方平台的公众号
+func (s *Server) authorizer(c *call, appid string) (*App, int) {
+	for _, authorizer := range c.component.refreshTokens {
+		if authorizer == appid {
+			return s.apps[appid], 0
+		}
+	}
+	return nil, errCodeComponentUnauthorized
+}
+
+func (c *call) funcInfo() []interface{} {
+	info := make([]interface{}, 0, len(c.component.FuncInfo))
+	for _, id := range c.component.FuncInfo {
+		info = append(info, map[string]interface{}{"funcscope_category": map[string]int{"id": id}})
+	}
+	return info
+}
+
+func handlePreAuthCode(s *Server, c *call) (interface{}, int) {
+	var req componentRequest
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	return map[string]interface{}{"pre_auth_code": s.newID("PRE_AUTH_CODE"), "expires_in": 600}, 0
+}
+
+func handleQueryAuth(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		componentRequest
+		AuthorizationCode string `json:"authorization_code"`
+	}
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	code := s.authCodes[req.AuthorizationCode]
+	if code == nil || code.componentAppid != c.component.Appid {
+		return nil, wechat.ErrCodeInvalidOAuthCode
+	}
+	delete(s.authCodes, req.AuthorizationCode)
+	refreshToken := s.newID("AUTHORIZER_REFRESH_TOKEN")
+	c.component.refreshTokens[refreshToken] = code.authorizerAppid
+	return map[string]interface{}{
+		"authorization_info": map[string]interface{}{
+			"authorizer_appid":         code.authorizerAppid,
+			"authorizer_access_token":  s.issueToken(s.tokens, "AUTHORIZER_ACCESS_TOKEN", code.authorizerAppid),
+			"expires_in":               TokenExpiresIn,
+			"authorizer_refresh_token": refreshToken,
+			"func_info":                c.funcInfo(),
+		},
+	}, 0
+}
+
+func handleAuthorizerToken(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		componentRequest
+		RefreshToken string `json:"authorizer_refresh_token"`
+	}
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	if c.component.refreshTokens[req.RefreshToken] != req.AuthorizerAppid {
+		return nil, wechat.ErrCodeInvalidRefreshToken
+	}
+	return map[string]interface{}{
+		"authorizer_access_token":  s.issueToken(s.tokens, "AUTHORIZER_ACCESS_TOKEN", req.AuthorizerAppid),
+		"expires_in":               TokenExpiresIn,
+		"authorizer_refresh_token": req.RefreshToken,
+	}, 0
+}
+
+func handleAuthorizerInfo(s *Server, c *call) (interface{}, int) {
+	var req componentRequest
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	app, errcode := s.authorizer(c, req.AuthorizerAppid)
+	if errcode != 0 {
+		return nil, errcode
+	}
+	return map[string]interface{}{
+		"authorizer_info": map[string]interface{}{
+			"nick_name":         app.NickName,
+			"service_type_info": map[string]int{"id": 2},
+			"verify_type_info":  map[string]int{"id": 0},
+			"user_name":         "gh_" + app.Appid,
+		},
+		"authorization_info": map[string]interface{}{
+			"authorizer_appid": app.Appid,
+			"func_info":        c.funcInfo(),
+		},
+	}, 0
+}
+
+func handleGetAuthorizerOption(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		componentRequest
+		OptionName string `json:"option_name"`
+	}
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	app, errcode := s.authorizer(c, req.AuthorizerAppid)
+	if errcode != 0 {
+		return nil, errcode
+	}
+	return map[string]interface{}{
+		"authorizer_appid": app.Appid,
+		"option_name":      req.OptionName,
+		"option_value":     app.Options[req.OptionName],
+	}, 0
+}
+
+func handleSetAuthorizerOption(s *Server, c *call) (interface{}, int) {
+	var req struct {
+		componentRequest
+		OptionName  string `json:"option_name"`
+		OptionValue string `json:"option_value"`
+	}
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	app, errcode := s.authorizer(c, req.AuthorizerAppid)
+	if errcode != 0 {
+		return nil, errcode
+	}
+	app.Options[req.OptionName] = req.OptionValue
+	return nil, 0
+}
+
+func handleClearComponentQuota(s *Server, c *call) (interface{}, int) {
+	var req componentRequest
+	if errcode := c.decodeComponent(&req); errcode != 0 {
+		return nil, errcode
+	}
+	return nil, 0
+}
+
+type unifiedOrderResponse struct {
+	XMLName    xml.Name `xml:"xml"`
+	ReturnCode string   `xml:"return_code"`
+	ReturnMsg  string   `xml:"return_msg"`
+	AppID      string   `xml:"appid"`
+	MchID      string   `xml:"mch_id"`
+	NonceStr   string   `xml:"nonce_str"`
+	ResultCode string   `xml:"result_code"`
+	TradeType  string   `xml:"trade_type"`
+	PrepayID   string   `xml:"prepay_id"`
+}
+
+// 统一下单, 不校验签名
+func handleUnifiedOrder(s *Server, c *call) (interface{}, int) {
+	order := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(c.Body))
+	var name string
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, wechat.ErrCodeInvalidJSON
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			name = t.Name.Local
+		case xml.CharData:
+			if name != "" {
+				order[name] = string(t)
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+	if order["appid"] == "" || order["out_trade_no"] == "" {
+		return nil, wechat.ErrCodeInvalidJSON
+	}
+	app := s.app(order["appid"])
+	app.Orders = append(app.Orders, order)
+	return &unifiedOrderResponse{
+		ReturnCode: "SUCCESS",
+		ReturnMsg:  "OK",
+		AppID:      order["appid"],
+		MchID:      order["mch_id"],
+		NonceStr:   order["nonce_str"],
+		ResultCode: "SUCCESS",
+		TradeType:  order["trade_type"],
+		PrepayID:   s.newID("PREPAY_ID"),
+	}, 0
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechattest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/message"
+)
+
+// EncryptMode 是消息加解密方式
+type EncryptMode int
+
+const (
+	// 明文模式
+	ModePlaintext EncryptMode = iota
+
+	// 兼容模式, 消息同时包含明文及密文
+	ModeCompatible
+
+	// 安全模式, 消息只包含密文
+	ModeSecure
+)
+
+// 自增的消息 ID
+var msgID int64
+
+// InboundMessage 是微信服务器推送给本地服务器的消息, 用于生成模拟请求
+type InboundMessage struct {
+	// openid
+	FromUserName string
+
+	// 为 0 时使用当前时间
+	CreateTime int64
+
+	MsgType message.ServerMsgType
+
+	Event message.EventType
+
+	EventKey string
+
+	// 普通消息的消息 ID, 为 0 时自动生成, 事件消息没有该字段
+	MsgId int64
+
+	// 其他字段, 如 Content、PicUrl、Latitude 等, 按字段名排序后写入
+	Fields map[string]string
+}
+
+// NewInboundMessage 创建用户 openid 发送的 msgType 类型的消息
+func NewInboundMessage(openid string, msgType message.ServerMsgType) *InboundMessage {
+	return &InboundMessage{FromUserName: openid, MsgType: msgType}
+}
+
+// TextMessage 创建用户 openid 发送的文本消息
+func TextMessage(openid, content string) *InboundMessage {
+	return NewInboundMessage(openid, message.ServerMsgTypeText).Set("Content", content)
+}
+
+// EventMessage 创建用户 openid 触发的事件消息
+func EventMessage(openid string, event message.EventType, eventKey string) *InboundMessage {
+	msg := NewInboundMessage(openid, message.ServerMsgTypeEvent)
+	msg.Event = event
+	msg.EventKey = eventKey
+	return msg
+}
+
+// Set 设置字段 name 的值, 返回 msg 本身以便链式调用
+func (msg *InboundMessage) Set(name, value string) *InboundMessage {
+	if msg.Fields == nil {
+		msg.Fields = make(map[string]string)
+	}
+	msg.Fields[name] = value
+	return msg
+}
+
+// 生成消息的 xml 数据, 字符串值均以 CDATA 形式写入
+func (msg *InboundMessage) xml(toUserName string) []byte {
+	buf := bytes.NewBufferString("<xml>")
+	writeCdata(buf, "ToUserName", toUserName)
+	writeCdata(buf, "FromUserName", msg.FromUserName)
+	createTime := msg.CreateTime
+	if createTime == 0 {
+		createTime = time.Now().Unix()
+	}
+	fmt.Fprintf(buf, "<CreateTime>%d</CreateTime>", createTime)
+	writeCdata(buf, "MsgType", string(msg.MsgType))
+	if msg.MsgType == message.ServerMsgTypeEvent {
+		writeCdata(buf, "Event", string(msg.Event))
+		if msg.EventKey != "" {
+			writeCdata(buf, "EventKey", msg.EventKey)
+		}
+	} else {
+		id := msg.MsgId
+		if id == 0 {
+			id = atomic.AddInt64(&msgID, 1)
+		}
+		fmt.Fprintf(buf, "<MsgId>%d</MsgId>", id)
+	}
+	names := make([]string, 0, len(msg.Fields))
+	for name := range msg.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeCdata(buf, name, msg.Fields[name])
+	}
+	buf.WriteString("</xml>")
+	return buf.Bytes()
+}
+
+func writeCdata(buf *bytes.Buffer, name, value string) {
+	// CDATA 中不能包含 "]]>", 需要拆分为两段
+	value = strings.Replace(value, "]]>", "]]]]><![CDATA[>", -1)
+	fmt.Fprintf(buf, "<%s><![CDATA[%s]]></%s>", name, value, name)
+}
+
+// Simulator 模拟微信服务器向本地服务器推送消息, 生成带有正确签名的请求, 并解析本地服务器的被动回复.
+type Simulator struct {
+	// 消息校验 token
+	Token string
+
+	// 公众号原始 ID, 为空时为 "gh_wechattest"
+	ToUserName string
+
+	// 加解密方式, 兼容模式及安全模式下需要设置 Crypt
+	Mode EncryptMode
+
+	// 加解密器, 与本地服务器使用相同的 token、EncodingAESKey 及 appid 创建
+	Crypt *wechat.WXBizMsgCrypt
+
+	// 请求地址, 为空时为 "/"
+	Target string
+}
+
+func (s *Simulator) toUserName() string {
+	if s.ToUserName == "" {
+		return "gh_wechattest"
+	}
+	return s.ToUserName
+}
+
+func (s *Simulator) target(query url.Values) string {
+	target := s.Target
+	if target == "" {
+		target = "/"
+	}
+	if strings.Contains(target, "?") {
+		return target + "&" + query.Encode()
+	}
+	return target + "?" + query.Encode()
+}
+
+func (s *Simulator) signedQuery() url.Values {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := strconv.FormatInt(atomic.AddInt64(&msgID, 1), 10)
+	return url.Values{
+		"timestamp": {timestamp},
+		"nonce":     {nonce},
+		"signature": {wechat.SignParams(s.Token, timestamp, nonce)},
+	}
+}
+
+// NewVerifyRequest 生成微信后台配置服务器地址时发送的验证请求, 本地服务器应响应 echostr
+func (s *Simulator) NewVerifyRequest(echostr string) *http.Request {
+	query := s.signedQuery()
+	query.Set("echostr", echostr)
+	return httptest.NewRequest(http.MethodGet, s.target(query), nil)
+}
+
+// NewRequest 生成推送消息 msg 的请求, 按 Mode 对消息进行加密
+func (s *Simulator) NewRequest(msg *InboundMessage) (*http.Request, error) {
+	query := s.signedQuery()
+	plain := msg.xml(s.toUserName())
+	body := plain
+	if s.Mode != ModePlaintext {
+		if s.Crypt == nil {
+			return nil, errors.New("wechattest: Crypt is required in compatible or secure mode")
+		}
+		timestamp, _ := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+		encrypted, err := s.Crypt.EncryptMsg(plain, timestamp, query.Get("nonce"))
+		if err != nil {
+			return nil, err
+		}
+		query.Set("encrypt_type", "aes")
+		query.Set("msg_signature", encrypted.MsgSignature)
+		buf := bytes.NewBufferString("<xml>")
+		writeCdata(buf, "ToUserName", s.toUserName())
+		writeCdata(buf, "Encrypt", encrypted.Encrypt.Value)
+		if s.Mode == ModeCompatible {
+			// 兼容模式下同时包含明文字段
+			buf.Write(bytes.TrimSuffix(bytes.TrimPrefix(plain, []byte("<xml>")), []byte("</xml>")))
+		}
+		buf.WriteString("</xml>")
+		body = buf.Bytes()
+	}
+	req := httptest.NewRequest(http.MethodPost, s.target(query), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "text/xml")
+	return req, nil
+}
+
+// ParseReply 解析本地服务器的被动回复, 安全模式及兼容模式下先校验签名并解密.
+// 回复为空或 "success" 时返回 nil, nil.
+func (s *Simulator) ParseReply(data []byte) (*message.ResponseMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "success" {
+		return nil, nil
+	}
+	if s.Mode != ModePlaintext {
+		if s.Crypt == nil {
+			return nil, errors.New("wechattest: Crypt is required in compatible or secure mode")
+		}
+		encrypted := &struct {
+			Encrypt      string
+			MsgSignature string
+			TimeStamp    string
+			Nonce        string
+		}{}
+		err := xml.Unmarshal(data, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		data, err = s.Crypt.DecryptMsg(encrypted.MsgSignature, encrypted.TimeStamp, encrypted.Nonce, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reply := &message.ResponseMessage{}
+	err := xml.Unmarshal(data, reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Send 将消息 msg 推送给 handler 处理, 并解析其被动回复. 没有回复时返回 nil, nil.
+func (s *Simulator) Send(handler http.Handler, msg *InboundMessage) (*message.ResponseMessage, error) {
+	req, err := s.NewRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("wechattest: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	return s.ParseReply(rec.Body.Bytes())
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechattest_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/message"
+	"github.com/orivil/wechat/wechattest"
+)
+
+func newSimulator(t *testing.T, mode wechattest.EncryptMode) *wechattest.Simulator {
+	t.Helper()
+	crypt, err := wechat.NewWXBizMsgCrypt("token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", "wx123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wechattest.Simulator{Token: "token", ToUserName: "gh_123", Mode: mode, Crypt: crypt}
+}
+
+func TestSimulatorModes(t *testing.T) {
+	modes := []struct {
+		name string
+		mode wechattest.EncryptMode
+	}{
+		{"plaintext", wechattest.ModePlaintext},
+		{"compatible", wechattest.ModeCompatible},
+		{"secure", wechattest.ModeSecure},
+	}
+	for _, m := range modes {
+		sim := newSimulator(t, m.mode)
+		var crypt *wechat.WXBizMsgCrypt
+		if m.mode != wechattest.ModePlaintext {
+			crypt = sim.Crypt
+		}
+		msg := wechattest.TextMessage("openid", "hello ]]> world")
+		msg.MsgId = 100
+		req, err := sim.NewRequest(msg)
+		if err != nil {
+			t.Fatalf("%s: %v", m.name, err)
+		}
+		if _, err = message.CheckSignature(req, "token"); err != nil {
+			t.Fatalf("%s: %v", m.name, err)
+		}
+		if encrypted := req.URL.Query().Get("encrypt_type") == "aes"; encrypted != (crypt != nil) {
+			t.Errorf("%s: got encrypt_type %q", m.name, req.URL.Query().Get("encrypt_type"))
+		}
+		sm, err := message.ReadServerMessage(req, crypt)
+		if err != nil {
+			t.Fatalf("%s: %v", m.name, err)
+		}
+		if sm.ToUserName != "gh_123" || sm.FromUserName != "openid" || sm.MsgType != message.ServerMsgTypeText || sm.MsgId != 100 {
+			t.Errorf("%s: got %+v", m.name, sm)
+		}
+		text, err := sm.MarshalTextMessage()
+		if err != nil || text.Content != "hello ]]> world" {
+			t.Errorf("%s: got %+v, %v", m.name, text, err)
+		}
+
+		// 本地服务器的被动回复
+		reply, err := sm.ReplyText("reply")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		err = message.Response(sm, reply, rec, crypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sim.ParseReply(rec.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", m.name, err)
+		}
+		if got.Content == nil || got.Content.Value != "reply" || got.ToUserName.Value != "openid" || got.FromUserName.Value != "gh_123" {
+			t.Errorf("%s: got reply %+v", m.name, got)
+		}
+		reply, err = sim.ParseReply([]byte("success"))
+		if reply != nil || err != nil {
+			t.Errorf("%s: success: got %+v, %v", m.name, reply, err)
+		}
+	}
+
+	// 兼容模式及安全模式需要 Crypt
+	sim := &wechattest.Simulator{Token: "token", Mode: wechattest.ModeSecure}
+	if _, err := sim.NewRequest(wechattest.TextMessage("openid", "hello")); err == nil {
+		t.Error("want error without Crypt")
+	}
+}
+
+func TestSimulatorEvents(t *testing.T) {
+	sim := newSimulator(t, wechattest.ModeSecure)
+	msg := wechattest.EventMessage("openid", message.EvtUserClick, "V1001")
+	req, err := sim.NewRequest(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := message.ReadServerMessage(req, sim.Crypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.MsgType != message.ServerMsgTypeEvent || sm.Event != message.EvtUserClick || sm.EventKey != "V1001" || sm.MsgId != 0 {
+		t.Errorf("got %+v", sm)
+	}
+
+	// 其他字段按字段名写入
+	msg = wechattest.NewInboundMessage("openid", message.ServerMsgTypeImage).Set("PicUrl", "http://a.com/a.png").Set("MediaId", "media")
+	req, err = sim.NewRequest(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err = message.ReadServerMessage(req, sim.Crypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := sm.MarshalImageMessage()
+	if err != nil || image.PicUrl != "http://a.com/a.png" || image.MediaId != "media" || image.MsgId == 0 {
+		t.Errorf("got %+v, %v", image, err)
+	}
+}
+
+func TestSimulatorServer(t *testing.T) {
+	for _, mode := range []wechattest.EncryptMode{wechattest.ModePlaintext, wechattest.ModeCompatible, wechattest.ModeSecure} {
+		sim := newSimulator(t, mode)
+		s := message.NewServer("token", sim.Crypt)
+		s.HandleFunc(message.ServerMsgTypeText, func(req *message.Request) (*message.ResponseMessage, error) {
+			text, err := req.MarshalTextMessage()
+			if err != nil {
+				return nil, err
+			}
+			return req.ReplyText("echo: " + text.Content)
+		})
+		reply, err := sim.Send(s, wechattest.TextMessage("openid", "hello"))
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if reply == nil || reply.Content == nil || reply.Content.Value != "echo: hello" {
+			t.Errorf("mode %d: got reply %+v", mode, reply)
+		}
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, sim.NewVerifyRequest("echo"))
+		if rec.Body.String() != "echo" {
+			t.Errorf("mode %d: verify: got %q", mode, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// Package wechattest 提供模拟微信接口的本地服务器, 用于在不访问微信服务器的情况下进行集成测试.
+//
+// 服务器在内存中保存公众号、令牌、菜单、素材、标签、用户及已发送的消息等数据, 并记录所有收到的请求,
+// 测试时可通过 InjectError 让指定接口返回错误码. 本库的接口均可通过 Server.Client 指向该服务器:
+//
+//	srv := wechattest.NewServer()
+//	defer srv.Close()
+//	srv.AddApp("wx123", "secret")
+//	token, err := access.GetAccessTokenWith(srv.Client(), "wx123", "secret")
+//
+// Simulator 则用于模拟微信服务器向本地服务器推送消息及事件, 以测试消息处理逻辑:
+//
+//	sim := &wechattest.Simulator{Token: "token"}
+//	reply, err := sim.Send(handler, wechattest.TextMessage("openid", "hello"))
+package wechattest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orivil/wechat"
+)
+
+// 令牌有效期(秒)
+const TokenExpiresIn = 7200
+
+// Request 是服务器收到的请求记录
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// 注入的错误
+type fault struct {
+	status  int
+	errcode int
+	times   int // 剩余次数, 小于 0 时不限次数
+}
+
+type token struct {
+	appid     string
+	expiresAt time.Time
+}
+
+type authCode struct {
+	componentAppid  string
+	authorizerAppid string
+}
+
+// Server 是模拟微信接口的本地服务器
+type Server struct {
+	*httptest.Server
+
+	// user/get 接口每次返回的用户数量, 为 0 时为 10000
+	UserPageSize int
+
+	mu         sync.Mutex
+	seq        int
+	apps       map[string]*App
+	components map[string]*Component
+	faults     map[string][]*fault
+	requests   []*Request
+
+	// access_token 及 authorizer_access_token
+	tokens map[string]*token
+
+	// component_access_token
+	componentTokens map[string]*token
+
+	// authorization_code
+	authCodes map[string]*authCode
+}
+
+// NewServer 创建并启动服务器, 使用完毕后需调用 Close 关闭
+func NewServer() *Server {
+	s := &Server{
+		apps:            make(map[string]*App),
+		components:      make(map[string]*Component),
+		faults:          make(map[string][]*fault),
+		tokens:          make(map[string]*token),
+		componentTokens: make(map[string]*token),
+		authCodes:       make(map[string]*authCode),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client 返回将所有请求(包括微信支付接口)发送到该服务器的客户端.
+// 客户端默认不重试, 以便准确断言请求次数, 需要时可重新设置 Retry
+func (s *Server) Client() *wechat.Client {
+	return &wechat.Client{
+		HTTPClient: s.Server.Client(),
+		Retry:      wechat.NoRetry,
+		BaseURL:    s.URL,
+		MchBaseURL: s.URL,
+	}
+}
+
+// UseAsDefault 将 wechat.DefaultClient 替换为指向该服务器的客户端, 返回用于恢复原客户端的函数, 如:
+//
+//	defer srv.UseAsDefault()()
+func (s *Server) UseAsDefault() (restore func()) {
+	old := wechat.DefaultClient
+	wechat.DefaultClient = s.Client()
+	return func() {
+		wechat.DefaultClient = old
+	}
+}
+
+// AddApp 添加公众号, 已存在时更新 secret
+func (s *Server) AddApp(appid, secret string) *App {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.app(appid)
+	app.Secret = secret
+	return app
+}
+
+// AddUsers 向公众号 appid 添加用户, 公众号不存在时会自动添加
+func (s *Server) AddUsers(appid string, users ...*User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.app(appid)
+	app.Users = append(app.Users, users...)
+}
+
+// App 返回公众号的模拟数据, 不存在时返回 nil. 返回值应在没有未完成的请求时读取.
+func (s *Server) App(appid string) *App {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apps[appid]
+}
+
+func (s *Server) app(appid string) *App {
+	app := s.apps[appid]
+	if app == nil {
+		app = newApp(appid)
+		s.apps[appid] = app
+	}
+	return app
+}
+
+// AddComponent 添加第三方平台
+func (s *Server) AddComponent(appid, secret string) *Component {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.components[appid]
+	if c == nil {
+		c = &Component{Appid: appid, refreshTokens: make(map[string]string)}
+		s.components[appid] = c
+	}
+	c.Secret = secret
+	return c
+}
+
+// Authorize 模拟公众号 authorizerAppid 授权给第三方平台 componentAppid, 返回授权码 authorization_code.
+// 公众号不存在时会自动添加.
+func (s *Server) Authorize(componentAppid, authorizerAppid string) (authorizationCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.app(authorizerAppid)
+	authorizationCode = s.newID("AUTH_CODE")
+	s.authCodes[authorizationCode] = &authCode{componentAppid: componentAppid, authorizerAppid: authorizerAppid}
+	return authorizationCode
+}
+
+// ExpireTokens 使 appid 已获取的所有令牌过期, 之后使用这些令牌将返回 42001 错误
+func (s *Server) ExpireTokens(appid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tokens := range []map[string]*token{s.tokens, s.componentTokens} {
+		for _, t := range tokens {
+			if t.appid == appid {
+				t.expiresAt = time.Now()
+			}
+		}
+	}
+}
+
+// InjectError 使接口 path(如 "/cgi-bin/menu/create") 接下来的 times 次请求返回错误码 errcode,
+// times 小于等于 0 时一直返回该错误, 直到调用 ClearErrors.
+func (s *Server) InjectError(path string, errcode, times int) {
+	s.inject(path, &fault{errcode: errcode, times: faultTimes(times)})
+}
+
+// InjectStatus 使接口 path 接下来的 times 次请求返回 HTTP 状态码 status, 用于模拟服务器故障
+func (s *Server) InjectStatus(path string, status, times int) {
+	s.inject(path, &fault{status: status, times: faultTimes(times)})
+}
+
+func faultTimes(times int) int {
+	if times <= 0 {
+		return -1
+	}
+	return times
+}
+
+func (s *Server) inject(path string, f *fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[path] = append(s.faults[path], f)
+}
+
+// ClearErrors 清除所有注入的错误
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string][]*fault)
+}
+
+func (s *Server) takeFault(path string) *fault {
+	faults := s.faults[path]
+	if len(faults) == 0 {
+		return nil
+	}
+	f := faults[0]
+	if f.times > 0 {
+		f.times--
+		if f.times == 0 {
+			s.faults[path] = faults[1:]
+		}
+	}
+	return f
+}
+
+// Requests 返回服务器收到的所有请求
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// RequestsTo 返回服务器收到的发往接口 path 的请求
+func (s *Server) RequestsTo(path string) []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reqs []*Request
+	for _, req := range s.requests {
+		if req.Path == path {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// ResetRequests 清除请求记录
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+func (s *Server) newID(prefix string) string {
+	return prefix + "_" + strconv.Itoa(s.nextSeq())
+}
+
+func (s *Server) issueToken(tokens map[string]*token, prefix, appid string) string {
+	value := s.newID(prefix)
+	tokens[value] = &token{appid: appid, expiresAt: time.Now().Add(TokenExpiresIn * time.Second)}
+	return value
+}
+
+// 校验令牌, 返回令牌所属的 appid 及错误码
+func (s *Server) checkToken(tokens map[string]*token, value string) (appid string, errcode int) {
+	t := tokens[value]
+	if t == nil {
+		return "", wechat.ErrCodeInvalidAccessToken
+	}
+	if !time.Now().Before(t.expiresAt) {
+		return "", wechat.ErrCodeAccessTokenExpired
+	}
+	return t.appid, 0
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	route, ok := routes[req.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if f := s.takeFault(req.Path); f != nil {
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+			return
+		}
+		writeError(w, route.xml, f.errcode)
+		return
+	}
+	call := &call{Request: req}
+	switch route.auth {
+	case authApp:
+		appid, errcode := s.checkToken(s.tokens, req.Query.Get("access_token"))
+		if errcode != 0 {
+			writeError(w, route.xml, errcode)
+			return
+		}
+		call.app = s.apps[appid]
+	case authComponent:
+		appid, errcode := s.checkToken(s.componentTokens, req.Query.Get("component_access_token"))
+		if errcode != 0 {
+			writeError(w, route.xml, errcode)
+			return
+		}
+		call.component = s.components[appid]
+	}
+	res, errcode := route.handle(s, call)
+	if errcode != 0 {
+		writeError(w, route.xml, errcode)
+		return
+	}
+	switch v := res.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(v)
+	case *file:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": v.name}))
+		_, _ = w.Write(v.data)
+	case nil:
+		writeJSON(w, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+	default:
+		if route.xml {
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(v)
+		} else {
+			writeJSON(w, v)
+		}
+	}
+}
+
+// 下载的文件
+type file struct {
+	name string
+	data []byte
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func writeError(w http.ResponseWriter, isXML bool, errcode int) {
+	errmsg := wechat.ErrCodeText(errcode, "en")
+	if errmsg == "" {
+		errmsg = "error"
+	}
+	if isXML {
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = fmt.Fprintf(w, "<xml><return_code>FAIL</return_code><return_msg>%d %s</return_msg></xml>", errcode, errmsg)
+		return
+	}
+	writeJSON(w, &wechat.Error{ErrCode: errcode, ErrMsg: errmsg})
+}
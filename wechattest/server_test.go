@@ -0,0 +1,276 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package wechattest_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/orivil/wechat"
+	"github.com/orivil/wechat/access"
+	open_platform "github.com/orivil/wechat/open-platform"
+	"github.com/orivil/wechat/users"
+	"github.com/orivil/wechat/wechattest"
+)
+
+// 返回已添加公众号 wx123 的服务器及 access_token
+func newServer(t *testing.T) (*wechattest.Server, string) {
+	t.Helper()
+	srv := wechattest.NewServer()
+	srv.AddApp("wx123", "secret")
+	token, err := access.GetAccessTokenWith(srv.Client(), "wx123", "secret")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return srv, token.Value
+}
+
+func TestToken(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	c := srv.Client()
+
+	_, err := access.GetAccessTokenWith(c, "wx123", "bad")
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidAppSecret {
+		t.Errorf("bad secret: got %v", err)
+	}
+	_, err = access.GetAccessTokenWith(c, "unknown", "secret")
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidAppid {
+		t.Errorf("unknown appid: got %v", err)
+	}
+
+	ticket, err := access.GetTicketWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Value == "" || ticket.Value != srv.App("wx123").Ticket {
+		t.Errorf("got ticket %q, want %q", ticket.Value, srv.App("wx123").Ticket)
+	}
+	_, err = access.GetTicketWith(c, "invalid")
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidAccessToken {
+		t.Errorf("invalid token: got %v", err)
+	}
+
+	srv.ExpireTokens("wx123")
+	_, err = access.GetTicketWith(c, token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeAccessTokenExpired {
+		t.Errorf("expired token: got %v", err)
+	}
+}
+
+func TestInjectError(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	c := srv.Client()
+
+	srv.InjectError("/cgi-bin/tags/get", wechat.ErrCodeAPIDailyQuota, 1)
+	_, err := users.GetTagsWith(c, token)
+	if !wechat.IsQuotaExceeded(err) {
+		t.Errorf("got %v, want quota exceeded", err)
+	}
+	_, err = users.GetTagsWith(c, token)
+	if err != nil {
+		t.Errorf("after injected error: %v", err)
+	}
+
+	// times 小于等于 0 时一直返回错误
+	srv.InjectStatus("/cgi-bin/tags/get", http.StatusBadGateway, 0)
+	for i := 0; i < 3; i++ {
+		_, err = users.GetTagsWith(c, token)
+		if err == nil {
+			t.Fatal("want error for status 502")
+		}
+	}
+	srv.ClearErrors()
+	_, err = users.GetTagsWith(c, token)
+	if err != nil {
+		t.Errorf("after ClearErrors: %v", err)
+	}
+
+	// 注入的错误优先于令牌校验, 并且只影响指定接口
+	srv.InjectError("/cgi-bin/tags/get", wechat.ErrCodeSystemBusy, 1)
+	_, err = users.GetUserTagsWith(c, "openid", token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeInvalidOpenid {
+		t.Errorf("other endpoint: got %v", err)
+	}
+	_, err = users.GetTagsWith(c, "invalid")
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeSystemBusy {
+		t.Errorf("got %v, want system busy", err)
+	}
+
+	if n := len(srv.RequestsTo("/cgi-bin/tags/get")); n != 7 {
+		t.Errorf("got %d requests, want 7", n)
+	}
+	srv.ResetRequests()
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("got %d requests after reset", n)
+	}
+}
+
+func TestTags(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	c := srv.Client()
+	srv.AddUsers("wx123", &wechattest.User{Openid: "o1", Subscribe: 1}, &wechattest.User{Openid: "o2", Subscribe: 1})
+
+	tag, err := users.CreateTagWith(c, "star", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag == nil || tag.ID == 0 || tag.Name != "star" {
+		t.Fatalf("got tag %+v", tag)
+	}
+	err = users.TagUsersWith(c, tag.ID, []string{"o1", "o2"}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = users.UntagUsersWith(c, tag.ID, []string{"o2"}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := users.GetUserTagsWith(c, "o1", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{tag.ID}) {
+		t.Errorf("got tag ids %v", ids)
+	}
+	res, err := users.GetTagUsersWith(c, tag.ID, "", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Data.Openid, []string{"o1"}) {
+		t.Errorf("got tag users %v", res.Data.Openid)
+	}
+
+	tag.Name = "moon"
+	err = users.UpdateTagWith(c, tag, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := users.GetTagsWith(c, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "moon" || tags[0].Count != 1 {
+		t.Errorf("got tags %+v", tags)
+	}
+	err = users.DeleteTagWith(c, tag, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app := srv.App("wx123"); len(app.Tags) != 0 || len(app.Users[0].TagIDList) != 0 {
+		t.Error("tag not deleted")
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	srv, token := newServer(t)
+	defer srv.Close()
+	srv.UserPageSize = 2
+	srv.AddUsers("wx123",
+		&wechattest.User{Openid: "o1", Subscribe: 1},
+		&wechattest.User{Openid: "o2", Subscribe: 0},
+		&wechattest.User{Openid: "o3", Subscribe: 1},
+		&wechattest.User{Openid: "o4", Subscribe: 1},
+		&wechattest.User{Openid: "o5", Subscribe: 1},
+	)
+	var pages [][]string
+	err := users.GetSubscribersWith(srv.Client(), token, func(openids []string) error {
+		pages = append(pages, openids)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"o1", "o3"}, {"o4", "o5"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %v, want %v", pages, want)
+	}
+}
+
+func TestComponent(t *testing.T) {
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	c := srv.Client()
+	component := srv.AddComponent("wxcomponent", "secret")
+	component.VerifyTicket = "ticket"
+	component.FuncInfo = []int{1, 2}
+
+	_, err := open_platform.GetComponentAccessTokenWith(c, "wxcomponent", "secret", "bad")
+	if err == nil {
+		t.Error("invalid verify ticket should fail")
+	}
+	token, err := open_platform.GetComponentAccessTokenWith(c, "wxcomponent", "secret", "ticket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := open_platform.GetPureAuthCodeWith(c, "wxcomponent", token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.PreAuthCode == "" {
+		t.Error("empty pre_auth_code")
+	}
+
+	authCode := srv.Authorize("wxcomponent", "wxapp")
+	info, err := open_platform.GetAuthorizationInfoWith(c, "wxcomponent", authCode, token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AuthorizerAppid != "wxapp" || len(info.FuncInfo) != 2 || info.AuthorizerAccessToken == "" {
+		t.Fatalf("got authorization info %+v", info)
+	}
+	// 授权码只能使用一次
+	_, err = open_platform.GetAuthorizationInfoWith(c, "wxcomponent", authCode, token.Token)
+	if err == nil {
+		t.Error("authorization code reused")
+	}
+
+	at, err := open_platform.RefreshAuthorizerTokenWith(c, "wxcomponent", "wxapp", info.AuthorizerRefreshToken, token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at.AuthorizerAccessToken == "" || at.AuthorizerAccessToken == info.AuthorizerAccessToken {
+		t.Errorf("got authorizer token %+v", at)
+	}
+	// 授权方令牌可调用公众号接口
+	_, err = users.GetTagsWith(c, at.AuthorizerAccessToken)
+	if err != nil {
+		t.Error(err)
+	}
+
+	option := open_platform.Option{AuthorizerAppid: "wxapp", OptionName: "voice_recognize", OptionValue: "1"}
+	err = open_platform.SetAuthorizerOptionWith(c, "wxcomponent", token.Token, option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := open_platform.GetAuthorizerOptionWith(c, "wxcomponent", "wxapp", "voice_recognize", token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != option {
+		t.Errorf("got option %+v, want %+v", got, option)
+	}
+	authorizer, err := open_platform.GetAuthorizerInfoWith(c, "wxcomponent", "wxapp", token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorizer.AuthorizerInfo.NickName != "wxapp" || authorizer.AuthorizationInfo.AuthorizerAppid != "wxapp" {
+		t.Errorf("got authorizer %+v", authorizer)
+	}
+	_, err = open_platform.GetAuthorizerInfoWith(c, "wxcomponent", "wxother", token.Token)
+	if err == nil {
+		t.Error("unauthorized app should fail")
+	}
+
+	srv.ExpireTokens("wxcomponent")
+	_, err = open_platform.GetPureAuthCodeWith(c, "wxcomponent", token.Token)
+	if code, _ := wechat.ErrCodeOf(err); code != wechat.ErrCodeAccessTokenExpired {
+		t.Errorf("expired component token: got %v", err)
+	}
+}